@@ -3,9 +3,16 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/alecthomas/kong"
 
@@ -17,16 +24,47 @@ import (
 // Version information (set by goreleaser)
 var version = "dev"
 
-type CLI struct {
-	Config  string      `short:"c" help:"Path to config file"`
-	Version VersionFlag `name:"version" help:"Print version information"`
+// exitCodeDrift is the exit status of 'drift --exit-code' when drift is detected
+const exitCodeDrift = 2
+
+// Exit statuses of 'plan --detailed-exitcode' (0 for no changes, 1 on errors)
+const (
+	exitCodePlanChanges     = 2
+	exitCodePlanDestructive = 3
+)
+
+// exitCodeError is returned by a command to exit with a status other than 0 or 1 without an error message.
+// Commands return it instead of calling os.Exit, so that their deferred cleanup runs.
+type exitCodeError struct {
+	code int
+}
 
-	Plan     PlanCmd     `cmd:"" help:"Show execution plan without making changes"`
-	Apply    ApplyCmd    `cmd:"" help:"Apply the configuration changes"`
-	Versions VersionsCmd `cmd:"" help:"List application versions"`
-	Diff     DiffCmd     `cmd:"" help:"Show diff between two versions"`
-	Activate ActivateCmd `cmd:"" help:"Activate a version"`
-	Dump     DumpCmd     `cmd:"" help:"Dump current cluster configuration as YAML"`
+func (e *exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// ExitCode implements kong.ExitCoder
+func (e *exitCodeError) ExitCode() int {
+	return e.code
+}
+
+type CLI struct {
+	Config   string            `short:"c" help:"Path to config file, or a directory or glob pattern of config files to merge"`
+	Output   string            `short:"o" enum:"text,json" default:"text" help:"Output format (text or json)"`
+	Policies string            `name:"policies" help:"Directory of policy files checked by plan and apply (default: policies/ in the config directory, if it exists)"`
+	Vars     map[string]string `name:"var" mapsep:"none" placeholder:"NAME=VALUE" help:"Set a variable referenced in the config. Repeatable"`
+	VarFiles []string          `name:"var-file" sep:"none" help:"YAML file of variables referenced in the config. Repeatable"`
+	Version  VersionFlag       `name:"version" help:"Print version information"`
+
+	Plan        PlanCmd        `cmd:"" help:"Show execution plan without making changes"`
+	Apply       ApplyCmd       `cmd:"" help:"Apply the configuration changes"`
+	Versions    VersionsCmd    `cmd:"" help:"List application versions"`
+	Diff        DiffCmd        `cmd:"" help:"Show diff between two versions"`
+	Drift       DriftCmd       `cmd:"" help:"Show differences between config, active version and latest version"`
+	Activate    ActivateCmd    `cmd:"" help:"Activate a version"`
+	Dump        DumpCmd        `cmd:"" help:"Dump current cluster configuration as YAML"`
+	Render      RenderCmd      `cmd:"" help:"Print the configuration after merging files and applying overlays"`
+	ForceUnlock ForceUnlockCmd `cmd:"" name:"force-unlock" help:"Release the apply lock left by an interrupted apply"`
 }
 
 type VersionFlag bool
@@ -37,11 +75,29 @@ func (v VersionFlag) BeforeApply() error {
 	return nil
 }
 
-type PlanCmd struct{}
+type PlanCmd struct {
+	Out              string   `name:"out" help:"Save the plan as JSON to the given file (e.g. plan.json) so that it can be applied later with 'apply <planfile>'"`
+	Targets          []string `name:"target" help:"Limit the plan to the given resource and its dependencies (app:<name>, asg:<name> or lb:<asg>/<name>). Repeatable"`
+	Parallelism      int      `name:"parallelism" help:"Maximum number of concurrent API operations" default:"4"`
+	Prune            bool     `name:"prune" help:"Plan deletion of applications that exist in the cluster but not in the config (except prune.keep)"`
+	DetailedExitCode bool     `name:"detailed-exitcode" help:"Exit with status 2 if there are changes, 3 if any resource is recreated or deleted (0 for no changes, 1 on errors)"`
+}
 
 type ApplyCmd struct {
-	Activate    bool `help:"Activate the created/updated version after apply"`
-	AutoApprove bool `short:"y" name:"auto-approve" help:"Skip interactive approval of plan before applying"`
+	PlanFile          string        `arg:"" optional:"" name:"planfile" help:"Saved plan file to apply (created by 'plan --out')"`
+	Activate          bool          `help:"Activate the created/updated version after apply"`
+	AtomicActivate    bool          `name:"atomic-activate" help:"With --activate, activate versions only after all of them are created, and roll back activations if any fails"`
+	AutoApprove       bool          `short:"y" name:"auto-approve" help:"Skip interactive approval of plan before applying"`
+	Targets           []string      `name:"target" help:"Limit the apply to the given resource and its dependencies (app:<name>, asg:<name> or lb:<asg>/<name>). Repeatable"`
+	Parallelism       int           `name:"parallelism" help:"Maximum number of concurrent API operations" default:"4"`
+	Resume            bool          `help:"Resume the interrupted apply recorded in the apply journal"`
+	Prune             bool          `name:"prune" help:"Delete applications that exist in the cluster but not in the config (except prune.keep). Required to delete applications without the interactive prompt"`
+	LockTimeout       time.Duration `name:"lock-timeout" help:"How long to wait for the apply lock held by another apply (e.g. 5m). Fails immediately by default" default:"0s"`
+	Wait              bool          `help:"With --activate, wait until the activated versions run at their desired count and older versions have drained"`
+	WaitTimeout       time.Duration `name:"wait-timeout" help:"Maximum time to wait for the rollouts with --wait" default:"10m"`
+	Verify            bool          `help:"With --activate, watch the containers of the activated versions and reactivate the previous version if they keep restarting or do not reach running"`
+	VerifyWindow      time.Duration `name:"verify-window" help:"How long to watch the containers with --verify" default:"2m"`
+	VerifyMaxRestarts int           `name:"verify-max-restarts" help:"Number of container restarts allowed during the verify window" default:"2"`
 }
 
 type VersionsCmd struct {
@@ -54,6 +110,11 @@ type DiffCmd struct {
 	To   int    `help:"Target version (default: latest version)" default:"0"`
 }
 
+type DriftCmd struct {
+	ExitCode    bool `name:"exit-code" help:"Exit with status 2 if drift is detected (1 on errors)"`
+	Parallelism int  `name:"parallelism" help:"Maximum number of concurrent API operations" default:"4"`
+}
+
 type ActivateCmd struct {
 	App           string `short:"a" help:"Application name" required:""`
 	TargetVersion int    `name:"target" short:"t" help:"Version to activate (default: latest)" default:"0"`
@@ -63,6 +124,15 @@ type DumpCmd struct {
 	ClusterName string `arg:"" help:"Cluster name to dump"`
 }
 
+type RenderCmd struct {
+	ShowSecrets bool `name:"show-secrets" help:"Print the values of secret env vars and registry passwords instead of (redacted)"`
+}
+
+type ForceUnlockCmd struct {
+	LockID      string `arg:"" name:"lock-id" help:"ID of the lock to release (shown when apply fails to acquire the lock)"`
+	AutoApprove bool   `short:"y" name:"auto-approve" help:"Skip interactive confirmation"`
+}
+
 func main() {
 	var cli CLI
 	ctx := kong.Parse(&cli,
@@ -70,15 +140,40 @@ func main() {
 		kong.Description("Provision AppRun Dedicated applications from YAML configuration"),
 	)
 
+	// Cancel the command on Ctrl-C or SIGTERM instead of exiting, so that it releases the apply lock
+	// and records the progress of an apply before it returns. A second signal exits immediately.
+	runCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-runCtx.Done()
+		stop()
+	}()
+	ctx.BindTo(runCtx, (*context.Context)(nil))
+
 	err := ctx.Run(&cli)
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) {
+		// The command has reported the result already
+		os.Exit(exitErr.code)
+	}
 	ctx.FatalIfErrorf(err)
 }
 
-func (c *PlanCmd) Run(cli *CLI) error {
+func (c *PlanCmd) Run(ctx context.Context, cli *CLI) error {
 	if cli.Config == "" {
 		return fmt.Errorf("--config (-c) is required")
 	}
-	cfg, err := loadConfig(cli.Config)
+	if c.Parallelism < 1 {
+		return fmt.Errorf("--parallelism must be at least 1")
+	}
+	targets, err := parseTargets(c.Targets)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(cli)
+	if err != nil {
+		return err
+	}
+	policies, err := loadPolicies(cli)
 	if err != nil {
 		return err
 	}
@@ -88,21 +183,101 @@ func (c *PlanCmd) Run(cli *CLI) error {
 		return err
 	}
 
-	ctx := context.Background()
-	plan, err := p.CreatePlan(ctx, cfg)
+	warnUnfinishedApply(cli.Config)
+
+	plan, err := p.CreatePlan(ctx, cfg, provisioner.PlanOptions{Targets: targets, Parallelism: c.Parallelism, Prune: c.Prune})
 	if err != nil {
 		return fmt.Errorf("failed to create plan: %w", err)
 	}
 
-	printPlan(plan)
+	violations, err := policies.Evaluate(cfg, plan, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to evaluate policies: %w", err)
+	}
+
+	if cli.Output == outputJSON {
+		if err := writeJSON(newPlanDocument(plan, cfg.Variables, violations)); err != nil {
+			return err
+		}
+	} else {
+		printPlan(plan)
+		printCapacity(plan.Capacity)
+		printVariables(cfg.Variables)
+		printPolicyViolations(violations)
+	}
+	if err := checkPlanProblems(plan, violations); err != nil {
+		return err
+	}
+
+	if c.Out != "" {
+		if err := provisioner.SavePlan(c.Out, plan); err != nil {
+			return err
+		}
+		// Keep stdout machine-readable in JSON mode
+		out := os.Stdout
+		if cli.Output == outputJSON {
+			out = os.Stderr
+		}
+		fmt.Fprintf(out, "\nPlan saved to %s. To apply exactly this plan, run:\n  apprun-dedicated-provisioner apply -c %s %s\n", c.Out, cli.Config, c.Out)
+		if len(cfg.Variables) > 0 {
+			fmt.Fprintln(out, "with the same variables; the plan is rejected if they resolve to different values.")
+		}
+	}
+
+	if c.DetailedExitCode {
+		switch {
+		case plan.HasDestructiveChanges():
+			return &exitCodeError{code: exitCodePlanDestructive}
+		case plan.HasChanges():
+			return &exitCodeError{code: exitCodePlanChanges}
+		}
+	}
 	return nil
 }
 
-func (c *ApplyCmd) Run(cli *CLI) error {
+func (c *ApplyCmd) Run(ctx context.Context, cli *CLI) error {
 	if cli.Config == "" {
 		return fmt.Errorf("--config (-c) is required")
 	}
-	cfg, err := loadConfig(cli.Config)
+	if cli.Output == outputJSON {
+		return fmt.Errorf("--output json is not supported by apply; use 'plan --output json' to inspect changes")
+	}
+	if c.PlanFile != "" && len(c.Targets) > 0 {
+		return fmt.Errorf("--target cannot be used with a saved plan; pass --target to 'plan --out' instead")
+	}
+	if c.Resume && (c.PlanFile != "" || len(c.Targets) > 0) {
+		return fmt.Errorf("--resume cannot be used with a saved plan or --target; it continues the interrupted plan")
+	}
+	if c.AtomicActivate && !c.Activate && !c.Resume {
+		return fmt.Errorf("--atomic-activate requires --activate")
+	}
+	if c.Wait && !c.Activate && !c.Resume {
+		return fmt.Errorf("--wait requires --activate")
+	}
+	if c.WaitTimeout <= 0 {
+		return fmt.Errorf("--wait-timeout must be positive")
+	}
+	if c.Verify && !c.Activate && !c.Resume {
+		return fmt.Errorf("--verify requires --activate")
+	}
+	if c.VerifyWindow <= 0 {
+		return fmt.Errorf("--verify-window must be positive")
+	}
+	if c.VerifyMaxRestarts < 0 {
+		return fmt.Errorf("--verify-max-restarts must not be negative")
+	}
+	if c.Parallelism < 1 {
+		return fmt.Errorf("--parallelism must be at least 1")
+	}
+	targets, err := parseTargets(c.Targets)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(cli)
+	if err != nil {
+		return err
+	}
+	policies, err := loadPolicies(cli)
 	if err != nil {
 		return err
 	}
@@ -112,53 +287,71 @@ func (c *ApplyCmd) Run(cli *CLI) error {
 		return err
 	}
 
-	ctx := context.Background()
-
-	plan, err := p.CreatePlan(ctx, cfg)
+	// Hold the lock from planning until the apply completes,
+	// so that concurrent applies never plan against the same versions or overwrite each other's state
+	unlock, err := lockCluster(ctx, cfg, cli.Config, c.PlanFile, c.LockTimeout)
 	if err != nil {
-		return fmt.Errorf("failed to create plan: %w", err)
+		return err
 	}
+	defer unlock()
 
-	printPlan(plan)
-
-	hasChanges := false
-
-	// Check for ASG changes (skip doesn't count as a change)
-	for _, action := range plan.ASGActions {
-		if action.Action != provisioner.ASGActionNoop && action.Action != provisioner.ASGActionSkip {
-			hasChanges = true
-			break
+	if !c.Resume {
+		// Refuse early instead of after the confirmation prompt
+		journal, err := state.LoadJournal(cli.Config)
+		if err != nil {
+			return fmt.Errorf("failed to read apply journal: %w", err)
+		}
+		if journal != nil {
+			return fmt.Errorf("an interrupted apply was found in %s; run 'apply --resume' to continue it, or remove the file to discard it", state.GetJournalPath(cli.Config))
 		}
 	}
 
-	// Check for LB changes (skip doesn't count as a change)
-	for _, action := range plan.LBActions {
-		if action.Action != provisioner.LBActionNoop && action.Action != provisioner.LBActionSkip {
-			hasChanges = true
-			break
+	var plan *provisioner.Plan
+	if c.Resume {
+		// Continue the interrupted apply with the plan recorded in the journal
+		plan, err = loadResumePlan(p, cfg, cli.Config)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Resuming the interrupted apply recorded in %s\n\n", state.GetJournalPath(cli.Config))
+	} else if c.PlanFile != "" {
+		// Apply a saved plan as-is, refusing if anything changed since it was created
+		plan, err = loadSavedPlan(ctx, p, cfg, c.PlanFile)
+		if err != nil {
+			return err
+		}
+	} else {
+		plan, err = p.CreatePlan(ctx, cfg, provisioner.PlanOptions{Targets: targets, Parallelism: c.Parallelism, Prune: c.Prune})
+		if err != nil {
+			return fmt.Errorf("failed to create plan: %w", err)
 		}
 	}
 
-	// Check for Application changes
-	for _, action := range plan.Actions {
-		if action.Action != provisioner.ActionNoop {
-			hasChanges = true
-			break
-		}
+	violations, err := policies.Evaluate(cfg, plan, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to evaluate policies: %w", err)
 	}
 
-	if !hasChanges {
+	printPlan(plan)
+	printCapacity(plan.Capacity)
+	printVariables(cfg.Variables)
+	printPolicyViolations(violations)
+	if err := checkPlanProblems(plan, violations); err != nil {
+		return err
+	}
+
+	if !plan.HasChanges() {
 		fmt.Println("\nNo changes to apply.")
 		return nil
 	}
 
-	// Prompt for confirmation unless --auto-approve is set
-	if !c.AutoApprove {
+	// Prompt for confirmation unless --auto-approve is set.
+	// A saved plan has already been reviewed, so it is applied without prompting.
+	if !c.AutoApprove && c.PlanFile == "" {
 		fmt.Print("\nDo you want to apply these changes? [y/N]: ")
-		reader := bufio.NewReader(os.Stdin)
-		input, err := reader.ReadString('\n')
+		input, err := readInput(ctx)
 		if err != nil {
-			return fmt.Errorf("failed to read input: %w", err)
+			return err
 		}
 		input = strings.TrimSpace(strings.ToLower(input))
 		if input != "y" && input != "yes" {
@@ -167,12 +360,43 @@ func (c *ApplyCmd) Run(cli *CLI) error {
 		}
 	}
 
+	// Deleting applications always needs an explicit confirmation
+	if deleted := deletedApplications(plan); len(deleted) > 0 {
+		if c.AutoApprove || c.PlanFile != "" {
+			if !c.Prune {
+				return fmt.Errorf("the plan deletes applications (%s); pass --prune to confirm the deletion", strings.Join(deleted, ", "))
+			}
+		} else {
+			fmt.Printf("\nThe following applications will be DELETED: %s\n", strings.Join(deleted, ", "))
+			fmt.Print("Type 'delete' to confirm: ")
+			input, err := readInput(ctx)
+			if err != nil {
+				return err
+			}
+			if strings.TrimSpace(input) != "delete" {
+				fmt.Println("Apply canceled.")
+				return nil
+			}
+		}
+	}
+
 	fmt.Println("\nApplying changes...")
 
 	opts := provisioner.ApplyOptions{
-		Activate: c.Activate,
+		Activate:          c.Activate,
+		AtomicActivate:    c.AtomicActivate,
+		Parallelism:       c.Parallelism,
+		Resume:            c.Resume,
+		Wait:              c.Wait,
+		WaitTimeout:       c.WaitTimeout,
+		Verify:            c.Verify,
+		VerifyWindow:      c.VerifyWindow,
+		VerifyMaxRestarts: c.VerifyMaxRestarts,
 	}
 	if err := p.Apply(ctx, cfg, plan, opts); err != nil {
+		if journal, _ := state.LoadJournal(cli.Config); journal != nil {
+			return fmt.Errorf("failed to apply plan: %w\nProgress was recorded in %s; run 'apply --resume' to continue from the last completed step", err, state.GetJournalPath(cli.Config))
+		}
 		return fmt.Errorf("failed to apply plan: %w", err)
 	}
 
@@ -180,11 +404,11 @@ func (c *ApplyCmd) Run(cli *CLI) error {
 	return nil
 }
 
-func (c *VersionsCmd) Run(cli *CLI) error {
+func (c *VersionsCmd) Run(ctx context.Context, cli *CLI) error {
 	if cli.Config == "" {
 		return fmt.Errorf("--config (-c) is required")
 	}
-	cfg, err := loadConfig(cli.Config)
+	cfg, err := loadConfig(cli)
 	if err != nil {
 		return err
 	}
@@ -194,21 +418,24 @@ func (c *VersionsCmd) Run(cli *CLI) error {
 		return err
 	}
 
-	ctx := context.Background()
 	result, err := p.ListVersions(ctx, cfg.ClusterName, c.App)
 	if err != nil {
 		return fmt.Errorf("failed to list versions: %w", err)
 	}
 
+	if cli.Output == outputJSON {
+		return writeJSON(newVersionListDocument(result))
+	}
+
 	printVersionList(result)
 	return nil
 }
 
-func (c *DiffCmd) Run(cli *CLI) error {
+func (c *DiffCmd) Run(ctx context.Context, cli *CLI) error {
 	if cli.Config == "" {
 		return fmt.Errorf("--config (-c) is required")
 	}
-	cfg, err := loadConfig(cli.Config)
+	cfg, err := loadConfig(cli)
 	if err != nil {
 		return err
 	}
@@ -218,21 +445,61 @@ func (c *DiffCmd) Run(cli *CLI) error {
 		return err
 	}
 
-	ctx := context.Background()
 	diff, err := p.GetVersionDiff(ctx, cfg.ClusterName, c.App, c.From, c.To)
 	if err != nil {
 		return fmt.Errorf("failed to get version diff: %w", err)
 	}
 
+	if cli.Output == outputJSON {
+		return writeJSON(newVersionDiffDocument(c.App, diff))
+	}
+
 	printVersionDiff(c.App, diff)
 	return nil
 }
 
-func (c *ActivateCmd) Run(cli *CLI) error {
+func (c *DriftCmd) Run(ctx context.Context, cli *CLI) error {
+	if cli.Config == "" {
+		return fmt.Errorf("--config (-c) is required")
+	}
+	if c.Parallelism < 1 {
+		return fmt.Errorf("--parallelism must be at least 1")
+	}
+	cfg, err := loadConfig(cli)
+	if err != nil {
+		return err
+	}
+
+	// The state file is needed to compare secret versions in the same way as plan
+	p, err := createProvisioner(cli.Config)
+	if err != nil {
+		return err
+	}
+
+	report, err := p.DetectDrift(ctx, cfg, provisioner.DriftOptions{Parallelism: c.Parallelism})
+	if err != nil {
+		return fmt.Errorf("failed to detect drift: %w", err)
+	}
+
+	if cli.Output == outputJSON {
+		if err := writeJSON(newDriftDocument(report)); err != nil {
+			return err
+		}
+	} else {
+		printDriftReport(report)
+	}
+
+	if c.ExitCode && report.HasDrift() {
+		return &exitCodeError{code: exitCodeDrift}
+	}
+	return nil
+}
+
+func (c *ActivateCmd) Run(ctx context.Context, cli *CLI) error {
 	if cli.Config == "" {
 		return fmt.Errorf("--config (-c) is required")
 	}
-	cfg, err := loadConfig(cli.Config)
+	cfg, err := loadConfig(cli)
 	if err != nil {
 		return err
 	}
@@ -242,28 +509,69 @@ func (c *ActivateCmd) Run(cli *CLI) error {
 		return err
 	}
 
-	ctx := context.Background()
 	activatedVersion, err := p.ActivateVersion(ctx, cfg.ClusterName, c.App, c.TargetVersion)
 	if err != nil {
 		return fmt.Errorf("failed to activate version: %w", err)
 	}
 
+	if cli.Output == outputJSON {
+		return writeJSON(&activateDocument{
+			FormatVersion:    jsonFormatVersion,
+			Application:      applicationDocument{Name: c.App},
+			ActivatedVersion: activatedVersion,
+		})
+	}
+
 	fmt.Printf("Successfully activated version %d for application %q\n", activatedVersion, c.App)
 	return nil
 }
 
-func (c *DumpCmd) Run(cli *CLI) error {
+func (c *ForceUnlockCmd) Run(ctx context.Context, cli *CLI) error {
+	if cli.Config == "" {
+		return fmt.Errorf("--config (-c) is required")
+	}
+	cfg, err := loadConfig(cli)
+	if err != nil {
+		return err
+	}
+
+	if !c.AutoApprove {
+		fmt.Printf("Releasing lock %s lets another apply run on cluster %s.\n", c.LockID, cfg.ClusterName)
+		fmt.Print("Make sure the apply holding it is no longer running. Do you want to release the lock? [y/N]: ")
+		input, err := readInput(ctx)
+		if err != nil {
+			return err
+		}
+		input = strings.TrimSpace(strings.ToLower(input))
+		if input != "y" && input != "yes" {
+			fmt.Println("Force-unlock canceled.")
+			return nil
+		}
+	}
+
+	if err := newLocker(cfg, cli.Config).Unlock(ctx, c.LockID); err != nil {
+		return fmt.Errorf("failed to release the lock: %w", err)
+	}
+
+	fmt.Printf("Released lock %s.\n", c.LockID)
+	return nil
+}
+
+func (c *DumpCmd) Run(ctx context.Context, cli *CLI) error {
 	p, err := createProvisionerSimple()
 	if err != nil {
 		return err
 	}
 
-	ctx := context.Background()
 	clusterConfig, err := p.DumpClusterConfig(ctx, c.ClusterName)
 	if err != nil {
 		return fmt.Errorf("failed to dump cluster config: %w", err)
 	}
 
+	if cli.Output == outputJSON {
+		return writeJSON(newDumpDocument(clusterConfig))
+	}
+
 	// Output as YAML
 	yamlOutput, err := clusterConfig.ToYAML()
 	if err != nil {
@@ -274,6 +582,40 @@ func (c *DumpCmd) Run(cli *CLI) error {
 	return nil
 }
 
+func (c *RenderCmd) Run(cli *CLI) error {
+	if cli.Config == "" {
+		return fmt.Errorf("--config (-c) is required")
+	}
+	cfg, err := loadConfig(cli)
+	if err != nil {
+		return err
+	}
+
+	variables := cfg.Variables
+	if c.ShowSecrets {
+		variables = slices.Clone(variables)
+		for i := range variables {
+			variables[i].Secret = false
+		}
+	} else {
+		cfg = redactConfig(cfg)
+	}
+	if cli.Output == outputJSON {
+		return writeJSON(&dumpDocument{FormatVersion: jsonFormatVersion, Config: cfg, Variables: newVariableDocuments(variables)})
+	}
+
+	yamlOutput, err := cfg.ToYAML()
+	if err != nil {
+		return fmt.Errorf("failed to serialize config: %w", err)
+	}
+	// List the variables as comments, keeping the output valid YAML
+	for _, v := range variables {
+		fmt.Printf("# %s\n", formatVariable(v))
+	}
+	fmt.Print(yamlOutput)
+	return nil
+}
+
 func createProvisioner(configPath string) (*provisioner.Provisioner, error) {
 	accessToken := getEnvWithFallback("SAKURA_ACCESS_TOKEN", "SAKURACLOUD_ACCESS_TOKEN")
 	accessTokenSecret := getEnvWithFallback("SAKURA_ACCESS_TOKEN_SECRET", "SAKURACLOUD_ACCESS_TOKEN_SECRET")
@@ -299,8 +641,161 @@ func createProvisioner(configPath string) (*provisioner.Provisioner, error) {
 	return provisioner.NewProvisioner(client, st, configPath), nil
 }
 
-func loadConfig(path string) (*config.ClusterConfig, error) {
-	cfg, err := config.Load(path)
+// loadResumePlan loads the plan of the interrupted apply and checks that the config is unchanged
+func loadResumePlan(p *provisioner.Provisioner, cfg *config.ClusterConfig, configPath string) (*provisioner.Plan, error) {
+	plan, err := p.ResumePlan()
+	if err != nil {
+		return nil, err
+	}
+
+	configHash, err := cfg.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash config: %w", err)
+	}
+	if plan.ConfigHash != configHash {
+		return nil, fmt.Errorf("config has changed since the interrupted apply; restore it to resume, or remove %s to discard the interrupted apply", state.GetJournalPath(configPath))
+	}
+
+	return plan, nil
+}
+
+// warnUnfinishedApply warns if an interrupted apply has not been resumed
+func warnUnfinishedApply(configPath string) {
+	journal, err := state.LoadJournal(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: failed to read apply journal: %v\n", err)
+		return
+	}
+	if journal != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: An interrupted apply started at %s has not completed (%d steps completed).\n", journal.StartedAt.Local().Format("2006-01-02 15:04:05"), journal.CompletedSteps())
+		fmt.Fprintf(os.Stderr, "         The cluster may be partially changed. Run 'apply --resume' to continue it, or remove %s to discard it.\n\n", state.GetJournalPath(configPath))
+	}
+}
+
+// loadSavedPlan loads a saved plan and verifies that neither the config nor the cluster changed since
+func loadSavedPlan(ctx context.Context, p *provisioner.Provisioner, cfg *config.ClusterConfig, path string) (*provisioner.Plan, error) {
+	plan, err := provisioner.LoadPlan(path)
+	if err != nil {
+		return nil, err
+	}
+
+	configHash, err := cfg.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash config: %w", err)
+	}
+	if plan.ConfigHash != configHash {
+		return nil, fmt.Errorf("config has changed since the plan %s was created; run plan again", path)
+	}
+
+	if err := p.VerifyPlan(ctx, plan); err != nil {
+		return nil, fmt.Errorf("saved plan %s is stale, run plan again: %w", path, err)
+	}
+
+	return plan, nil
+}
+
+// parseTargets parses --target selectors
+func parseTargets(selectors []string) ([]provisioner.Target, error) {
+	var targets []provisioner.Target
+	for _, selector := range selectors {
+		target, err := provisioner.ParseTarget(selector)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// checkPlanProblems returns an error if the plan violates a policy or the applications do not fit in the ASGs
+func checkPlanProblems(plan *provisioner.Plan, violations []provisioner.PolicyViolation) error {
+	var errs []error
+	if provisioner.HasPolicyErrors(violations) {
+		errs = append(errs, errors.New("the plan violates policies with error severity"))
+	}
+	if plan.Capacity.HasErrors() {
+		errs = append(errs, errors.New("the applications do not fit in the capacity of the ASGs"))
+	}
+	return errors.Join(errs...)
+}
+
+// loadPolicies loads the policy directory given by --policies, or policies/ in the config directory.
+// It returns nil if the default directory does not exist.
+func loadPolicies(cli *CLI) (*provisioner.PolicySet, error) {
+	dir := cli.Policies
+	if dir == "" {
+		dir = filepath.Join(config.Dir(cli.Config), config.PoliciesDir)
+		if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+	}
+
+	policies, err := provisioner.LoadPolicies(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policies: %w", err)
+	}
+	return policies, nil
+}
+
+// newLocker creates the backend of the apply lock configured in the config
+func newLocker(cfg *config.ClusterConfig, configPath string) state.Locker {
+	if cfg.LockBackend() == config.LockBackendHTTP {
+		return state.NewHTTPLocker(cfg.Lock.Address, os.Getenv("APPRUN_LOCK_USERNAME"), os.Getenv("APPRUN_LOCK_PASSWORD"))
+	}
+	return state.NewLocalLocker(configPath)
+}
+
+// lockCluster acquires the apply lock of the cluster and returns the function that releases it
+func lockCluster(ctx context.Context, cfg *config.ClusterConfig, configPath, planFile string, timeout time.Duration) (func(), error) {
+	locker := newLocker(cfg, configPath)
+	info := state.NewLockInfo("apply", cfg.ClusterName)
+	info.PlanFile = planFile
+	if hash, err := cfg.Hash(); err == nil {
+		info.ConfigHash = hash
+	}
+
+	if err := state.AcquireLock(ctx, locker, info, timeout); err != nil {
+		var locked *state.LockedError
+		if errors.As(err, &locked) && locked.Info != nil {
+			return nil, fmt.Errorf("failed to acquire the apply lock: %w\nIf that apply is no longer running, run 'force-unlock %s' to release the lock", err, locked.Info.ID)
+		}
+		return nil, fmt.Errorf("failed to acquire the apply lock: %w", err)
+	}
+
+	return func() {
+		// Release the lock even if the command was interrupted
+		if err := locker.Unlock(context.Background(), info.ID); err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: failed to release the apply lock: %v\n", err)
+			fmt.Fprintf(os.Stderr, "         Run 'force-unlock %s' to release it.\n", info.ID)
+		}
+	}, nil
+}
+
+// readInput reads a line of the answer to a prompt, returning early if the command is interrupted
+func readInput(ctx context.Context) (string, error) {
+	type result struct {
+		input string
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		done <- result{input, err}
+	}()
+	select {
+	case <-ctx.Done():
+		fmt.Println()
+		return "", ctx.Err()
+	case r := <-done:
+		if r.err != nil {
+			return "", fmt.Errorf("failed to read input: %w", r.err)
+		}
+		return r.input, nil
+	}
+}
+
+func loadConfig(cli *CLI) (*config.ClusterConfig, error) {
+	cfg, err := config.Load(cli.Config, config.LoadOptions{Vars: cli.Vars, VarFiles: cli.VarFiles})
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
@@ -310,6 +805,13 @@ func loadConfig(path string) (*config.ClusterConfig, error) {
 func printPlan(plan *provisioner.Plan) {
 	fmt.Printf("Cluster: %s (%s)\n\n", plan.ClusterName, plan.ClusterID)
 
+	if plan.IsTargeted() {
+		fmt.Printf("Targets: %s\n", formatTargets(plan.Targets))
+		fmt.Println("WARNING: Only the targeted resources and their dependencies were planned.")
+		fmt.Println("         The rest of the config was not checked against the cluster.")
+		fmt.Println()
+	}
+
 	// Print ASG changes
 	asgHasChanges := false
 	for _, action := range plan.ASGActions {
@@ -324,16 +826,13 @@ func printPlan(plan *provisioner.Plan) {
 			switch action.Action {
 			case provisioner.ASGActionCreate:
 				fmt.Printf("+ %s (create)\n", action.Name)
-				for _, change := range action.Changes {
-					fmt.Printf("    %s\n", change)
-				}
+				printCreatedConfig(action.Changes)
 			case provisioner.ASGActionDelete:
 				fmt.Printf("- %s (delete)\n", action.Name)
+				printChanges(action.Reason, nil)
 			case provisioner.ASGActionRecreate:
 				fmt.Printf("~ %s (recreate - settings changed)\n", action.Name)
-				for _, change := range action.Changes {
-					fmt.Printf("    %s\n", change)
-				}
+				printChanges(action.Reason, action.Changes)
 			case provisioner.ASGActionSkip:
 				fmt.Printf("  %s (not in YAML, skipping)\n", action.Name)
 			case provisioner.ASGActionNoop:
@@ -357,15 +856,19 @@ func printPlan(plan *provisioner.Plan) {
 			switch action.Action {
 			case provisioner.LBActionCreate:
 				fmt.Printf("+ %s (create, ASG: %s)\n", action.Name, action.ASGName)
-				for _, change := range action.Changes {
-					fmt.Printf("    %s\n", change)
-				}
+				printCreatedConfig(action.Changes)
 			case provisioner.LBActionDelete:
 				fmt.Printf("- %s (delete, ASG: %s)\n", action.Name, action.ASGName)
+				printChanges(action.Reason, nil)
 			case provisioner.LBActionRecreate:
-				fmt.Printf("~ %s (recreate, ASG: %s - settings changed)\n", action.Name, action.ASGName)
-				for _, change := range action.Changes {
-					fmt.Printf("    %s\n", change)
+				if action.CreateBeforeDestroy {
+					fmt.Printf("~ %s (recreate, ASG: %s - create before destroy)\n", action.Name, action.ASGName)
+				} else {
+					fmt.Printf("~ %s (recreate, ASG: %s - settings changed)\n", action.Name, action.ASGName)
+				}
+				printChanges(action.Reason, action.Changes)
+				if action.CreateName != "" {
+					fmt.Printf("    the replacement is created as %s and renamed back by the next apply\n", action.CreateName)
 				}
 			case provisioner.LBActionSkip:
 				fmt.Printf("  %s (not in YAML, skipping, ASG: %s)\n", action.Name, action.ASGName)
@@ -390,6 +893,7 @@ func printPlan(plan *provisioner.Plan) {
 
 	createCount := 0
 	updateCount := 0
+	deleteCount := 0
 	noopCount := 0
 
 	for _, action := range plan.Actions {
@@ -397,15 +901,19 @@ func printPlan(plan *provisioner.Plan) {
 		case provisioner.ActionCreate:
 			createCount++
 			fmt.Printf("+ %s (create)\n", action.ApplicationName)
-			for _, change := range action.Changes {
-				fmt.Printf("    %s\n", change)
-			}
+			printChanges(action.Reason, action.Changes)
 		case provisioner.ActionUpdate:
 			updateCount++
-			fmt.Printf("~ %s (update)\n", action.ApplicationName)
-			for _, change := range action.Changes {
-				fmt.Printf("    %s\n", change)
+			if action.BaseVersion != action.LatestVersion {
+				fmt.Printf("~ %s (update, based on active version %d)\n", action.ApplicationName, action.BaseVersion)
+			} else {
+				fmt.Printf("~ %s (update)\n", action.ApplicationName)
 			}
+			printChanges(action.Reason, action.Changes)
+		case provisioner.ActionDelete:
+			deleteCount++
+			fmt.Printf("- %s (delete)\n", action.ApplicationName)
+			printChanges(action.Reason, action.Changes)
 		case provisioner.ActionNoop:
 			noopCount++
 			fmt.Printf("  %s (no changes)\n", action.ApplicationName)
@@ -444,7 +952,69 @@ func printPlan(plan *provisioner.Plan) {
 	if lbCreateCount+lbDeleteCount+lbRecreateCount > 0 {
 		fmt.Printf("  LB: %d to create, %d to delete, %d to recreate\n", lbCreateCount, lbDeleteCount, lbRecreateCount)
 	}
-	fmt.Printf("  Applications: %d to create, %d to update, %d unchanged\n", createCount, updateCount, noopCount)
+	if deleteCount > 0 {
+		fmt.Printf("  Applications: %d to create, %d to update, %d to delete, %d unchanged\n", createCount, updateCount, deleteCount, noopCount)
+	} else {
+		fmt.Printf("  Applications: %d to create, %d to update, %d unchanged\n", createCount, updateCount, noopCount)
+	}
+}
+
+func printCapacity(report *provisioner.CapacityReport) {
+	if report == nil {
+		return
+	}
+
+	fmt.Println("\n=== Capacity ===")
+	for _, asg := range report.ASGs {
+		if asg.Node == (provisioner.Resources{}) {
+			fmt.Printf("  ASG %s: %d-%d nodes of %s (unknown size)\n", asg.Name, asg.MinNodes, asg.MaxNodes, asg.WorkerServiceClassPath)
+			continue
+		}
+		fmt.Printf("  ASG %s: %d-%d nodes of %d mCPU, %d MB\n", asg.Name, asg.MinNodes, asg.MaxNodes, asg.Node.CPU, asg.Node.Memory)
+	}
+	fmt.Printf("  CPU:    demand %d-%d mCPU, supply %d-%d mCPU\n", report.MinDemand.CPU, report.MaxDemand.CPU, report.MinSupply.CPU, report.MaxSupply.CPU)
+	fmt.Printf("  Memory: demand %d-%d MB, supply %d-%d MB\n", report.MinDemand.Memory, report.MaxDemand.Memory, report.MinSupply.Memory, report.MaxSupply.Memory)
+	for _, problem := range report.Problems {
+		fmt.Printf("[%s] %s\n", problem.Severity, problem.Message)
+	}
+}
+
+func printVariables(variables []config.Variable) {
+	if len(variables) == 0 {
+		return
+	}
+
+	fmt.Println("\n=== Variables ===")
+	for _, v := range variables {
+		fmt.Printf("  %s\n", formatVariable(v))
+	}
+}
+
+// formatVariable returns the variable as "NAME = value (source)", with a secret value redacted
+func formatVariable(v config.Variable) string {
+	return fmt.Sprintf("%s = %s (%s)", v.Name, variableValue(v), v.Source)
+}
+
+func printPolicyViolations(violations []provisioner.PolicyViolation) {
+	if len(violations) == 0 {
+		return
+	}
+
+	fmt.Println("\n=== Policy Violations ===")
+	for _, v := range violations {
+		fmt.Printf("%s (%s)\n", v, v.File)
+	}
+}
+
+// deletedApplications returns the names of applications the plan deletes
+func deletedApplications(plan *provisioner.Plan) []string {
+	var names []string
+	for _, action := range plan.Actions {
+		if action.Action == provisioner.ActionDelete {
+			names = append(names, action.ApplicationName)
+		}
+	}
+	return names
 }
 
 // getEnvWithFallback returns the value of the first environment variable that is set
@@ -519,6 +1089,23 @@ func printVersionList(list *provisioner.VersionList) {
 	}
 }
 
+// printChanges prints the reason of an action followed by its field changes
+func printChanges(reason string, changes []provisioner.FieldChange) {
+	if reason != "" {
+		fmt.Printf("    %s\n", reason)
+	}
+	for _, change := range changes {
+		fmt.Printf("    %s\n", change)
+	}
+}
+
+// printCreatedConfig prints the configuration of a resource to be created
+func printCreatedConfig(changes []provisioner.FieldChange) {
+	for _, change := range changes {
+		fmt.Printf("    %s: %s\n", change.Path, change.New)
+	}
+}
+
 func printVersionDiff(appName string, diff *provisioner.VersionDiff) {
 	fmt.Printf("Application: %s\n", appName)
 	fmt.Printf("Comparing version %d → %d\n\n", diff.FromVersion, diff.ToVersion)
@@ -538,6 +1125,70 @@ func printVersionDiff(appName string, diff *provisioner.VersionDiff) {
 	}
 }
 
+func printDriftReport(report *provisioner.DriftReport) {
+	fmt.Printf("Cluster: %s (%s)\n\n", report.ClusterName, report.ClusterID)
+
+	driftCount := 0
+	for i := range report.Applications {
+		drift := &report.Applications[i]
+		if !drift.HasDrift() {
+			fmt.Printf("  %s (no drift, version %s)\n", drift.ApplicationName, formatVersionNumber(drift.ActiveVersion))
+			continue
+		}
+		driftCount++
+		if drift.Missing {
+			fmt.Printf("! %s (not found in cluster)\n", drift.ApplicationName)
+			continue
+		}
+
+		fmt.Printf("! %s (active: %s, latest: %s)\n", drift.ApplicationName, formatVersionNumber(drift.ActiveVersion), formatVersionNumber(drift.LatestVersion))
+		printDriftChanges("Config vs active", drift.ActiveVersion, drift.ConfigVsActive)
+		printDriftChanges("Config vs latest", drift.LatestVersion, drift.ConfigVsLatest)
+		if drift.ActiveVersion != 0 && drift.LatestVersion != 0 && drift.ActiveVersion != drift.LatestVersion {
+			fmt.Printf("  Active vs latest (%d → %d):\n", drift.ActiveVersion, drift.LatestVersion)
+			printDriftChangeLines(drift.ActiveVsLatest)
+		}
+	}
+
+	fmt.Printf("\nDrift Summary: %d of %d application(s) drifted\n", driftCount, len(report.Applications))
+}
+
+// printDriftChanges prints the changes between the config and a version
+func printDriftChanges(title string, version int, changes []provisioner.FieldChange) {
+	if version == 0 {
+		fmt.Printf("  %s: (no version)\n", title)
+		return
+	}
+	fmt.Printf("  %s (version %d):\n", title, version)
+	printDriftChangeLines(changes)
+}
+
+func printDriftChangeLines(changes []provisioner.FieldChange) {
+	if len(changes) == 0 {
+		fmt.Println("    (no differences)")
+		return
+	}
+	for _, change := range changes {
+		fmt.Printf("    %s\n", change)
+	}
+}
+
+// formatVersionNumber formats a version number, where 0 means no version
+func formatVersionNumber(version int) string {
+	if version == 0 {
+		return "none"
+	}
+	return fmt.Sprint(version)
+}
+
+func formatTargets(targets []provisioner.Target) string {
+	selectors := make([]string, 0, len(targets))
+	for _, target := range targets {
+		selectors = append(selectors, target.String())
+	}
+	return strings.Join(selectors, ", ")
+}
+
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/provisioner"
+)
+
+// jsonFormatVersion is the version of the JSON documents emitted with --output json.
+// Increment it when making incompatible changes to the documents below.
+const jsonFormatVersion = 1
+
+// outputJSON is the --output value for machine-readable JSON output
+const outputJSON = "json"
+
+// redactedValue replaces secret values in JSON output
+const redactedValue = "(redacted)"
+
+// planDocument is the JSON representation of a plan
+type planDocument struct {
+	FormatVersion int             `json:"formatVersion"`
+	Cluster       clusterDocument `json:"cluster"`
+	// Variables lists the variables the config was loaded with
+	Variables []variableDocument `json:"variables"`
+	// Targets lists the --target selectors the plan is limited to (empty for a full plan)
+	Targets           []string            `json:"targets"`
+	AutoScalingGroups []asgActionDocument `json:"autoScalingGroups"`
+	LoadBalancers     []lbActionDocument  `json:"loadBalancers"`
+	Applications      []appActionDocument `json:"applications"`
+	HasChanges        bool                `json:"hasChanges"`
+	// HasDestructiveChanges is true if the plan recreates or deletes any resource
+	HasDestructiveChanges bool                      `json:"hasDestructiveChanges"`
+	Summary               planSummaryDocument       `json:"summary"`
+	PolicyViolations      []policyViolationDocument `json:"policyViolations"`
+	// Capacity is null if the cluster has no ASGs
+	Capacity *capacityDocument `json:"capacity"`
+}
+
+type capacityDocument struct {
+	MinDemand resourcesDocument         `json:"minDemand"`
+	MaxDemand resourcesDocument         `json:"maxDemand"`
+	MinSupply resourcesDocument         `json:"minSupply"`
+	MaxSupply resourcesDocument         `json:"maxSupply"`
+	Problems  []capacityProblemDocument `json:"problems"`
+}
+
+// resourcesDocument is an amount of CPU (mCPU) and memory (MB)
+type resourcesDocument struct {
+	CPU    int64 `json:"cpu"`
+	Memory int64 `json:"memory"`
+}
+
+type capacityProblemDocument struct {
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// variableDocument is a variable referenced by the config; the value of a secret variable is redacted
+type variableDocument struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	Secret bool   `json:"secret"`
+}
+
+type policyViolationDocument struct {
+	Rule     string `json:"rule"`
+	File     string `json:"file"`
+	Severity string `json:"severity"`
+	Resource string `json:"resource"`
+	Message  string `json:"message"`
+}
+
+type clusterDocument struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+type asgActionDocument struct {
+	Name    string           `json:"name"`
+	Action  string           `json:"action"`
+	Reason  string           `json:"reason,omitempty"`
+	Changes []changeDocument `json:"changes"`
+}
+
+type lbActionDocument struct {
+	Name             string           `json:"name"`
+	AutoScalingGroup string           `json:"autoScalingGroup"`
+	Action           string           `json:"action"`
+	Reason           string           `json:"reason,omitempty"`
+	Changes          []changeDocument `json:"changes"`
+}
+
+type appActionDocument struct {
+	Name    string           `json:"name"`
+	Action  string           `json:"action"`
+	Reason  string           `json:"reason,omitempty"`
+	Changes []changeDocument `json:"changes"`
+}
+
+// changeDocument is the JSON representation of a field change.
+// For sensitive changes, old and new hold secret versions instead of values.
+type changeDocument struct {
+	Resource    string `json:"resource"`
+	Path        string `json:"path"`
+	Kind        string `json:"kind"`
+	Old         string `json:"old,omitempty"`
+	New         string `json:"new,omitempty"`
+	Sensitive   bool   `json:"sensitive"`
+	Description string `json:"description"`
+}
+
+// planSummaryDocument counts actions by resource type and action type
+type planSummaryDocument struct {
+	AutoScalingGroups map[string]int `json:"autoScalingGroups"`
+	LoadBalancers     map[string]int `json:"loadBalancers"`
+	Applications      map[string]int `json:"applications"`
+}
+
+// versionListDocument is the JSON representation of a version list
+type versionListDocument struct {
+	FormatVersion int                   `json:"formatVersion"`
+	Application   applicationDocument   `json:"application"`
+	Versions      []versionInfoDocument `json:"versions"`
+	ActiveVersion *int                  `json:"activeVersion"`
+	LatestVersion *int                  `json:"latestVersion"`
+}
+
+type applicationDocument struct {
+	Name string `json:"name"`
+	ID   string `json:"id,omitempty"`
+}
+
+type versionInfoDocument struct {
+	Version     int       `json:"version"`
+	Image       string    `json:"image"`
+	Created     time.Time `json:"created"`
+	ActiveNodes int64     `json:"activeNodes"`
+	Active      bool      `json:"active"`
+}
+
+// versionDiffDocument is the JSON representation of a version diff
+type versionDiffDocument struct {
+	FormatVersion int                 `json:"formatVersion"`
+	Application   applicationDocument `json:"application"`
+	FromVersion   int                 `json:"fromVersion"`
+	ToVersion     int                 `json:"toVersion"`
+	Changes       []changeDocument    `json:"changes"`
+	// Incomparable lists fields whose values are not returned by the API
+	Incomparable incomparableDocument `json:"incomparable"`
+}
+
+type incomparableDocument struct {
+	SecretEnv        bool `json:"secretEnv"`
+	RegistryPassword bool `json:"registryPassword"`
+}
+
+// driftDocument is the JSON representation of a drift report
+type driftDocument struct {
+	FormatVersion int                `json:"formatVersion"`
+	Cluster       clusterDocument    `json:"cluster"`
+	Applications  []appDriftDocument `json:"applications"`
+	HasDrift      bool               `json:"hasDrift"`
+}
+
+type appDriftDocument struct {
+	Name string `json:"name"`
+	// Missing is true if the application does not exist in the cluster
+	Missing        bool             `json:"missing"`
+	ActiveVersion  *int             `json:"activeVersion"`
+	LatestVersion  *int             `json:"latestVersion"`
+	HasDrift       bool             `json:"hasDrift"`
+	ConfigVsActive []changeDocument `json:"configVsActive"`
+	ConfigVsLatest []changeDocument `json:"configVsLatest"`
+	ActiveVsLatest []changeDocument `json:"activeVsLatest"`
+}
+
+// dumpDocument is the JSON representation of a dumped or rendered cluster configuration
+type dumpDocument struct {
+	FormatVersion int                   `json:"formatVersion"`
+	Config        *config.ClusterConfig `json:"config"`
+	// Variables lists the variables a rendered config was loaded with
+	Variables []variableDocument `json:"variables,omitempty"`
+}
+
+// activateDocument is the JSON representation of an activation result
+type activateDocument struct {
+	FormatVersion    int                 `json:"formatVersion"`
+	Application      applicationDocument `json:"application"`
+	ActivatedVersion int                 `json:"activatedVersion"`
+}
+
+// writeJSON writes v to stdout as indented JSON
+func writeJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func newPlanDocument(plan *provisioner.Plan, variables []config.Variable, violations []provisioner.PolicyViolation) *planDocument {
+	doc := &planDocument{
+		FormatVersion:         jsonFormatVersion,
+		Cluster:               clusterDocument{Name: plan.ClusterName, ID: plan.ClusterID.String()},
+		Variables:             newVariableDocuments(variables),
+		Targets:               []string{},
+		AutoScalingGroups:     []asgActionDocument{},
+		LoadBalancers:         []lbActionDocument{},
+		Applications:          []appActionDocument{},
+		PolicyViolations:      []policyViolationDocument{},
+		HasChanges:            plan.HasChanges(),
+		HasDestructiveChanges: plan.HasDestructiveChanges(),
+		Summary: planSummaryDocument{
+			AutoScalingGroups: map[string]int{},
+			LoadBalancers:     map[string]int{},
+			Applications:      map[string]int{},
+		},
+	}
+
+	for _, target := range plan.Targets {
+		doc.Targets = append(doc.Targets, target.String())
+	}
+
+	for _, action := range plan.ASGActions {
+		doc.AutoScalingGroups = append(doc.AutoScalingGroups, asgActionDocument{
+			Name:    action.Name,
+			Action:  string(action.Action),
+			Reason:  action.Reason,
+			Changes: newChangeDocuments(action.Changes),
+		})
+		doc.Summary.AutoScalingGroups[string(action.Action)]++
+	}
+
+	for _, action := range plan.LBActions {
+		doc.LoadBalancers = append(doc.LoadBalancers, lbActionDocument{
+			Name:             action.Name,
+			AutoScalingGroup: action.ASGName,
+			Action:           string(action.Action),
+			Reason:           action.Reason,
+			Changes:          newChangeDocuments(action.Changes),
+		})
+		doc.Summary.LoadBalancers[string(action.Action)]++
+	}
+
+	for _, action := range plan.Actions {
+		doc.Applications = append(doc.Applications, appActionDocument{
+			Name:    action.ApplicationName,
+			Action:  string(action.Action),
+			Reason:  action.Reason,
+			Changes: newChangeDocuments(action.Changes),
+		})
+		doc.Summary.Applications[string(action.Action)]++
+	}
+
+	if report := plan.Capacity; report != nil {
+		doc.Capacity = &capacityDocument{
+			MinDemand: resourcesDocument(report.MinDemand),
+			MaxDemand: resourcesDocument(report.MaxDemand),
+			MinSupply: resourcesDocument(report.MinSupply),
+			MaxSupply: resourcesDocument(report.MaxSupply),
+			Problems:  []capacityProblemDocument{},
+		}
+		for _, problem := range report.Problems {
+			doc.Capacity.Problems = append(doc.Capacity.Problems, capacityProblemDocument{
+				Severity: string(problem.Severity),
+				Message:  problem.Message,
+			})
+		}
+	}
+
+	for _, v := range violations {
+		doc.PolicyViolations = append(doc.PolicyViolations, policyViolationDocument{
+			Rule:     v.Rule,
+			File:     v.File,
+			Severity: string(v.Severity),
+			Resource: v.Resource,
+			Message:  v.Message,
+		})
+	}
+
+	return doc
+}
+
+func newVersionListDocument(list *provisioner.VersionList) *versionListDocument {
+	doc := &versionListDocument{
+		FormatVersion: jsonFormatVersion,
+		Application:   applicationDocument{Name: list.ApplicationName, ID: list.ApplicationID},
+		Versions:      []versionInfoDocument{},
+	}
+
+	// Newest first, same as the text output
+	for i := len(list.Versions) - 1; i >= 0; i-- {
+		v := list.Versions[i]
+		doc.Versions = append(doc.Versions, versionInfoDocument{
+			Version:     v.Version,
+			Image:       v.Image,
+			Created:     v.Created.UTC(),
+			ActiveNodes: v.ActiveNodes,
+			Active:      v.IsActive,
+		})
+	}
+
+	if list.ActiveVersion > 0 {
+		activeVersion := list.ActiveVersion
+		doc.ActiveVersion = &activeVersion
+	}
+	if list.LatestVersion > 0 {
+		latestVersion := list.LatestVersion
+		doc.LatestVersion = &latestVersion
+	}
+
+	return doc
+}
+
+func newVersionDiffDocument(appName string, diff *provisioner.VersionDiff) *versionDiffDocument {
+	return &versionDiffDocument{
+		FormatVersion: jsonFormatVersion,
+		Application:   applicationDocument{Name: appName},
+		FromVersion:   diff.FromVersion,
+		ToVersion:     diff.ToVersion,
+		Changes:       newChangeDocuments(diff.Changes),
+		Incomparable: incomparableDocument{
+			SecretEnv:        diff.HasSecretEnv,
+			RegistryPassword: diff.HasRegistryPwd,
+		},
+	}
+}
+
+func newDriftDocument(report *provisioner.DriftReport) *driftDocument {
+	doc := &driftDocument{
+		FormatVersion: jsonFormatVersion,
+		Cluster:       clusterDocument{Name: report.ClusterName, ID: report.ClusterID.String()},
+		Applications:  []appDriftDocument{},
+		HasDrift:      report.HasDrift(),
+	}
+
+	for i := range report.Applications {
+		drift := &report.Applications[i]
+		appDoc := appDriftDocument{
+			Name:           drift.ApplicationName,
+			Missing:        drift.Missing,
+			HasDrift:       drift.HasDrift(),
+			ConfigVsActive: newChangeDocuments(drift.ConfigVsActive),
+			ConfigVsLatest: newChangeDocuments(drift.ConfigVsLatest),
+			ActiveVsLatest: newChangeDocuments(drift.ActiveVsLatest),
+		}
+		if drift.ActiveVersion > 0 {
+			activeVersion := drift.ActiveVersion
+			appDoc.ActiveVersion = &activeVersion
+		}
+		if drift.LatestVersion > 0 {
+			latestVersion := drift.LatestVersion
+			appDoc.LatestVersion = &latestVersion
+		}
+		doc.Applications = append(doc.Applications, appDoc)
+	}
+
+	return doc
+}
+
+func newDumpDocument(cfg *config.ClusterConfig) *dumpDocument {
+	return &dumpDocument{
+		FormatVersion: jsonFormatVersion,
+		Config:        redactConfig(cfg),
+	}
+}
+
+func newVariableDocuments(variables []config.Variable) []variableDocument {
+	docs := []variableDocument{}
+	for _, v := range variables {
+		docs = append(docs, variableDocument{
+			Name:   v.Name,
+			Value:  variableValue(v),
+			Source: v.Source,
+			Secret: v.Secret,
+		})
+	}
+	return docs
+}
+
+// variableValue returns the value of the variable, or redactedValue for a secret variable
+func variableValue(v config.Variable) string {
+	if v.Secret {
+		return redactedValue
+	}
+	return v.Value
+}
+
+// redactConfig returns a copy of the config with secret values replaced
+func redactConfig(cfg *config.ClusterConfig) *config.ClusterConfig {
+	redacted := *cfg
+	redacted.Applications = make([]config.ApplicationConfig, len(cfg.Applications))
+	for i, app := range cfg.Applications {
+		if app.Spec.RegistryPassword != nil {
+			app.Spec.RegistryPassword = stringPtr(redactedValue)
+		}
+		if len(app.Spec.Env) > 0 {
+			env := make([]config.EnvVarConfig, len(app.Spec.Env))
+			for j, e := range app.Spec.Env {
+				if e.Secret && e.Value != nil {
+					e.Value = stringPtr(redactedValue)
+				}
+				env[j] = e
+			}
+			app.Spec.Env = env
+		}
+		redacted.Applications[i] = app
+	}
+	return &redacted
+}
+
+// newChangeDocuments converts field changes to JSON documents.
+// It returns an empty slice instead of nil so that JSON output has [] instead of null.
+func newChangeDocuments(changes []provisioner.FieldChange) []changeDocument {
+	docs := make([]changeDocument, 0, len(changes))
+	for _, c := range changes {
+		docs = append(docs, changeDocument{
+			Resource:    string(c.Resource),
+			Path:        c.Path,
+			Kind:        string(c.Kind),
+			Old:         c.Old,
+			New:         c.New,
+			Sensitive:   c.Sensitive,
+			Description: c.String(),
+		})
+	}
+	return docs
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -1,103 +1,234 @@
 package config
 
+import "slices"
+
 // ClusterConfig represents the YAML configuration for a cluster
 type ClusterConfig struct {
 	// ClusterName is the target cluster name
-	ClusterName string `yaml:"clusterName"`
+	ClusterName string `yaml:"clusterName" json:"clusterName"`
 	// AutoScalingGroups is a list of auto scaling group configurations
-	AutoScalingGroups []AutoScalingGroupConfig `yaml:"autoScalingGroups,omitempty"`
+	AutoScalingGroups []AutoScalingGroupConfig `yaml:"autoScalingGroups,omitempty" json:"autoScalingGroups,omitempty"`
 	// LoadBalancers is a list of load balancer configurations
-	LoadBalancers []LoadBalancerConfig `yaml:"loadBalancers,omitempty"`
+	LoadBalancers []LoadBalancerConfig `yaml:"loadBalancers,omitempty" json:"loadBalancers,omitempty"`
 	// Applications is a list of application configurations
-	Applications []ApplicationConfig `yaml:"applications"`
+	Applications []ApplicationConfig `yaml:"applications" json:"applications"`
+	// Prune configures deletion of applications that exist in the cluster but not in the config
+	Prune *PruneConfig `yaml:"prune,omitempty" json:"prune,omitempty"`
+	// ManageInfrastructure controls ASGs and LBs that exist in the cluster but not in the config:
+	// "additive" (default) leaves them alone, "exclusive" deletes them
+	ManageInfrastructure string `yaml:"manageInfrastructure,omitempty" json:"manageInfrastructure,omitempty"`
+	// Base selects the version that applications are compared with and inherit settings from:
+	// "latest" (default) uses the highest version number, "active" uses the active version
+	Base string `yaml:"base,omitempty" json:"base,omitempty"`
+	// Lock configures the lock that prevents concurrent applies on the cluster
+	Lock *LockConfig `yaml:"lock,omitempty" json:"lock,omitempty"`
+
+	// Variables are the variables the configuration was loaded with (see Load).
+	// They are not part of the configuration itself, whose values already have them expanded.
+	Variables []Variable `yaml:"-" json:"-"`
+}
+
+// Values of ClusterConfig.ManageInfrastructure
+const (
+	ManageInfrastructureAdditive  = "additive"
+	ManageInfrastructureExclusive = "exclusive"
+)
+
+// Values of ClusterConfig.Base and ApplicationConfig.Base
+const (
+	BaseLatest = "latest"
+	BaseActive = "active"
+)
+
+// Values of LockConfig.Backend
+const (
+	LockBackendLocal = "local"
+	LockBackendHTTP  = "http"
+)
+
+// LockConfig represents the settings of the apply lock
+type LockConfig struct {
+	// Backend is "local" (default) for a lock file next to the state file, or "http" for a remote lock server
+	Backend string `yaml:"backend,omitempty" json:"backend,omitempty"`
+	// Address is the URL of the lock on the lock server (required for the http backend)
+	Address string `yaml:"address,omitempty" json:"address,omitempty"`
+}
+
+// LockBackend returns the lock backend, falling back to "local"
+func (c *ClusterConfig) LockBackend() string {
+	if c.Lock == nil || c.Lock.Backend == "" {
+		return LockBackendLocal
+	}
+	return c.Lock.Backend
+}
+
+// PruneConfig represents the settings for deleting applications not in the config
+type PruneConfig struct {
+	// Enabled plans deletion of applications not in the config (same as --prune)
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Keep is a list of application names that are never deleted, even with --prune
+	Keep []string `yaml:"keep,omitempty" json:"keep,omitempty"`
+}
+
+// PruneEnabled reports whether pruning is enabled in the config
+func (c *ClusterConfig) PruneEnabled() bool {
+	return c.Prune != nil && c.Prune.Enabled
+}
+
+// IsPruneProtected reports whether the named application must never be pruned
+func (c *ClusterConfig) IsPruneProtected(name string) bool {
+	return c.Prune != nil && slices.Contains(c.Prune.Keep, name)
+}
+
+// ExclusiveInfrastructure reports whether ASGs and LBs not in the config are deleted
+func (c *ClusterConfig) ExclusiveInfrastructure() bool {
+	return c.ManageInfrastructure == ManageInfrastructureExclusive
+}
+
+// VersionBase returns the base of the application, falling back to the global setting and then to "latest"
+func (c *ClusterConfig) VersionBase(app *ApplicationConfig) string {
+	if app.Base != "" {
+		return app.Base
+	}
+	if c.Base != "" {
+		return c.Base
+	}
+	return BaseLatest
 }
 
 // AutoScalingGroupConfig represents an auto scaling group configuration
 // Note: ASG settings cannot be updated. Changes require delete and recreate.
 type AutoScalingGroupConfig struct {
 	// Name is the ASG name (must be unique within cluster)
-	Name string `yaml:"name"`
+	Name string `yaml:"name" json:"name"`
 	// Zone is the zone where the ASG is created (e.g., "is1a")
-	Zone string `yaml:"zone"`
+	Zone string `yaml:"zone" json:"zone"`
 	// WorkerServiceClassPath is the service class path for workers
-	WorkerServiceClassPath string `yaml:"workerServiceClassPath"`
+	WorkerServiceClassPath string `yaml:"workerServiceClassPath" json:"workerServiceClassPath"`
 	// MinNodes is the minimum number of nodes
-	MinNodes int32 `yaml:"minNodes"`
+	MinNodes int32 `yaml:"minNodes" json:"minNodes"`
 	// MaxNodes is the maximum number of nodes
-	MaxNodes int32 `yaml:"maxNodes"`
+	MaxNodes int32 `yaml:"maxNodes" json:"maxNodes"`
 	// NameServers is the list of DNS servers
-	NameServers []string `yaml:"nameServers"`
+	NameServers []string `yaml:"nameServers" json:"nameServers"`
 	// Interfaces is the list of network interfaces
-	Interfaces []ASGInterfaceConfig `yaml:"interfaces"`
+	Interfaces []ASGInterfaceConfig `yaml:"interfaces" json:"interfaces"`
+	// Lifecycle controls how the provisioner may replace the ASG
+	Lifecycle *LifecycleConfig `yaml:"lifecycle,omitempty" json:"lifecycle,omitempty"`
+}
+
+// LifecycleConfig represents the lifecycle settings of an ASG or LB
+type LifecycleConfig struct {
+	// PreventDestroy makes plan fail instead of deleting the resource to apply a change
+	PreventDestroy bool `yaml:"preventDestroy" json:"preventDestroy"`
+}
+
+// PreventsDestroy reports whether the resource must not be deleted
+func (l *LifecycleConfig) PreventsDestroy() bool {
+	return l != nil && l.PreventDestroy
 }
 
 // ASGInterfaceConfig represents a network interface configuration for ASG
 type ASGInterfaceConfig struct {
 	// InterfaceIndex is the interface number (0=eth0, 1=eth1, etc.)
-	InterfaceIndex int16 `yaml:"interfaceIndex"`
+	InterfaceIndex int16 `yaml:"interfaceIndex" json:"interfaceIndex"`
 	// Upstream is "shared" for shared segment, or switch/router ID
-	Upstream string `yaml:"upstream"`
+	Upstream string `yaml:"upstream" json:"upstream"`
 	// IpPool is the IP address pool (required unless upstream is "shared")
-	IpPool []IpRangeConfig `yaml:"ipPool,omitempty"`
+	IpPool []IpRangeConfig `yaml:"ipPool,omitempty" json:"ipPool,omitempty"`
 	// NetmaskLen is the netmask length (required unless upstream is "shared")
-	NetmaskLen *int16 `yaml:"netmaskLen,omitempty"`
+	NetmaskLen *int16 `yaml:"netmaskLen,omitempty" json:"netmaskLen,omitempty"`
 	// DefaultGateway is the default gateway
-	DefaultGateway *string `yaml:"defaultGateway,omitempty"`
+	DefaultGateway *string `yaml:"defaultGateway,omitempty" json:"defaultGateway,omitempty"`
 	// PacketFilterID is the packet filter ID
-	PacketFilterID *string `yaml:"packetFilterId,omitempty"`
+	PacketFilterID *string `yaml:"packetFilterId,omitempty" json:"packetFilterId,omitempty"`
 	// ConnectsToLB indicates if this interface connects to load balancer
-	ConnectsToLB bool `yaml:"connectsToLB"`
+	ConnectsToLB bool `yaml:"connectsToLB" json:"connectsToLB"`
 }
 
 // IpRangeConfig represents an IP address range
 type IpRangeConfig struct {
 	// Start is the start IP address
-	Start string `yaml:"start"`
+	Start string `yaml:"start" json:"start"`
 	// End is the end IP address
-	End string `yaml:"end"`
+	End string `yaml:"end" json:"end"`
 }
 
 // LoadBalancerConfig represents a load balancer configuration
 // Note: LB settings cannot be updated. Changes require delete and recreate.
 type LoadBalancerConfig struct {
 	// Name is the load balancer name
-	Name string `yaml:"name"`
+	Name string `yaml:"name" json:"name"`
 	// AutoScalingGroupName is the name of the ASG this LB belongs to
-	AutoScalingGroupName string `yaml:"autoScalingGroupName"`
+	AutoScalingGroupName string `yaml:"autoScalingGroupName" json:"autoScalingGroupName"`
 	// ServiceClassPath is the service class path
-	ServiceClassPath string `yaml:"serviceClassPath"`
+	ServiceClassPath string `yaml:"serviceClassPath" json:"serviceClassPath"`
 	// NameServers is the list of DNS servers
-	NameServers []string `yaml:"nameServers"`
+	NameServers []string `yaml:"nameServers" json:"nameServers"`
 	// Interfaces is the list of network interfaces
-	Interfaces []LBInterfaceConfig `yaml:"interfaces"`
+	Interfaces []LBInterfaceConfig `yaml:"interfaces" json:"interfaces"`
+	// Lifecycle controls how the provisioner may replace the LB
+	Lifecycle *LifecycleConfig `yaml:"lifecycle,omitempty" json:"lifecycle,omitempty"`
+	// ReplaceStrategy controls how the LB is recreated:
+	// "destroyBeforeCreate" (default) or "createBeforeDestroy"
+	ReplaceStrategy string `yaml:"replaceStrategy,omitempty" json:"replaceStrategy,omitempty"`
+}
+
+// Values of LoadBalancerConfig.ReplaceStrategy
+const (
+	ReplaceDestroyBeforeCreate = "destroyBeforeCreate"
+	ReplaceCreateBeforeDestroy = "createBeforeDestroy"
+)
+
+// CreateBeforeDestroy reports whether the replacement LB is created before the existing LB is deleted
+func (c *LoadBalancerConfig) CreateBeforeDestroy() bool {
+	return c.ReplaceStrategy == ReplaceCreateBeforeDestroy
+}
+
+// replacementSuffix is appended to the name of an LB replaced with createBeforeDestroy
+const replacementSuffix = "-next"
+
+// maxLBNameLength is the maximum length of an LB name allowed by the API
+const maxLBNameLength = 20
+
+// ReplacementName returns the temporary name under which a createBeforeDestroy replacement is created.
+// LB names are unique within an ASG, so the replacement cannot use the name of the LB it replaces.
+func (c *LoadBalancerConfig) ReplacementName() string {
+	name := c.Name
+	if len(name)+len(replacementSuffix) > maxLBNameLength {
+		name = name[:maxLBNameLength-len(replacementSuffix)]
+	}
+	return name + replacementSuffix
 }
 
 // LBInterfaceConfig represents a network interface configuration for LoadBalancer
 type LBInterfaceConfig struct {
 	// InterfaceIndex is the interface number
-	InterfaceIndex int16 `yaml:"interfaceIndex"`
+	InterfaceIndex int16 `yaml:"interfaceIndex" json:"interfaceIndex"`
 	// Upstream is "shared" for shared segment, or switch/router ID
-	Upstream string `yaml:"upstream"`
+	Upstream string `yaml:"upstream" json:"upstream"`
 	// IpPool is the IP address pool (required unless upstream is "shared")
-	IpPool []IpRangeConfig `yaml:"ipPool,omitempty"`
+	IpPool []IpRangeConfig `yaml:"ipPool,omitempty" json:"ipPool,omitempty"`
 	// NetmaskLen is the netmask length (required unless upstream is "shared")
-	NetmaskLen *int16 `yaml:"netmaskLen,omitempty"`
+	NetmaskLen *int16 `yaml:"netmaskLen,omitempty" json:"netmaskLen,omitempty"`
 	// DefaultGateway is the default gateway
-	DefaultGateway *string `yaml:"defaultGateway,omitempty"`
+	DefaultGateway *string `yaml:"defaultGateway,omitempty" json:"defaultGateway,omitempty"`
 	// Vip is the virtual IP address
-	Vip *string `yaml:"vip,omitempty"`
+	Vip *string `yaml:"vip,omitempty" json:"vip,omitempty"`
 	// VirtualRouterID is the VRRP virtual router ID (1-255, required if vip is set)
-	VirtualRouterID *int16 `yaml:"virtualRouterId,omitempty"`
+	VirtualRouterID *int16 `yaml:"virtualRouterId,omitempty" json:"virtualRouterId,omitempty"`
 	// PacketFilterID is the packet filter ID
-	PacketFilterID *string `yaml:"packetFilterId,omitempty"`
+	PacketFilterID *string `yaml:"packetFilterId,omitempty" json:"packetFilterId,omitempty"`
 }
 
 // ApplicationConfig represents an application configuration
 type ApplicationConfig struct {
 	// Name is the application name (must be unique within cluster)
-	Name string `yaml:"name"`
+	Name string `yaml:"name" json:"name"`
+	// Base overrides the global base for this application ("latest" or "active")
+	Base string `yaml:"base,omitempty" json:"base,omitempty"`
 	// Spec contains the application spec settings
-	Spec ApplicationSpec `yaml:"spec"`
+	Spec ApplicationSpec `yaml:"spec" json:"spec"`
 }
 
 // ApplicationSpec represents the application spec settings
@@ -106,69 +237,69 @@ type ApplicationSpec struct {
 	// instead of using the image specified in the config.
 	// When false (default), the image field in config is used.
 	// When true, the image is inherited from the previous version.
-	InheritImage bool `yaml:"inheritImage,omitempty"`
+	InheritImage bool `yaml:"inheritImage,omitempty" json:"inheritImage,omitempty"`
 	// CPU in mCPU (100-64000)
-	CPU int64 `yaml:"cpu"`
+	CPU int64 `yaml:"cpu" json:"cpu"`
 	// Memory in MB (128-131072)
-	Memory int64 `yaml:"memory"`
+	Memory int64 `yaml:"memory" json:"memory"`
 	// ScalingMode: "manual" or "cpu"
-	ScalingMode string `yaml:"scalingMode"`
+	ScalingMode string `yaml:"scalingMode" json:"scalingMode"`
 	// FixedScale for manual scaling mode
-	FixedScale *int32 `yaml:"fixedScale,omitempty"`
+	FixedScale *int32 `yaml:"fixedScale,omitempty" json:"fixedScale,omitempty"`
 	// MinScale for cpu scaling mode
-	MinScale *int32 `yaml:"minScale,omitempty"`
+	MinScale *int32 `yaml:"minScale,omitempty" json:"minScale,omitempty"`
 	// MaxScale for cpu scaling mode
-	MaxScale *int32 `yaml:"maxScale,omitempty"`
+	MaxScale *int32 `yaml:"maxScale,omitempty" json:"maxScale,omitempty"`
 	// ScaleInThreshold for cpu scaling mode (30-70)
-	ScaleInThreshold *int32 `yaml:"scaleInThreshold,omitempty"`
+	ScaleInThreshold *int32 `yaml:"scaleInThreshold,omitempty" json:"scaleInThreshold,omitempty"`
 	// ScaleOutThreshold for cpu scaling mode (50-99)
-	ScaleOutThreshold *int32 `yaml:"scaleOutThreshold,omitempty"`
+	ScaleOutThreshold *int32 `yaml:"scaleOutThreshold,omitempty" json:"scaleOutThreshold,omitempty"`
 	// Image is the container image
-	Image string `yaml:"image"`
+	Image string `yaml:"image" json:"image"`
 	// Cmd is the command to run (optional)
-	Cmd []string `yaml:"cmd,omitempty"`
+	Cmd []string `yaml:"cmd,omitempty" json:"cmd,omitempty"`
 	// Registry credentials
-	RegistryUsername        *string `yaml:"registryUsername,omitempty"`
-	RegistryPassword        *string `yaml:"registryPassword,omitempty"`
-	RegistryPasswordVersion *int    `yaml:"registryPasswordVersion,omitempty"`
+	RegistryUsername        *string `yaml:"registryUsername,omitempty" json:"registryUsername,omitempty"`
+	RegistryPassword        *string `yaml:"registryPassword,omitempty" json:"registryPassword,omitempty"`
+	RegistryPasswordVersion *int    `yaml:"registryPasswordVersion,omitempty" json:"registryPasswordVersion,omitempty"`
 	// ExposedPorts defines ports exposed by the application
-	ExposedPorts []ExposedPortConfig `yaml:"exposedPorts"`
+	ExposedPorts []ExposedPortConfig `yaml:"exposedPorts" json:"exposedPorts"`
 	// Env is a list of environment variables
-	Env []EnvVarConfig `yaml:"env,omitempty"`
+	Env []EnvVarConfig `yaml:"env,omitempty" json:"env,omitempty"`
 }
 
 // ExposedPortConfig represents a port configuration
 type ExposedPortConfig struct {
 	// TargetPort is the port the application listens on
-	TargetPort int32 `yaml:"targetPort"`
+	TargetPort int32 `yaml:"targetPort" json:"targetPort"`
 	// LoadBalancerPort is the external port (null if not exposed via LB)
-	LoadBalancerPort *int32 `yaml:"loadBalancerPort,omitempty"`
+	LoadBalancerPort *int32 `yaml:"loadBalancerPort,omitempty" json:"loadBalancerPort,omitempty"`
 	// UseLetsEncrypt enables Let's Encrypt for HTTPS
-	UseLetsEncrypt bool `yaml:"useLetsEncrypt"`
+	UseLetsEncrypt bool `yaml:"useLetsEncrypt" json:"useLetsEncrypt"`
 	// Host is the hostname for HTTP/HTTPS routing
-	Host []string `yaml:"host,omitempty"`
+	Host []string `yaml:"host,omitempty" json:"host,omitempty"`
 	// HealthCheck configuration
-	HealthCheck *HealthCheckConfig `yaml:"healthCheck,omitempty"`
+	HealthCheck *HealthCheckConfig `yaml:"healthCheck,omitempty" json:"healthCheck,omitempty"`
 }
 
 // HealthCheckConfig represents health check settings
 type HealthCheckConfig struct {
 	// Path is the health check endpoint path
-	Path string `yaml:"path"`
+	Path string `yaml:"path" json:"path"`
 	// IntervalSeconds is the check interval in seconds
-	IntervalSeconds int32 `yaml:"intervalSeconds"`
+	IntervalSeconds int32 `yaml:"intervalSeconds" json:"intervalSeconds"`
 	// TimeoutSeconds is the check timeout in seconds
-	TimeoutSeconds int32 `yaml:"timeoutSeconds"`
+	TimeoutSeconds int32 `yaml:"timeoutSeconds" json:"timeoutSeconds"`
 }
 
 // EnvVarConfig represents an environment variable
 type EnvVarConfig struct {
 	// Key is the environment variable name
-	Key string `yaml:"key"`
+	Key string `yaml:"key" json:"key"`
 	// Value is the environment variable value
-	Value *string `yaml:"value,omitempty"`
+	Value *string `yaml:"value,omitempty" json:"value,omitempty"`
 	// Secret marks the variable as secret (value cannot be retrieved via API)
-	Secret bool `yaml:"secret"`
+	Secret bool `yaml:"secret" json:"secret"`
 	// SecretVersion is required when secret is true (increment to trigger update)
-	SecretVersion *int `yaml:"secretVersion,omitempty"`
+	SecretVersion *int `yaml:"secretVersion,omitempty" json:"secretVersion,omitempty"`
 }
@@ -0,0 +1,310 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// variablesKey is the top-level key of the block that declares config variables
+const variablesKey = "variables"
+
+// Values of Variable.Source
+const (
+	VariableSourceFlag        = "--var"
+	VariableSourceFile        = "--var-file"
+	VariableSourceConfig      = "variables"
+	VariableSourceEnvironment = "environment"
+	VariableSourceDefault     = "default"
+)
+
+// variableName matches valid variable names
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// LoadOptions holds the variables given on the command line
+type LoadOptions struct {
+	// Vars are the variables set by --var, overriding all other sources
+	Vars map[string]string
+	// VarFiles are the YAML files of variables given by --var-file; later files override earlier ones
+	VarFiles []string
+}
+
+// Variable is a variable referenced by the configuration and the value it was resolved to
+type Variable struct {
+	Name  string
+	Value string
+	// Source is where the value came from (see VariableSource*)
+	Source string
+	// Secret reports whether the value is used in a secret env var or registryPassword
+	Secret bool
+}
+
+// applicationPath and envPath match the field paths of applications and of their env vars
+var (
+	applicationPath = regexp.MustCompile(`^applications\[\d+\]$`)
+	envPath         = regexp.MustCompile(`\.spec\.env\[\d+\]$`)
+)
+
+// interpolator resolves ${NAME} and ${NAME:-default} references in config values
+type interpolator struct {
+	flags map[string]string
+	files map[string]string
+	// used records the resolved variables in the order they were first referenced
+	used []Variable
+	// secretRefs records the references in fields that may hold secret values
+	secretRefs []secretRef
+}
+
+// secretRef is a reference in an env var value or registryPassword of an application.
+// Whether the value is secret is decided on the loaded configuration, since overlays
+// may mark the env var as secret in another file.
+type secretRef struct {
+	variable Variable
+	app      string
+	// envKey is the env var the reference is in (empty for registryPassword)
+	envKey string
+}
+
+// fieldOwner identifies the application and env var a field belongs to
+type fieldOwner struct {
+	app    string
+	envKey string
+}
+
+func newInterpolator(opts LoadOptions) (*interpolator, error) {
+	in := &interpolator{flags: opts.Vars, files: make(map[string]string)}
+	for name := range opts.Vars {
+		if !variableName.MatchString(name) {
+			return nil, fmt.Errorf("invalid variable name %q", name)
+		}
+	}
+	for _, path := range opts.VarFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read variable file: %w", err)
+		}
+		var vars map[string]string
+		if err := yaml.Unmarshal(data, &vars); err != nil {
+			return nil, fmt.Errorf("failed to parse variable file %s: %w", path, err)
+		}
+		for name, value := range vars {
+			if !variableName.MatchString(name) {
+				return nil, fmt.Errorf("%s: invalid variable name %q", path, name)
+			}
+			in.files[name] = value
+		}
+	}
+	return in, nil
+}
+
+// lookup resolves a variable from the sources in order of precedence.
+// declared holds the variables of the variables blocks.
+func (in *interpolator) lookup(name string, declared map[string]string) (string, string, bool) {
+	if value, ok := in.flags[name]; ok {
+		return value, VariableSourceFlag, true
+	}
+	if value, ok := in.files[name]; ok {
+		return value, VariableSourceFile, true
+	}
+	if value, ok := declared[name]; ok {
+		return value, VariableSourceConfig, true
+	}
+	if value, ok := os.LookupEnv(name); ok {
+		return value, VariableSourceEnvironment, true
+	}
+	return "", "", false
+}
+
+// record adds the resolved variable unless the same value has been recorded already
+func (in *interpolator) record(v Variable) {
+	for _, used := range in.used {
+		if used.Name == v.Name && used.Value == v.Value {
+			return
+		}
+	}
+	in.used = append(in.used, v)
+}
+
+// interpolateNode expands the references in the scalar values under the node.
+// path is the field path of the node for error messages.
+func (in *interpolator) interpolateNode(node *yaml.Node, path string, declared map[string]string, owner fieldOwner) error {
+	var errs []error
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			errs = append(errs, in.interpolateNode(child, path, declared, owner))
+		}
+	case yaml.MappingNode:
+		switch {
+		case applicationPath.MatchString(path):
+			owner.app = mappingValue(node, "name")
+		case envPath.MatchString(path):
+			owner.envKey = mappingValue(node, "key")
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			errs = append(errs, in.interpolateNode(node.Content[i+1], key, declared, owner))
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			errs = append(errs, in.interpolateNode(child, fmt.Sprintf("%s[%d]", path, i), declared, owner))
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "$") {
+			return nil
+		}
+		value, refs, err := in.expand(node.Value, declared)
+		if err != nil {
+			return fmt.Errorf("%s: %w", describePath(path), err)
+		}
+		if owner.app != "" && (strings.HasSuffix(path, ".spec.registryPassword") || (owner.envKey != "" && strings.HasSuffix(path, ".value"))) {
+			for _, ref := range refs {
+				in.secretRefs = append(in.secretRefs, secretRef{variable: ref, app: owner.app, envKey: owner.envKey})
+			}
+		}
+		if node.Style == 0 && node.Tag == "!!str" {
+			// Let an unquoted reference produce a number or a boolean, as if the value were written in place
+			node.Tag = resolvedTag(value)
+		}
+		node.Value = value
+	}
+	return errors.Join(errs...)
+}
+
+// mappingValue returns the scalar value of the key in the mapping node (empty if not found)
+func mappingValue(node *yaml.Node, key string) string {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1].Value
+		}
+	}
+	return ""
+}
+
+// expand replaces the references in the value and returns the variables referenced. "$$" is a literal "$".
+func (in *interpolator) expand(value string, declared map[string]string) (string, []Variable, error) {
+	var b strings.Builder
+	var refs []Variable
+	for {
+		i := strings.IndexByte(value, '$')
+		if i < 0 || i == len(value)-1 {
+			b.WriteString(value)
+			return b.String(), refs, nil
+		}
+		b.WriteString(value[:i])
+		value = value[i:]
+
+		switch value[1] {
+		case '$':
+			b.WriteByte('$')
+			value = value[2:]
+			continue
+		case '{':
+		default:
+			b.WriteByte('$')
+			value = value[1:]
+			continue
+		}
+
+		end := strings.IndexByte(value, '}')
+		if end < 0 {
+			return "", nil, fmt.Errorf("unterminated variable reference %q", value)
+		}
+		expr := value[2:end]
+		value = value[end+1:]
+
+		name, def, hasDefault := strings.Cut(expr, ":-")
+		if !variableName.MatchString(name) {
+			return "", nil, fmt.Errorf("invalid variable reference ${%s}", expr)
+		}
+		resolved, source, ok := in.lookup(name, declared)
+		if !ok || (hasDefault && resolved == "") {
+			if !hasDefault {
+				return "", nil, fmt.Errorf("variable %s is not defined", name)
+			}
+			resolved, source = def, VariableSourceDefault
+		}
+		ref := Variable{Name: name, Value: resolved, Source: source}
+		in.record(ref)
+		refs = append(refs, ref)
+		b.WriteString(resolved)
+	}
+}
+
+// resolvedTag returns the tag of an unquoted scalar with the value, limited to values
+// that are written back unchanged, so that string fields keep the exact value
+func resolvedTag(value string) string {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil && strconv.FormatInt(n, 10) == value {
+		return "!!int"
+	}
+	if value == "true" || value == "false" {
+		return "!!bool"
+	}
+	return "!!str"
+}
+
+// declaredVariables removes the variables block from the root of a config file and returns its values
+func declaredVariables(root *yaml.Node) (map[string]string, error) {
+	if root.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != variablesKey {
+			continue
+		}
+		var vars map[string]string
+		if err := root.Content[i+1].Decode(&vars); err != nil {
+			return nil, fmt.Errorf("%s must be a map of names to values: %w", variablesKey, err)
+		}
+		for name := range vars {
+			if !variableName.MatchString(name) {
+				return nil, fmt.Errorf("%s: invalid variable name %q", variablesKey, name)
+			}
+		}
+		root.Content = append(root.Content[:i], root.Content[i+2:]...)
+		return vars, nil
+	}
+	return nil, nil
+}
+
+// markSecretVariables marks the used variables that are referenced in the secret values of the config
+func (in *interpolator) markSecretVariables(config *ClusterConfig) {
+	for _, ref := range in.secretRefs {
+		if !config.isSecretField(ref.app, ref.envKey) {
+			continue
+		}
+		for i := range in.used {
+			if in.used[i].Name == ref.variable.Name && in.used[i].Value == ref.variable.Value {
+				in.used[i].Secret = true
+			}
+		}
+	}
+}
+
+// isSecretField reports whether the env var of the application is secret
+// (the registryPassword for an empty envKey, which is always secret)
+func (c *ClusterConfig) isSecretField(app, envKey string) bool {
+	for _, a := range c.Applications {
+		if a.Name != app {
+			continue
+		}
+		if envKey == "" {
+			return true
+		}
+		for _, env := range a.Spec.Env {
+			if env.Key == envKey {
+				return env.Secret
+			}
+		}
+	}
+	// Keep the value hidden if the field cannot be found
+	return true
+}
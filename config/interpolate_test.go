@@ -0,0 +1,230 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpand(t *testing.T) {
+	t.Setenv("APPRUN_TEST_EMPTY", "")
+	t.Setenv("APPRUN_TEST_TAG", "v1")
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{name: "reference", value: "nginx:${APPRUN_TEST_TAG}", want: "nginx:v1"},
+		{name: "escaped", value: "$${APPRUN_TEST_TAG} costs $$5", want: "${APPRUN_TEST_TAG} costs $5"},
+		{name: "lone dollar signs", value: "$HOME $ 100$", want: "$HOME $ 100$"},
+		{name: "default for an unset variable", value: "${APPRUN_TEST_UNSET:-latest}", want: "latest"},
+		{name: "default for an empty variable", value: "${APPRUN_TEST_EMPTY:-latest}", want: "latest"},
+		{name: "empty default", value: "x${APPRUN_TEST_UNSET:-}y", want: "xy"},
+		{name: "empty variable without a default", value: "x${APPRUN_TEST_EMPTY}y", want: "xy"},
+		{name: "undefined", value: "${APPRUN_TEST_UNSET}", wantErr: "variable APPRUN_TEST_UNSET is not defined"},
+		{name: "invalid name", value: "${1TAG}", wantErr: "invalid variable reference ${1TAG}"},
+		{name: "unterminated", value: "nginx:${APPRUN_TEST_TAG", wantErr: `unterminated variable reference "${APPRUN_TEST_TAG"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, err := newInterpolator(LoadOptions{})
+			require.NoError(t, err)
+			got, _, err := in.expand(tt.value, nil)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantErr, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoad_VariablePrecedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		flag       bool
+		file       bool
+		declared   bool
+		env        bool
+		wantValue  string
+		wantSource string
+	}{
+		{name: "--var", flag: true, file: true, declared: true, env: true, wantValue: "flag", wantSource: VariableSourceFlag},
+		{name: "--var-file", file: true, declared: true, env: true, wantValue: "file", wantSource: VariableSourceFile},
+		{name: "variables", declared: true, env: true, wantValue: "declared", wantSource: VariableSourceConfig},
+		{name: "environment", env: true, wantValue: "env", wantSource: VariableSourceEnvironment},
+		{name: "default", wantValue: "default", wantSource: VariableSourceDefault},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			config := "clusterName: ${APPRUN_TEST_CLUSTER:-default}\n" + appYAML("api")
+			if tt.declared {
+				config = "variables:\n  APPRUN_TEST_CLUSTER: declared\n" + config
+			}
+			writeFiles(t, dir, map[string]string{
+				"config.yaml": config,
+				"vars.yaml":   "APPRUN_TEST_CLUSTER: file\n",
+			})
+			var opts LoadOptions
+			if tt.flag {
+				opts.Vars = map[string]string{"APPRUN_TEST_CLUSTER": "flag"}
+			}
+			if tt.file {
+				opts.VarFiles = []string{filepath.Join(dir, "vars.yaml")}
+			}
+			if tt.env {
+				t.Setenv("APPRUN_TEST_CLUSTER", "env")
+			}
+
+			cfg, err := Load(filepath.Join(dir, "config.yaml"), opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantValue, cfg.ClusterName)
+			assert.Equal(t, []Variable{{Name: "APPRUN_TEST_CLUSTER", Value: tt.wantValue, Source: tt.wantSource}}, cfg.Variables)
+		})
+	}
+}
+
+func TestLoad_LaterVarFilesOverride(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.yaml": "clusterName: ${CLUSTER}\n" + appYAML("api"),
+		"common.yaml": "CLUSTER: common\n",
+		"prod.yaml":   "CLUSTER: prod\n",
+	})
+
+	cfg, err := Load(filepath.Join(dir, "config.yaml"), LoadOptions{VarFiles: []string{filepath.Join(dir, "common.yaml"), filepath.Join(dir, "prod.yaml")}})
+	require.NoError(t, err)
+	assert.Equal(t, "prod", cfg.ClusterName)
+}
+
+func TestLoad_InterpolatedTypes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.yaml": `clusterName: my-cluster
+applications:
+  - name: api
+    spec:
+      cpu: ${CPU}
+      memory: 1024
+      scalingMode: manual
+      fixedScale: ${SCALE}
+      image: "nginx:${TAG}"
+      exposedPorts:
+        - targetPort: 80
+          useLetsEncrypt: ${LETS_ENCRYPT}
+      env:
+        - key: PORT
+          value: ${SCALE}
+        - key: ZIP
+          value: ${ZIP}
+        - key: QUOTED
+          value: "${LETS_ENCRYPT}"
+`,
+	})
+	vars := map[string]string{"CPU": "1000", "SCALE": "3", "TAG": "1.27", "LETS_ENCRYPT": "true", "ZIP": "0123"}
+
+	cfg, err := Load(filepath.Join(dir, "config.yaml"), LoadOptions{Vars: vars})
+	require.NoError(t, err)
+	spec := cfg.Applications[0].Spec
+	// Unquoted references to numbers and booleans fill typed fields
+	assert.Equal(t, int64(1000), spec.CPU)
+	assert.Equal(t, int32(3), *spec.FixedScale)
+	assert.True(t, spec.ExposedPorts[0].UseLetsEncrypt)
+	assert.Equal(t, "nginx:1.27", spec.Image)
+	// String fields keep the exact value
+	require.Len(t, spec.Env, 3)
+	assert.Equal(t, "3", *spec.Env[0].Value)
+	assert.Equal(t, "0123", *spec.Env[1].Value)
+	assert.Equal(t, "true", *spec.Env[2].Value)
+}
+
+func TestLoad_InterpolationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		opts    LoadOptions
+		wantErr string
+	}{
+		{
+			name:    "undefined variable",
+			config:  "clusterName: my-cluster\n" + appYAML("api") + "      env:\n        - key: TOKEN\n          value: ${APPRUN_TEST_UNSET}\n",
+			wantErr: "applications[0].spec.env[0].value: variable APPRUN_TEST_UNSET is not defined",
+		},
+		{
+			name:    "invalid reference",
+			config:  "clusterName: ${cluster-name}\n" + appYAML("api"),
+			wantErr: "clusterName: invalid variable reference ${cluster-name}",
+		},
+		{
+			name:    "invalid --var name",
+			config:  "clusterName: my-cluster\n" + appYAML("api"),
+			opts:    LoadOptions{Vars: map[string]string{"MY-VAR": "x"}},
+			wantErr: `invalid variable name "MY-VAR"`,
+		},
+		{
+			name:    "invalid name in the variables block",
+			config:  "variables:\n  MY-VAR: x\nclusterName: my-cluster\n" + appYAML("api"),
+			wantErr: `variables: invalid variable name "MY-VAR"`,
+		},
+		{
+			name:    "variables block that is not a map",
+			config:  "variables: [A]\nclusterName: my-cluster\n" + appYAML("api"),
+			wantErr: "variables must be a map of names to values",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"config.yaml": tt.config})
+
+			_, err := Load(filepath.Join(dir, "config.yaml"), tt.opts)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestLoad_SecretVariables(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"base.yaml": "clusterName: my-cluster\n" + appYAML("api") + `      registryPassword: ${REGISTRY_PASSWORD}
+      registryPasswordVersion: 1
+      env:
+        - key: API_TOKEN
+          value: ${API_TOKEN}
+        - key: LOG_LEVEL
+          value: ${LOG_LEVEL}
+`,
+		// The overlay makes the env var secret after its value was interpolated in the base
+		"prod.yaml": `extends: base.yaml
+applications:
+  - name: api
+    spec:
+      env:
+        - key: API_TOKEN
+          secret: true
+          secretVersion: 1
+`,
+	})
+	vars := map[string]string{"REGISTRY_PASSWORD": "hunter2", "API_TOKEN": "t0ken", "LOG_LEVEL": "info"}
+
+	secrets := func(t *testing.T, path string) map[string]bool {
+		cfg, err := Load(filepath.Join(dir, path), LoadOptions{Vars: vars})
+		require.NoError(t, err)
+		secret := make(map[string]bool)
+		for _, v := range cfg.Variables {
+			secret[v.Name] = v.Secret
+		}
+		return secret
+	}
+
+	assert.Equal(t, map[string]bool{"REGISTRY_PASSWORD": true, "API_TOKEN": false, "LOG_LEVEL": false}, secrets(t, "base.yaml"))
+	assert.Equal(t, map[string]bool{"REGISTRY_PASSWORD": true, "API_TOKEN": true, "LOG_LEVEL": false}, secrets(t, "prod.yaml"))
+}
@@ -2,29 +2,245 @@ package config
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
-// Load reads and parses a YAML configuration file
-func Load(path string) (*ClusterConfig, error) {
+// PoliciesDir is the directory next to the config that holds policy files instead of config files
+const PoliciesDir = "policies"
+
+// Load reads and parses the YAML configuration.
+// The path is a file, a directory or a glob pattern; the files of a directory or pattern
+// (see Files) are merged into a single configuration. A file with extends is an overlay
+// applied to the configuration it extends (see loadOverlay).
+// ${NAME} references in values are expanded with the variables of opts, the variables blocks
+// of the files and the environment, and the variables used are recorded in Variables.
+func Load(path string, opts LoadOptions) (*ClusterConfig, error) {
+	in, err := newInterpolator(opts)
+	if err != nil {
+		return nil, err
+	}
+	config, err := load(in, path, true, loadScope{})
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(config); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	in.markSecretVariables(config)
+	config.Variables = in.used
+	return config, nil
+}
+
+// loadScope is passed from an overlay to the configuration it extends
+type loadScope struct {
+	// chain holds the overlay files being loaded, to detect cycles
+	chain []string
+	// declared holds the variables declared by the overlays, which override those of the files they extend
+	declared map[string]string
+}
+
+// load reads the configuration without validating the result.
+// checkFiles validates the applications of each file of a multi-file configuration; it is off
+// for the base of an overlay, whose files may leave settings to the overlay.
+func load(in *interpolator, path string, checkFiles bool, scope loadScope) (*ClusterConfig, error) {
+	if !IsMultiFile(path) {
+		root, vars, err := parseFile(path)
+		if err != nil {
+			return nil, err
+		}
+		config, _, err := decodeFile(in, path, root, overrideVariables(vars, scope.declared), scope)
+		return config, err
+	}
+
+	paths, err := Files(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The variables blocks of all files apply to every file
+	roots := make([]*yaml.Node, len(paths))
+	declared := make(map[string]string)
+	declaredIn := make(map[string]string)
+	for i, p := range paths {
+		root, vars, err := parseFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		for name, value := range vars {
+			if prev, ok := declared[name]; ok && prev != value {
+				return nil, fmt.Errorf("invalid config: variable %s is set to different values in %s (%q) and %s (%q)", name, declaredIn[name], prev, p, value)
+			}
+			declared[name] = value
+			declaredIn[name] = p
+		}
+		roots[i] = root
+	}
+	declared = overrideVariables(declared, scope.declared)
+
+	files := make([]configFile, 0, len(paths))
+	for i, p := range paths {
+		config, overlay, err := decodeFile(in, p, roots[i], declared, scope)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		if overlay {
+			return nil, fmt.Errorf("%s: an overlay cannot be merged with other files; specify the overlay file itself", p)
+		}
+		// Check each file first, so that errors in applications point to the file they are defined in
+		if checkFiles {
+			if err := validateItems(config); err != nil {
+				return nil, fmt.Errorf("invalid config: %s: %w", p, err)
+			}
+		}
+		files = append(files, configFile{path: p, config: config})
+	}
+
+	config, err := merge(files)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return config, nil
+}
+
+// parseFile reads a single YAML configuration file and returns its root node and variables block
+func parseFile(path string) (*yaml.Node, map[string]string, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		// An empty file
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil, nil
+	}
+
+	root := doc.Content[0]
+	vars, err := declaredVariables(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	return root, vars, nil
+}
+
+// decodeFile expands the variables of a parsed configuration file and decodes it without validating it.
+// It reports whether the file is an overlay.
+func decodeFile(in *interpolator, path string, root *yaml.Node, declared map[string]string, scope loadScope) (*ClusterConfig, bool, error) {
+	if err := in.interpolateNode(root, "", declared, fieldOwner{}); err != nil {
+		return nil, false, err
+	}
+
+	var header map[string]any
+	if err := root.Decode(&header); err != nil {
+		return nil, false, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if _, ok := header[extendsKey]; ok {
+		config, err := loadOverlay(in, path, header, loadScope{chain: scope.chain, declared: declared})
+		return config, true, err
 	}
 
 	var config ClusterConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	if err := root.Decode(&config); err != nil {
+		return nil, false, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	return &config, false, nil
+}
 
-	if err := validate(&config); err != nil {
-		return nil, fmt.Errorf("invalid config: %w", err)
+// overrideVariables returns the variables with those of overrides taking precedence
+func overrideVariables(vars, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(vars)+len(overrides))
+	for name, value := range vars {
+		merged[name] = value
 	}
+	for name, value := range overrides {
+		merged[name] = value
+	}
+	return merged
+}
 
-	return &config, nil
+// IsMultiFile reports whether the config path is a directory or a glob pattern
+func IsMultiFile(path string) bool {
+	if isGlob(path) {
+		return true
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// Dir returns the directory the configuration belongs to: the directory itself for a directory,
+// the leading directory without glob characters for a glob pattern, and the parent directory for a file
+func Dir(path string) string {
+	if isGlob(path) {
+		dir := filepath.Dir(path)
+		for isGlob(dir) {
+			dir = filepath.Dir(dir)
+		}
+		return dir
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return filepath.Clean(path)
+	}
+	return filepath.Dir(path)
+}
+
+// Files returns the config files the path refers to, in the order they are merged.
+// A directory contains all *.yaml and *.yml files under it, except those in hidden directories
+// and in the policies directory. A glob pattern matches files as in filepath.Glob.
+func Files(path string) ([]string, error) {
+	var files []string
+	if isGlob(path) {
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config pattern %q: %w", path, err)
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && !info.IsDir() {
+				files = append(files, match)
+			}
+		}
+	} else {
+		root := filepath.Clean(path)
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if p != root && (strings.HasPrefix(d.Name(), ".") || p == filepath.Join(root, PoliciesDir)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if ext := filepath.Ext(p); ext == ".yaml" || ext == ".yml" {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config directory: %w", err)
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no config files found in %s", path)
+	}
+	return files, nil
+}
+
+// isGlob reports whether the path contains glob characters
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
 }
 
 // ToYAML serializes the configuration to YAML format with 2-space indentation
@@ -38,6 +254,17 @@ func (c *ClusterConfig) ToYAML() (string, error) {
 	return buf.String(), nil
 }
 
+// Hash returns a SHA-256 hash of the configuration.
+// It is used to detect whether the configuration has changed since a plan was saved.
+func (c *ClusterConfig) Hash() (string, error) {
+	data, err := c.ToYAML()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:]), nil
+}
+
 // validate checks if the configuration is valid
 func validate(config *ClusterConfig) error {
 	if config.ClusterName == "" {
@@ -47,20 +274,100 @@ func validate(config *ClusterConfig) error {
 		return fmt.Errorf("at least one application is required")
 	}
 
+	if err := validateItems(config); err != nil {
+		return err
+	}
+
+	if config.Prune != nil {
+		for i, name := range config.Prune.Keep {
+			if name == "" {
+				return fmt.Errorf("prune.keep[%d]: name must not be empty", i)
+			}
+		}
+	}
+
+	if err := validateBase(config.Base); err != nil {
+		return fmt.Errorf("base %w", err)
+	}
+
+	switch config.ManageInfrastructure {
+	case "", ManageInfrastructureAdditive, ManageInfrastructureExclusive:
+	default:
+		return fmt.Errorf("manageInfrastructure must be '%s' or '%s'", ManageInfrastructureAdditive, ManageInfrastructureExclusive)
+	}
+
+	if config.Lock != nil {
+		switch config.Lock.Backend {
+		case "", LockBackendLocal:
+			if config.Lock.Address != "" {
+				return fmt.Errorf("lock.address is only used by the '%s' backend", LockBackendHTTP)
+			}
+		case LockBackendHTTP:
+			if config.Lock.Address == "" {
+				return fmt.Errorf("lock.address is required for the '%s' backend", LockBackendHTTP)
+			}
+		default:
+			return fmt.Errorf("lock.backend must be '%s' or '%s'", LockBackendLocal, LockBackendHTTP)
+		}
+	}
+
+	for i, lb := range config.LoadBalancers {
+		switch lb.ReplaceStrategy {
+		case "", ReplaceDestroyBeforeCreate, ReplaceCreateBeforeDestroy:
+		default:
+			return fmt.Errorf("loadBalancers[%d]: replaceStrategy must be '%s' or '%s'", i, ReplaceDestroyBeforeCreate, ReplaceCreateBeforeDestroy)
+		}
+		if lb.CreateBeforeDestroy() {
+			replacement := lb.ReplacementName()
+			for _, other := range config.LoadBalancers {
+				if other.AutoScalingGroupName == lb.AutoScalingGroupName && other.Name == replacement {
+					return fmt.Errorf("loadBalancers[%d]: the replacement name %q of createBeforeDestroy is used by another load balancer", i, replacement)
+				}
+			}
+		}
+	}
+
+	if config.ExclusiveInfrastructure() {
+		// The ASG of an LB would be deleted as not in the config
+		for i, lb := range config.LoadBalancers {
+			if !slices.ContainsFunc(config.AutoScalingGroups, func(asg AutoScalingGroupConfig) bool { return asg.Name == lb.AutoScalingGroupName }) {
+				return fmt.Errorf("loadBalancers[%d]: autoScalingGroupName %q must be in autoScalingGroups when manageInfrastructure is '%s'", i, lb.AutoScalingGroupName, ManageInfrastructureExclusive)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateItems checks the applications of the configuration.
+// It is also run on each file of a multi-file configuration before the files are merged.
+func validateItems(config *ClusterConfig) error {
 	for i, app := range config.Applications {
 		if err := validateApplication(&app, i); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
+func validateBase(base string) error {
+	switch base {
+	case "", BaseLatest, BaseActive:
+		return nil
+	default:
+		return fmt.Errorf("must be '%s' or '%s'", BaseLatest, BaseActive)
+	}
+}
+
 func validateApplication(app *ApplicationConfig, index int) error {
 	if app.Name == "" {
 		return fmt.Errorf("applications[%d]: name is required", index)
 	}
 
+	if err := validateBase(app.Base); err != nil {
+		return fmt.Errorf("applications[%d]: base %w", index, err)
+	}
+
 	v := &app.Spec
 	if v.CPU < 100 || v.CPU > 64000 {
 		return fmt.Errorf("applications[%d]: cpu must be between 100 and 64000", index)
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles creates the files under dir, with paths relative to dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+// appYAML returns the YAML of a valid application list with the named application
+func appYAML(name string) string {
+	return `applications:
+  - name: ` + name + `
+    spec:
+      cpu: 1000
+      memory: 1024
+      scalingMode: manual
+      fixedScale: 1
+      image: nginx:latest
+      exposedPorts:
+        - targetPort: 80
+`
+}
+
+func appNames(config *ClusterConfig) []string {
+	var names []string
+	for _, app := range config.Applications {
+		names = append(names, app.Name)
+	}
+	return names
+}
+
+func TestFiles_Directory(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"cluster.yaml":          "",
+		"apps/api.yml":          "",
+		"apps/web.yaml":         "",
+		"apps/README.md":        "",
+		".hidden/skipped.yaml":  "",
+		"apps/.git/config.yaml": "",
+		"policies/policy.yaml":  "",
+		"apps/policies/x.yaml":  "",
+	})
+
+	files, err := Files(dir)
+	require.NoError(t, err)
+	// Only the policies directory at the top is skipped
+	assert.Equal(t, []string{
+		filepath.Join(dir, "apps", "api.yml"),
+		filepath.Join(dir, "apps", "policies", "x.yaml"),
+		filepath.Join(dir, "apps", "web.yaml"),
+		filepath.Join(dir, "cluster.yaml"),
+	}, files)
+}
+
+func TestFiles_Glob(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"b.yaml":        "",
+		"a.yaml":        "",
+		"c.yml":         "",
+		"sub.yaml/x.md": "",
+	})
+
+	files, err := Files(filepath.Join(dir, "*.yaml"))
+	require.NoError(t, err)
+	// Directories matching the pattern are not config files
+	assert.Equal(t, []string{filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")}, files)
+
+	_, err = Files(filepath.Join(dir, "*.json"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no config files found in")
+
+	_, err = Files(filepath.Join(dir, "[.yaml"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid config pattern")
+}
+
+func TestFiles_EmptyDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"policies/policy.yaml": ""})
+
+	_, err := Files(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no config files found in "+dir)
+}
+
+func TestIsMultiFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"config.yaml": ""})
+
+	assert.True(t, IsMultiFile(dir))
+	assert.True(t, IsMultiFile(filepath.Join(dir, "*.yaml")))
+	assert.False(t, IsMultiFile(filepath.Join(dir, "config.yaml")))
+	assert.False(t, IsMultiFile(filepath.Join(dir, "missing.yaml")))
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"prod/config.yaml": ""})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: filepath.Join(dir, "prod"), want: filepath.Join(dir, "prod")},
+		{path: filepath.Join(dir, "prod") + "/", want: filepath.Join(dir, "prod")},
+		{path: filepath.Join(dir, "prod", "*.yaml"), want: filepath.Join(dir, "prod")},
+		{path: filepath.Join(dir, "*", "*.yaml"), want: dir},
+		{path: filepath.Join(dir, "prod", "config.yaml"), want: filepath.Join(dir, "prod")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, Dir(tt.path))
+		})
+	}
+}
+
+func TestLoad_Directory(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"cluster.yaml": `clusterName: my-cluster
+autoScalingGroups:
+  - name: asg-1
+    zone: is1a
+    workerServiceClassPath: cloud/plan/standard
+    minNodes: 1
+    maxNodes: 2
+`,
+		"apps/b.yaml": appYAML("app-b"),
+		"apps/a.yaml": appYAML("app-a"),
+		"z.yaml":      "clusterName: my-cluster\n" + appYAML("app-z"),
+	})
+
+	config, err := Load(dir, LoadOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "my-cluster", config.ClusterName)
+	require.Len(t, config.AutoScalingGroups, 1)
+	assert.Equal(t, "asg-1", config.AutoScalingGroups[0].Name)
+	// Lists are concatenated in the order of the files
+	assert.Equal(t, []string{"app-a", "app-b", "app-z"}, appNames(config))
+}
+
+func TestLoad_Glob(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"1-cluster.yaml": "clusterName: my-cluster\n",
+		"2-api.yaml":     appYAML("api"),
+		"3-web.yaml":     appYAML("web"),
+		"other.yml":      appYAML("other"),
+	})
+
+	config, err := Load(filepath.Join(dir, "*.yaml"), LoadOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api", "web"}, appNames(config))
+}
+
+func TestLoad_MultiFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr []string
+	}{
+		{
+			name: "application defined twice",
+			files: map[string]string{
+				"a.yaml": "clusterName: my-cluster\n" + appYAML("api"),
+				"b.yaml": appYAML("api"),
+			},
+			wantErr: []string{`application "api" is defined in both`, "a.yaml", "b.yaml"},
+		},
+		{
+			name: "conflicting setting",
+			files: map[string]string{
+				"a.yaml": "clusterName: my-cluster\n" + appYAML("api"),
+				"b.yaml": "clusterName: other-cluster\n",
+			},
+			wantErr: []string{"clusterName is set to different values in", `a.yaml ("my-cluster")`, `b.yaml ("other-cluster")`},
+		},
+		{
+			name: "conflicting variables",
+			files: map[string]string{
+				"a.yaml": "variables:\n  TAG: v1\nclusterName: my-cluster\n" + appYAML("api"),
+				"b.yaml": "variables:\n  TAG: v2\n",
+			},
+			wantErr: []string{"invalid config: variable TAG is set to different values in", `a.yaml ("v1")`, `b.yaml ("v2")`},
+		},
+		{
+			name: "invalid application in a file",
+			files: map[string]string{
+				"a.yaml": "clusterName: my-cluster\n" + appYAML("api"),
+				"b.yaml": "applications:\n  - name: web\n",
+			},
+			wantErr: []string{"invalid config: ", "b.yaml: applications[0]: cpu must be between 100 and 64000"},
+		},
+		{
+			name: "overlay in a file set",
+			files: map[string]string{
+				"a.yaml": "clusterName: my-cluster\n" + appYAML("api"),
+				"b.yaml": "extends: a.yaml\n",
+			},
+			wantErr: []string{"b.yaml: an overlay cannot be merged with other files; specify the overlay file itself"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+
+			_, err := Load(dir, LoadOptions{})
+			require.Error(t, err)
+			for _, want := range tt.wantErr {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
+
+func TestLoad_SharedVariables(t *testing.T) {
+	dir := t.TempDir()
+	// A variable declared in one file is used in another, and may be repeated with the same value
+	writeFiles(t, dir, map[string]string{
+		"a.yaml": "variables:\n  IMAGE: nginx:1.27\n  CLUSTER: my-cluster\nclusterName: ${CLUSTER}\n",
+		"b.yaml": "variables:\n  CLUSTER: my-cluster\n" + appYAML("api") + "      env:\n        - key: IMAGE\n          value: ${IMAGE}\n",
+	})
+
+	config, err := Load(dir, LoadOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "my-cluster", config.ClusterName)
+	require.Len(t, config.Applications[0].Spec.Env, 1)
+	assert.Equal(t, "nginx:1.27", *config.Applications[0].Spec.Env[0].Value)
+}
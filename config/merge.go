@@ -0,0 +1,90 @@
+package config
+
+import "fmt"
+
+// configFile is a file of a multi-file configuration
+type configFile struct {
+	path   string
+	config *ClusterConfig
+}
+
+// merge combines the files of a multi-file configuration.
+// Lists are concatenated in file order, and resources defined more than once are errors.
+// Settings may be repeated in several files only with the same value.
+func merge(files []configFile) (*ClusterConfig, error) {
+	merged := &ClusterConfig{}
+	// Where each setting and resource was defined, for error messages
+	defined := make(map[string]string)
+
+	define := func(key, path string) error {
+		if previous, ok := defined[key]; ok {
+			if previous == path {
+				return fmt.Errorf("%s is defined twice in %s", key, path)
+			}
+			return fmt.Errorf("%s is defined in both %s and %s", key, previous, path)
+		}
+		defined[key] = path
+		return nil
+	}
+	// setString sets a string setting, allowing repeated definitions with the same value
+	setString := func(name string, dst *string, value, path string) error {
+		if value == "" {
+			return nil
+		}
+		if *dst != "" && *dst != value {
+			return fmt.Errorf("%s is set to different values in %s (%q) and %s (%q)", name, defined[name], *dst, path, value)
+		}
+		if *dst == "" {
+			*dst = value
+			defined[name] = path
+		}
+		return nil
+	}
+
+	for _, file := range files {
+		c, path := file.config, file.path
+
+		if err := setString("clusterName", &merged.ClusterName, c.ClusterName, path); err != nil {
+			return nil, err
+		}
+		if err := setString("manageInfrastructure", &merged.ManageInfrastructure, c.ManageInfrastructure, path); err != nil {
+			return nil, err
+		}
+		if err := setString("base", &merged.Base, c.Base, path); err != nil {
+			return nil, err
+		}
+		if c.Prune != nil {
+			if err := define("prune", path); err != nil {
+				return nil, err
+			}
+			merged.Prune = c.Prune
+		}
+		if c.Lock != nil {
+			if err := define("lock", path); err != nil {
+				return nil, err
+			}
+			merged.Lock = c.Lock
+		}
+
+		for _, asg := range c.AutoScalingGroups {
+			if err := define(fmt.Sprintf("autoScalingGroup %q", asg.Name), path); err != nil {
+				return nil, err
+			}
+			merged.AutoScalingGroups = append(merged.AutoScalingGroups, asg)
+		}
+		for _, lb := range c.LoadBalancers {
+			if err := define(fmt.Sprintf("loadBalancer %q (ASG: %s)", lb.Name, lb.AutoScalingGroupName), path); err != nil {
+				return nil, err
+			}
+			merged.LoadBalancers = append(merged.LoadBalancers, lb)
+		}
+		for _, app := range c.Applications {
+			if err := define(fmt.Sprintf("application %q", app.Name), path); err != nil {
+				return nil, err
+			}
+			merged.Applications = append(merged.Applications, app)
+		}
+	}
+
+	return merged, nil
+}
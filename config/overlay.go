@@ -0,0 +1,249 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// extendsKey is the top-level key of an overlay file that names the configuration it patches
+const extendsKey = "extends"
+
+// patchKey is the key of the directive that changes how a map or list item is patched
+const patchKey = "$patch"
+
+// Values of the $patch directive
+const (
+	// patchDelete removes the list item (or map) from the base
+	patchDelete = "delete"
+	// patchReplace replaces the list item (or map) instead of merging it.
+	// As a list element of its own, it replaces the whole list.
+	patchReplace = "replace"
+)
+
+// mergeKeys are the fields that identify the items of the lists merged by key, by field path.
+// Other lists in an overlay replace the list of the base.
+var mergeKeys = map[string][]string{
+	"autoScalingGroups":              {"name"},
+	"loadBalancers":                  {"autoScalingGroupName", "name"},
+	"applications":                   {"name"},
+	"applications.spec.env":          {"key"},
+	"applications.spec.exposedPorts": {"targetPort"},
+}
+
+// loadOverlay loads the configuration an overlay extends and applies the overlay to it
+// with strategic merge: maps are merged recursively, the lists in mergeKeys are merged
+// item by item, and other lists and values replace those of the base.
+// The variables declared in scope are passed on to the configuration it extends.
+func loadOverlay(in *interpolator, path string, overlay map[string]any, scope loadScope) (*ClusterConfig, error) {
+	extends, ok := overlay[extendsKey].(string)
+	if !ok || extends == "" {
+		return nil, fmt.Errorf("%s must be the path of the configuration to extend", extendsKey)
+	}
+	delete(overlay, extendsKey)
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config path: %w", err)
+	}
+	if slices.Contains(scope.chain, abs) {
+		return nil, fmt.Errorf("overlays extend each other in a cycle: %s", strings.Join(append(scope.chain, abs), " -> "))
+	}
+
+	// The base path is relative to the overlay file
+	basePath := extends
+	if !filepath.IsAbs(basePath) {
+		basePath = filepath.Join(filepath.Dir(path), basePath)
+	}
+	base, err := load(in, basePath, false, loadScope{chain: append(scope.chain, abs), declared: scope.declared})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s %s: %w", extendsKey, basePath, err)
+	}
+
+	tree, err := toTree(base)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := patchMap(tree, overlay, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply overlay: %w", err)
+	}
+
+	data, err := yaml.Marshal(patched)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply overlay: %w", err)
+	}
+	var config ClusterConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to apply overlay: %w", err)
+	}
+	return &config, nil
+}
+
+// toTree converts the configuration to generic YAML values
+func toTree(config *ClusterConfig) (map[string]any, error) {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	var tree map[string]any
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return tree, nil
+}
+
+// patchMap merges the overlay map into the base map.
+// A null value removes the field, and maps and lists are merged recursively.
+// path is the field path without list indexes (e.g., "applications.spec.env"), used to find merge keys.
+func patchMap(base, overlay map[string]any, path string) (map[string]any, error) {
+	if directive, ok := overlay[patchKey]; ok {
+		if directive != patchReplace {
+			return nil, fmt.Errorf("%s: %s must be %q for a map", describePath(path), patchKey, patchReplace)
+		}
+		replaced := make(map[string]any)
+		for key, value := range overlay {
+			if key != patchKey {
+				replaced[key] = value
+			}
+		}
+		return replaced, nil
+	}
+
+	merged := make(map[string]any)
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overlay {
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+
+		var err error
+		switch v := value.(type) {
+		case map[string]any:
+			if baseMap, ok := merged[key].(map[string]any); ok {
+				merged[key], err = patchMap(baseMap, v, fieldPath)
+			} else {
+				merged[key], err = patchMap(nil, v, fieldPath)
+			}
+		case []any:
+			baseList, _ := merged[key].([]any)
+			merged[key], err = patchList(baseList, v, fieldPath)
+		default:
+			merged[key] = value
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return merged, nil
+}
+
+// patchList merges the overlay list into the base list.
+// Lists with merge keys are merged item by item; other lists are replaced.
+func patchList(base, overlay []any, path string) ([]any, error) {
+	keys, keyed := mergeKeys[path]
+
+	// A "$patch: replace" element replaces the whole list
+	replace := false
+	var items []any
+	for _, item := range overlay {
+		if m, ok := item.(map[string]any); ok && len(m) == 1 && m[patchKey] == patchReplace {
+			replace = true
+			continue
+		}
+		items = append(items, item)
+	}
+	if !keyed || replace {
+		// Directives only apply to items merged by key
+		for _, item := range items {
+			if m, ok := item.(map[string]any); ok {
+				if _, ok := m[patchKey]; ok {
+					return nil, fmt.Errorf("%s: %s can only be used in lists merged by key", describePath(path), patchKey)
+				}
+			}
+		}
+		if items == nil {
+			items = []any{}
+		}
+		return items, nil
+	}
+
+	merged := slices.Clone(base)
+	for i, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s[%d]: must be a map with %s", describePath(path), i, strings.Join(keys, " and "))
+		}
+		id, err := itemKey(m, keys)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", describePath(path), i, err)
+		}
+		itemPath := fmt.Sprintf("%s[%s]", describePath(path), id)
+
+		index := slices.IndexFunc(merged, func(baseItem any) bool {
+			baseMap, ok := baseItem.(map[string]any)
+			if !ok {
+				return false
+			}
+			baseID, err := itemKey(baseMap, keys)
+			return err == nil && baseID == id
+		})
+
+		directive, hasDirective := m[patchKey]
+		switch {
+		case hasDirective && directive == patchDelete:
+			if index < 0 {
+				return nil, fmt.Errorf("%s: cannot delete, not in the base", itemPath)
+			}
+			merged = slices.Delete(merged, index, index+1)
+		case hasDirective && directive != patchReplace:
+			return nil, fmt.Errorf("%s: %s must be %q or %q", itemPath, patchKey, patchDelete, patchReplace)
+		case index < 0:
+			// New items are appended (without the directive)
+			added, err := patchMap(nil, m, path)
+			if err != nil {
+				return nil, err
+			}
+			merged = append(merged, added)
+		default:
+			baseMap, _ := merged[index].(map[string]any)
+			patched, err := patchMap(baseMap, m, path)
+			if err != nil {
+				return nil, err
+			}
+			merged[index] = patched
+		}
+	}
+	return merged, nil
+}
+
+// itemKey returns the identity of a list item merged by the given keys
+func itemKey(item map[string]any, keys []string) (string, error) {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		value, ok := item[key]
+		if !ok || value == nil {
+			return "", fmt.Errorf("%s is required to merge the item", key)
+		}
+		values[i] = fmt.Sprint(value)
+	}
+	return strings.Join(values, "/"), nil
+}
+
+// describePath returns the field path for error messages
+func describePath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
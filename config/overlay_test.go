@@ -0,0 +1,407 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// parseTree parses YAML into generic values as loadOverlay does
+func parseTree(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var tree map[string]any
+	require.NoError(t, yaml.Unmarshal([]byte(s), &tree))
+	return tree
+}
+
+func TestPatchMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		overlay string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "maps are merged recursively",
+			base:    "clusterName: c\nprune:\n  enabled: false\n  keep: [a]\n",
+			overlay: "prune:\n  enabled: true\n",
+			want:    "clusterName: c\nprune:\n  enabled: true\n  keep: [a]\n",
+		},
+		{
+			name:    "null deletes a field",
+			base:    "clusterName: c\nbase: active\nprune:\n  enabled: true\n  keep: [a]\n",
+			overlay: "base: null\nprune:\n  keep: ~\n",
+			want:    "clusterName: c\nprune:\n  enabled: true\n",
+		},
+		{
+			name:    "replace a map",
+			base:    "lock:\n  backend: http\n  address: https://lock.example.com\n",
+			overlay: "lock:\n  $patch: replace\n  backend: local\n",
+			want:    "lock:\n  backend: local\n",
+		},
+		{
+			name:    "delete is not allowed on a map",
+			base:    "lock:\n  backend: local\n",
+			overlay: "lock:\n  $patch: delete\n",
+			wantErr: `lock: $patch must be "replace" for a map`,
+		},
+		{
+			name:    "lists without merge keys are replaced",
+			base:    "prune:\n  keep: [a, b]\n",
+			overlay: "prune:\n  keep: [c]\n",
+			want:    "prune:\n  keep: [c]\n",
+		},
+		{
+			name:    "directives are not allowed in lists without merge keys",
+			base:    "prune:\n  keep: [a]\n",
+			overlay: "prune:\n  keep:\n    - $patch: delete\n",
+			wantErr: "prune.keep: $patch can only be used in lists merged by key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := patchMap(parseTree(t, tt.base), parseTree(t, tt.overlay), "")
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, parseTree(t, tt.want), got)
+		})
+	}
+}
+
+func TestPatchList(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		overlay string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "items are merged by name and new items are appended",
+			base: `applications:
+  - name: api
+    spec: {cpu: 500, memory: 1024}
+  - name: web
+    spec: {cpu: 500}
+`,
+			overlay: `applications:
+  - name: web
+    spec: {cpu: 1000}
+  - name: worker
+    spec: {cpu: 200}
+`,
+			want: `applications:
+  - name: api
+    spec: {cpu: 500, memory: 1024}
+  - name: web
+    spec: {cpu: 1000}
+  - name: worker
+    spec: {cpu: 200}
+`,
+		},
+		{
+			name: "load balancers are identified by ASG and name",
+			base: `loadBalancers:
+  - {autoScalingGroupName: asg-a, name: lb, serviceClassPath: small}
+  - {autoScalingGroupName: asg-b, name: lb, serviceClassPath: small}
+`,
+			overlay: `loadBalancers:
+  - {autoScalingGroupName: asg-b, name: lb, serviceClassPath: large}
+`,
+			want: `loadBalancers:
+  - {autoScalingGroupName: asg-a, name: lb, serviceClassPath: small}
+  - {autoScalingGroupName: asg-b, name: lb, serviceClassPath: large}
+`,
+		},
+		{
+			name: "load balancers need both keys",
+			base: `loadBalancers:
+  - {autoScalingGroupName: asg-a, name: lb}
+`,
+			overlay: `loadBalancers:
+  - {name: lb, serviceClassPath: large}
+`,
+			wantErr: "loadBalancers[0]: autoScalingGroupName is required to merge the item",
+		},
+		{
+			name: "env vars are merged by key and exposed ports by target port",
+			base: `applications:
+  - name: api
+    spec:
+      env:
+        - {key: LOG_LEVEL, value: info}
+        - {key: REGION, value: is1a}
+      exposedPorts:
+        - {targetPort: 80, useLetsEncrypt: false}
+        - {targetPort: 8080, useLetsEncrypt: false}
+`,
+			overlay: `applications:
+  - name: api
+    spec:
+      env:
+        - {key: LOG_LEVEL, value: debug}
+      exposedPorts:
+        - {targetPort: 8080, useLetsEncrypt: true, host: [api.example.com]}
+`,
+			want: `applications:
+  - name: api
+    spec:
+      env:
+        - {key: LOG_LEVEL, value: debug}
+        - {key: REGION, value: is1a}
+      exposedPorts:
+        - {targetPort: 80, useLetsEncrypt: false}
+        - {targetPort: 8080, useLetsEncrypt: true, host: [api.example.com]}
+`,
+		},
+		{
+			name: "delete an item",
+			base: `applications:
+  - name: api
+    spec:
+      env:
+        - {key: DEBUG, value: "1"}
+        - {key: REGION, value: is1a}
+`,
+			overlay: `applications:
+  - name: api
+    spec:
+      env:
+        - {key: DEBUG, $patch: delete}
+`,
+			want: `applications:
+  - name: api
+    spec:
+      env:
+        - {key: REGION, value: is1a}
+`,
+		},
+		{
+			name: "delete an item not in the base",
+			base: `applications:
+  - name: api
+`,
+			overlay: `applications:
+  - {name: web, $patch: delete}
+`,
+			wantErr: "applications[web]: cannot delete, not in the base",
+		},
+		{
+			name: "replace an item",
+			base: `applications:
+  - name: api
+    spec: {cpu: 500, memory: 1024, image: nginx}
+`,
+			overlay: `applications:
+  - name: api
+    $patch: replace
+    spec: {cpu: 1000}
+`,
+			want: `applications:
+  - name: api
+    spec: {cpu: 1000}
+`,
+		},
+		{
+			name: "replace a whole list",
+			base: `autoScalingGroups:
+  - {name: asg-a, minNodes: 1}
+  - {name: asg-b, minNodes: 1}
+`,
+			overlay: `autoScalingGroups:
+  - $patch: replace
+  - {name: asg-c, minNodes: 2}
+`,
+			want: `autoScalingGroups:
+  - {name: asg-c, minNodes: 2}
+`,
+		},
+		{
+			name: "replace a list with an empty list",
+			base: `applications:
+  - name: api
+    spec:
+      env:
+        - {key: DEBUG, value: "1"}
+`,
+			overlay: `applications:
+  - name: api
+    spec:
+      env:
+        - $patch: replace
+`,
+			want: `applications:
+  - name: api
+    spec:
+      env: []
+`,
+		},
+		{
+			name: "unknown directive",
+			base: `applications:
+  - name: api
+`,
+			overlay: `applications:
+  - {name: api, $patch: merge}
+`,
+			wantErr: `applications[api]: $patch must be "delete" or "replace"`,
+		},
+		{
+			name: "item without the merge key",
+			base: `applications:
+  - name: api
+`,
+			overlay: `applications:
+  - spec: {cpu: 1000}
+`,
+			wantErr: "applications[0]: name is required to merge the item",
+		},
+		{
+			name: "item that is not a map",
+			base: `applications:
+  - name: api
+`,
+			overlay: `applications:
+  - api
+`,
+			wantErr: "applications[0]: must be a map with name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := patchMap(parseTree(t, tt.base), parseTree(t, tt.overlay), "")
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, parseTree(t, tt.want), got)
+		})
+	}
+}
+
+func TestLoad_Overlay(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"base/cluster.yaml": "clusterName: staging\n",
+		"base/apps.yaml": appYAML("api") + `      env:
+        - key: LOG_LEVEL
+          value: info
+        - key: DEBUG
+          value: "1"
+`,
+		"prod/overlay.yaml": `extends: ../base
+clusterName: production
+applications:
+  - name: api
+    spec:
+      fixedScale: 3
+      env:
+        - key: DEBUG
+          $patch: delete
+        - key: LOG_LEVEL
+          value: warn
+`,
+	})
+
+	config, err := Load(filepath.Join(dir, "prod", "overlay.yaml"), LoadOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "production", config.ClusterName)
+	require.Len(t, config.Applications, 1)
+	spec := config.Applications[0].Spec
+	assert.Equal(t, int32(3), *spec.FixedScale)
+	assert.Equal(t, "nginx:latest", spec.Image)
+	require.Len(t, spec.Env, 1)
+	assert.Equal(t, "LOG_LEVEL", spec.Env[0].Key)
+	assert.Equal(t, "warn", *spec.Env[0].Value)
+}
+
+func TestLoad_OverlayOfOverlay(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"base.yaml":    "clusterName: base\n" + appYAML("api"),
+		"staging.yaml": "extends: base.yaml\nclusterName: staging\nbase: active\n",
+		"prod.yaml":    "extends: staging.yaml\nclusterName: production\n",
+	})
+
+	config, err := Load(filepath.Join(dir, "prod.yaml"), LoadOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "production", config.ClusterName)
+	assert.Equal(t, BaseActive, config.Base)
+}
+
+func TestLoad_OverlayErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		path    string
+		wantErr []string
+	}{
+		{
+			name: "cycle",
+			files: map[string]string{
+				"a.yaml": "extends: b.yaml\n",
+				"b.yaml": "extends: a.yaml\n",
+			},
+			path:    "a.yaml",
+			wantErr: []string{"overlays extend each other in a cycle: ", "a.yaml -> ", "b.yaml -> ", "a.yaml"},
+		},
+		{
+			name:    "extends itself",
+			files:   map[string]string{"a.yaml": "extends: a.yaml\n"},
+			path:    "a.yaml",
+			wantErr: []string{"overlays extend each other in a cycle: "},
+		},
+		{
+			name:    "extends is not a path",
+			files:   map[string]string{"a.yaml": "extends: [base.yaml]\n"},
+			path:    "a.yaml",
+			wantErr: []string{"extends must be the path of the configuration to extend"},
+		},
+		{
+			name:    "missing base",
+			files:   map[string]string{"a.yaml": "extends: missing.yaml\n"},
+			path:    "a.yaml",
+			wantErr: []string{"failed to load extends ", "missing.yaml"},
+		},
+		{
+			name: "overlay matched by a glob pattern",
+			files: map[string]string{
+				"base.yaml": "clusterName: c\n" + appYAML("api"),
+				"prod.yaml": "extends: base.yaml\n",
+			},
+			path:    "*.yaml",
+			wantErr: []string{"prod.yaml: an overlay cannot be merged with other files; specify the overlay file itself"},
+		},
+		{
+			name: "invalid result",
+			files: map[string]string{
+				"base.yaml": "clusterName: c\n" + appYAML("api"),
+				"prod.yaml": "extends: base.yaml\napplications:\n  - name: api\n    spec:\n      image: null\n",
+			},
+			path:    "prod.yaml",
+			wantErr: []string{"invalid config: applications[0]: image is required"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+
+			_, err := Load(filepath.Join(dir, tt.path), LoadOptions{})
+			require.Error(t, err)
+			for _, want := range tt.wantErr {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
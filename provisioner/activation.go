@@ -0,0 +1,141 @@
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/api"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/state"
+)
+
+// pendingActivation is a version created by apply whose activation is deferred (AtomicActivate)
+type pendingActivation struct {
+	appID   api.ApplicationID
+	appName string
+	version api.ApplicationVersionNumber
+	// previous is the version that was active before the apply (null if none)
+	previous api.NilInt32
+}
+
+// activateApplication activates the created version of the index-th planned application.
+// With AtomicActivate, the activation is deferred until versions of all applications have been created.
+func (p *Provisioner) activateApplication(ctx context.Context, run *applyRun, index int, activation pendingActivation) error {
+	if !run.opts.Activate || !run.opts.AtomicActivate {
+		if err := p.activateIfRequested(ctx, activation.appID, activation.appName, activation.version, run.opts); err != nil {
+			return err
+		}
+		run.recordActivated(index, activation)
+		return nil
+	}
+
+	run.mu.Lock()
+	run.activations[index] = &activation
+	run.mu.Unlock()
+	logf(ctx, "Version %d for application %q will be activated after all applications are applied", activation.version, activation.appName)
+	return nil
+}
+
+// recordActivated records the version activated for the index-th planned application
+func (r *applyRun) recordActivated(index int, activation pendingActivation) {
+	if !r.opts.Activate {
+		return
+	}
+	r.mu.Lock()
+	r.activated[index] = &activation
+	r.mu.Unlock()
+}
+
+// restoreActivation restores the deferred activation of an application step completed by the previous apply
+func (r *applyRun) restoreActivation(index int, appName string) error {
+	if !r.opts.Resume || !r.opts.Activate || !r.opts.AtomicActivate || r.journal == nil {
+		return nil
+	}
+	step, ok := r.journal.Step(applyNodeID(appKey(appName)))
+	if !ok || step.Status != state.StepCompleted || step.Version == 0 {
+		return nil
+	}
+
+	appID, err := uuid.Parse(step.ResourceID)
+	if err != nil {
+		return fmt.Errorf("invalid application ID %q for %s in apply journal: %w", step.ResourceID, appName, err)
+	}
+	r.activations[index] = &pendingActivation{
+		appID:    api.ApplicationID(appID),
+		appName:  appName,
+		version:  api.ApplicationVersionNumber(step.Version),
+		previous: r.previousActiveVersion(appName),
+	}
+	return nil
+}
+
+// previousActiveVersion returns the version of the application that was active before the apply (null if none).
+// It is taken from the plan, since the apply being resumed may have activated new versions already.
+func (r *applyRun) previousActiveVersion(appName string) api.NilInt32 {
+	if existing, ok := r.planned.Applications[appName]; ok {
+		return existing.Application.ActiveVersion
+	}
+	return api.NilInt32{Null: true}
+}
+
+// activateAll activates the deferred versions in plan order.
+// If an activation fails, the applications activated so far are rolled back to their previous active version.
+func (p *Provisioner) activateAll(ctx context.Context, run *applyRun) error {
+	var activated []*pendingActivation
+	for i, activation := range run.activations {
+		if activation == nil {
+			continue
+		}
+		if err := p.activateIfRequested(ctx, activation.appID, activation.appName, activation.version, run.opts); err != nil {
+			err = fmt.Errorf("failed to activate application %s: %w", activation.appName, err)
+			// Roll back even if the apply was cancelled
+			return errors.Join(err, p.rollbackActivations(context.WithoutCancel(ctx), activated))
+		}
+		activated = append(activated, activation)
+		run.recordActivated(i, *activation)
+	}
+	return nil
+}
+
+// rollbackActivations reactivates the previous version of the given applications, in reverse order.
+// The returned error reports the applications that were rolled back and those that could not be.
+func (p *Provisioner) rollbackActivations(ctx context.Context, activations []*pendingActivation) error {
+	if len(activations) == 0 {
+		return errors.New("no application had been activated, nothing was rolled back")
+	}
+
+	var (
+		rolledBack []string
+		errs       []error
+	)
+	for i := len(activations) - 1; i >= 0; i-- {
+		activation := activations[i]
+		err := p.client.UpdateApplication(ctx, &api.UpdateApplication{ActiveVersion: activation.previous}, api.UpdateApplicationParams{
+			ApplicationID: activation.appID,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to roll back application %s to %s (version %d is still active): %w",
+				activation.appName, describeActiveVersion(activation.previous), activation.version, wrapAPIError(err, "failed to update application")))
+			continue
+		}
+		log.Printf("Rolled back application %q from version %d to %s", activation.appName, activation.version, describeActiveVersion(activation.previous))
+		rolledBack = append(rolledBack, fmt.Sprintf("%s (version %d -> %s)", activation.appName, activation.version, describeActiveVersion(activation.previous)))
+	}
+
+	if len(rolledBack) > 0 {
+		errs = append([]error{fmt.Errorf("rolled back activations: %s", strings.Join(rolledBack, ", "))}, errs...)
+	}
+	return errors.Join(errs...)
+}
+
+// describeActiveVersion describes an active version for rollback messages
+func describeActiveVersion(version api.NilInt32) string {
+	if version.Null {
+		return "no active version"
+	}
+	return fmt.Sprintf("version %d", version.Value)
+}
@@ -0,0 +1,160 @@
+package provisioner
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/state"
+)
+
+func atomicActivateTestApp(name string) config.ApplicationConfig {
+	return config.ApplicationConfig{
+		Name: name,
+		Spec: config.ApplicationSpec{
+			CPU:         1000, // Changed from createTestVersion
+			Memory:      2048,
+			ScalingMode: "manual",
+			FixedScale:  int32Ptr(2),
+			Image:       "nginx:latest",
+			ExposedPorts: []config.ExposedPortConfig{
+				{TargetPort: 80, LoadBalancerPort: int32Ptr(443), UseLetsEncrypt: true},
+			},
+		},
+	}
+}
+
+func TestApply_AtomicActivate(t *testing.T) {
+	captureLog(t)
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	appA := createTestApplication(mockServer, clusterID, "app-a")
+	createTestVersion(mockServer, appA, 1, 500, 1024)
+	appB := createTestApplication(mockServer, clusterID, "app-b")
+	createTestVersion(mockServer, appB, 1, 500, 1024)
+
+	provisioner := NewProvisioner(client, state.NewState(), "")
+	cfg := &config.ClusterConfig{
+		ClusterName:  "my-cluster",
+		Applications: []config.ApplicationConfig{atomicActivateTestApp("app-a"), atomicActivateTestApp("app-b")},
+	}
+
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	require.NoError(t, provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{Activate: true, AtomicActivate: true}))
+
+	for _, name := range []string{"app-a", "app-b"} {
+		app, found := mockServer.GetApplicationByName(clusterID, name)
+		require.True(t, found)
+		assert.Equal(t, int32(2), app.ActiveVersion.Value, name)
+	}
+}
+
+func TestApply_AtomicActivate_RollsBackOnFailure(t *testing.T) {
+	captureLog(t)
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	appA := createTestApplication(mockServer, clusterID, "app-a")
+	createTestVersion(mockServer, appA, 1, 500, 1024)
+	appB := createTestApplication(mockServer, clusterID, "app-b")
+	createTestVersion(mockServer, appB, 1, 500, 1024)
+	mockServer.FailActivation(appB, 2)
+
+	provisioner := NewProvisioner(client, state.NewState(), "")
+	cfg := &config.ClusterConfig{
+		ClusterName: "my-cluster",
+		Applications: []config.ApplicationConfig{
+			atomicActivateTestApp("new-app"),
+			atomicActivateTestApp("app-a"),
+			atomicActivateTestApp("app-b"),
+		},
+	}
+
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	err = provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{Activate: true, AtomicActivate: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to activate application app-b")
+	assert.Contains(t, err.Error(), "rolled back activations: app-a (version 2 -> version 1), new-app (version 1 -> no active version)")
+
+	// All versions were created before activation
+	assert.Equal(t, 2, mockServer.VersionCount(appA))
+	assert.Equal(t, 2, mockServer.VersionCount(appB))
+
+	// Previous active versions are restored
+	app, _ := mockServer.GetApplicationByName(clusterID, "app-a")
+	assert.Equal(t, int32(1), app.ActiveVersion.Value)
+	app, _ = mockServer.GetApplicationByName(clusterID, "app-b")
+	assert.Equal(t, int32(1), app.ActiveVersion.Value)
+	app, found := mockServer.GetApplicationByName(clusterID, "new-app")
+	require.True(t, found)
+	assert.True(t, app.ActiveVersion.Null)
+}
+
+func TestApply_AtomicActivate_NothingToRollBack(t *testing.T) {
+	captureLog(t)
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	appA := createTestApplication(mockServer, clusterID, "app-a")
+	createTestVersion(mockServer, appA, 1, 500, 1024)
+	mockServer.FailActivation(appA, 2)
+
+	provisioner := NewProvisioner(client, state.NewState(), "")
+	cfg := &config.ClusterConfig{
+		ClusterName:  "my-cluster",
+		Applications: []config.ApplicationConfig{atomicActivateTestApp("app-a")},
+	}
+
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	err = provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{Activate: true, AtomicActivate: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "nothing was rolled back")
+
+	app, _ := mockServer.GetApplicationByName(clusterID, "app-a")
+	assert.Equal(t, int32(1), app.ActiveVersion.Value)
+}
+
+func TestApply_AtomicActivate_ResumeActivatesCompletedSteps(t *testing.T) {
+	captureLog(t)
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	appA := createTestApplication(mockServer, clusterID, "app-a")
+	createTestVersion(mockServer, appA, 1, 500, 1024)
+	mockServer.FailActivation(appA, 2)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	provisioner := NewProvisioner(client, state.NewState(), configPath)
+	cfg := &config.ClusterConfig{
+		ClusterName:  "my-cluster",
+		Applications: []config.ApplicationConfig{atomicActivateTestApp("app-a")},
+	}
+
+	// The first apply creates the version, then fails to activate it
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	require.Error(t, provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{Activate: true, AtomicActivate: true}))
+	assert.Equal(t, 2, mockServer.VersionCount(appA))
+
+	// Resuming activates the version created by the first apply without creating another
+	mockServer.ClearActivationFailures()
+	resumed, err := provisioner.ResumePlan()
+	require.NoError(t, err)
+	require.NoError(t, provisioner.Apply(context.Background(), cfg, resumed, ApplyOptions{Resume: true}))
+
+	assert.Equal(t, 2, mockServer.VersionCount(appA))
+	app, _ := mockServer.GetApplicationByName(clusterID, "app-a")
+	assert.Equal(t, int32(2), app.ActiveVersion.Value)
+}
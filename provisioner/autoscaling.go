@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -27,21 +30,19 @@ const (
 
 // ASGAction represents a planned action for an ASG
 type ASGAction struct {
-	Action  ASGActionType
-	Name    string
-	Changes []string
-	// For delete/recreate, we need the existing ASG ID
+	Action ASGActionType
+	Name   string
+	// Reason describes why the action is needed when it is not explained by field changes
+	Reason  string
+	Changes []FieldChange
+	// ExistingID is the ID of the existing ASG (nil for create).
+	// Delete/recreate use it, and saved plans use it to detect changes in the cluster.
 	ExistingID *api.AutoScalingGroupID
 }
 
-// planASGChanges compares current ASGs with desired and returns planned changes
-func (p *Provisioner) planASGChanges(ctx context.Context, clusterID uuid.UUID, desired []config.AutoScalingGroupConfig) ([]ASGAction, error) {
-	// Get current ASGs
-	currentASGs, err := p.listAllASGs(ctx, clusterID)
-	if err != nil {
-		return nil, err
-	}
-
+// planASGChanges compares current ASGs with desired and returns planned changes.
+// ASGs not in YAML are deleted if exclusive is true, otherwise skipped.
+func planASGChanges(currentASGs []api.ReadAutoScalingGroupDetail, desired []config.AutoScalingGroupConfig, exclusive bool) ([]ASGAction, error) {
 	// Build map of current ASGs by name
 	currentByName := make(map[string]api.ReadAutoScalingGroupDetail)
 	for _, asg := range currentASGs {
@@ -49,6 +50,7 @@ func (p *Provisioner) planASGChanges(ctx context.Context, clusterID uuid.UUID, d
 	}
 
 	var actions []ASGAction
+	var errs []error
 
 	// Check each desired ASG
 	desiredNames := make(map[string]bool)
@@ -66,7 +68,9 @@ func (p *Provisioner) planASGChanges(ctx context.Context, clusterID uuid.UUID, d
 		} else {
 			// ASG exists, check if settings differ
 			changes := compareASG(current, desiredASG)
-			if len(changes) > 0 {
+			if len(changes) > 0 && desiredASG.Lifecycle.PreventsDestroy() {
+				errs = append(errs, preventDestroyError(fmt.Sprintf("ASG %s", desiredASG.Name), changes))
+			} else if len(changes) > 0 {
 				// Settings differ, need to recreate (no update API)
 				asgID := current.AutoScalingGroupID
 				actions = append(actions, ASGAction{
@@ -76,21 +80,39 @@ func (p *Provisioner) planASGChanges(ctx context.Context, clusterID uuid.UUID, d
 					ExistingID: &asgID,
 				})
 			} else {
+				asgID := current.AutoScalingGroupID
 				actions = append(actions, ASGAction{
-					Action: ASGActionNoop,
-					Name:   desiredASG.Name,
+					Action:     ASGActionNoop,
+					Name:       desiredASG.Name,
+					ExistingID: &asgID,
 				})
 			}
 		}
 	}
 
-	// Check for ASGs not in YAML (skip instead of delete)
-	for name := range currentByName {
-		if !desiredNames[name] {
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	// Check for ASGs not in YAML (skip unless the infrastructure is managed exclusively)
+	for _, name := range slices.Sorted(maps.Keys(currentByName)) {
+		if desiredNames[name] {
+			continue
+		}
+		asgID := currentByName[name].AutoScalingGroupID
+		if exclusive {
 			actions = append(actions, ASGAction{
-				Action:  ASGActionSkip,
-				Name:    name,
-				Changes: []string{"not in YAML, skipping"},
+				Action:     ASGActionDelete,
+				Name:       name,
+				Reason:     "not in YAML (manageInfrastructure: exclusive)",
+				ExistingID: &asgID,
+			})
+		} else {
+			actions = append(actions, ASGAction{
+				Action:     ASGActionSkip,
+				Name:       name,
+				Reason:     "not in YAML, skipping",
+				ExistingID: &asgID,
 			})
 		}
 	}
@@ -98,6 +120,103 @@ func (p *Provisioner) planASGChanges(ctx context.Context, clusterID uuid.UUID, d
 	return actions, nil
 }
 
+// preventDestroyError returns the error for a resource with lifecycle.preventDestroy that the plan would recreate
+func preventDestroyError(resource string, changes []FieldChange) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s has lifecycle.preventDestroy set but would be recreated because these fields changed:", resource)
+	for _, change := range changes {
+		fmt.Fprintf(&b, "\n  %s", change)
+	}
+	return errors.New(b.String())
+}
+
+// addASGOperations adds the planned ASG deletes and creates to the apply graph
+func (p *Provisioner) addASGOperations(graph *resourceGraph, run *applyRun, actions []ASGAction) error {
+	for _, action := range actions {
+		op := resourceOperation{Key: asgKey(action.Name)}
+
+		if action.Action == ASGActionDelete || action.Action == ASGActionRecreate {
+			if action.ExistingID == nil {
+				return fmt.Errorf("cannot delete ASG %s: missing ID", action.Name)
+			}
+			asgID := *action.ExistingID
+			op.Delete = func(ctx context.Context) error {
+				if currentID, ok := run.asgID(action.Name); !ok || currentID != asgID {
+					// Deleted by an interrupted apply
+					logf(ctx, "ASG %s was already deleted", action.Name)
+					return nil
+				}
+
+				if action.Action == ASGActionDelete {
+					// Applications may have been deployed to the ASG since the plan was created
+					if err := p.checkASGUnused(ctx, run.clusterID, asgID, action.Name); err != nil {
+						return err
+					}
+				}
+
+				logf(ctx, "Deleting ASG: %s", action.Name)
+				err := p.client.DeleteAutoScalingGroup(ctx, api.DeleteAutoScalingGroupParams{
+					ClusterID:          api.ClusterID(run.clusterID),
+					AutoScalingGroupID: asgID,
+				})
+				if err != nil {
+					return wrapAPIError(err, fmt.Sprintf("failed to delete ASG %s", action.Name))
+				}
+
+				// Wait for ASG to be deleted
+				if err := p.waitForASGDeletion(ctx, run.clusterID, asgID, action.Name); err != nil {
+					return fmt.Errorf("failed waiting for ASG deletion: %w", err)
+				}
+
+				run.deleteASGID(action.Name)
+				return nil
+			}
+		}
+
+		if action.Action == ASGActionCreate || action.Action == ASGActionRecreate {
+			// Find the config for this ASG
+			var asgCfg *config.AutoScalingGroupConfig
+			for i := range run.cfg.AutoScalingGroups {
+				if run.cfg.AutoScalingGroups[i].Name == action.Name {
+					asgCfg = &run.cfg.AutoScalingGroups[i]
+					break
+				}
+			}
+			if asgCfg == nil {
+				return fmt.Errorf("cannot create ASG %s: config not found", action.Name)
+			}
+
+			op.Apply = func(ctx context.Context) error {
+				if run.interrupted(applyNodeID(op.Key)) {
+					if currentID, ok := run.asgID(action.Name); ok && (action.ExistingID == nil || currentID != *action.ExistingID) {
+						recordResourceID(ctx, uuid.UUID(currentID).String())
+						logf(ctx, "ASG %s was already created by the previous apply", action.Name)
+						return nil
+					}
+				}
+
+				logf(ctx, "Creating ASG: %s", action.Name)
+				req := buildCreateASGRequest(*asgCfg)
+				resp, err := p.client.CreateAutoScalingGroup(ctx, req, api.CreateAutoScalingGroupParams{
+					ClusterID: api.ClusterID(run.clusterID),
+				})
+				if err != nil {
+					return wrapAPIError(err, fmt.Sprintf("failed to create ASG %s", action.Name))
+				}
+				run.setASGID(action.Name, resp.AutoScalingGroup.AutoScalingGroupID)
+				recordResourceID(ctx, uuid.UUID(resp.AutoScalingGroup.AutoScalingGroupID).String())
+				return nil
+			}
+		}
+
+		if op.Delete != nil || op.Apply != nil {
+			graph.add(op)
+		}
+	}
+
+	return nil
+}
+
 // listAllASGs retrieves all ASGs for a cluster (handling pagination)
 func (p *Provisioner) listAllASGs(ctx context.Context, clusterID uuid.UUID) ([]api.ReadAutoScalingGroupDetail, error) {
 	var allASGs []api.ReadAutoScalingGroupDetail
@@ -124,29 +243,124 @@ func (p *Provisioner) listAllASGs(ctx context.Context, clusterID uuid.UUID) ([]a
 	return allASGs, nil
 }
 
+// checkASGUnused returns an error if any application still has active nodes on the ASG
+func (p *Provisioner) checkASGUnused(ctx context.Context, clusterID uuid.UUID, asgID api.AutoScalingGroupID, asgName string) error {
+	apps, err := p.applicationsOnASG(ctx, clusterID, asgID)
+	if err != nil {
+		return fmt.Errorf("failed to check applications on ASG %s: %w", asgName, err)
+	}
+	if len(apps) > 0 {
+		return fmt.Errorf("cannot delete ASG %s: applications still have active nodes on it: %s", asgName, strings.Join(apps, ", "))
+	}
+	return nil
+}
+
+// applicationsOnASG returns the sorted names of the applications with active containers on the ASG's worker nodes.
+// Containers of applications that no longer exist are ignored, since they are already being removed.
+func (p *Provisioner) applicationsOnASG(ctx context.Context, clusterID uuid.UUID, asgID api.AutoScalingGroupID) ([]string, error) {
+	nodes, err := p.listAllWorkerNodes(ctx, clusterID, asgID)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
+	// ListWorkerNodes does not include containers, so each node needs a detail request
+	activeAppIDs := make(map[api.ApplicationID]bool)
+	for _, node := range nodes {
+		resp, err := p.client.GetWorkerNode(ctx, api.GetWorkerNodeParams{
+			ClusterID:          api.ClusterID(clusterID),
+			AutoScalingGroupID: asgID,
+			WorkerNodeID:       node.WorkerNodeID,
+		})
+		if err != nil {
+			return nil, wrapAPIError(err, fmt.Sprintf("failed to get worker node %s", uuid.UUID(node.WorkerNodeID)))
+		}
+		for _, container := range resp.WorkerNode.RunningContainers {
+			if isActiveContainer(container.State) {
+				activeAppIDs[container.ApplicationID] = true
+			}
+		}
+	}
+	if len(activeAppIDs) == 0 {
+		return nil, nil
+	}
+
+	apps, err := p.listAllApplications(ctx, clusterID)
+	if err != nil {
+		return nil, wrapAPIError(err, "failed to list applications")
+	}
+	var names []string
+	for _, app := range apps {
+		if activeAppIDs[app.ApplicationID] {
+			names = append(names, app.Name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// isActiveContainer reports whether a container in the given state is serving or about to serve an application
+func isActiveContainer(state string) bool {
+	switch state {
+	case "exited", "dead", "removing":
+		return false
+	default:
+		return true
+	}
+}
+
+// listAllWorkerNodes retrieves all worker nodes of an ASG (handling pagination)
+func (p *Provisioner) listAllWorkerNodes(ctx context.Context, clusterID uuid.UUID, asgID api.AutoScalingGroupID) ([]api.ReadWorkerNodeSummary, error) {
+	var allNodes []api.ReadWorkerNodeSummary
+
+	params := api.ListWorkerNodesParams{
+		ClusterID:          api.ClusterID(clusterID),
+		AutoScalingGroupID: asgID,
+		MaxItems:           30,
+	}
+
+	for {
+		resp, err := p.client.ListWorkerNodes(ctx, params)
+		if err != nil {
+			return nil, wrapAPIError(err, "failed to list worker nodes")
+		}
+
+		allNodes = append(allNodes, resp.WorkerNodes...)
+
+		if !resp.NextCursor.Set {
+			break
+		}
+		params.Cursor = resp.NextCursor
+	}
+
+	return allNodes, nil
+}
+
 // compareASG compares current ASG with desired config and returns differences
-func compareASG(current api.ReadAutoScalingGroupDetail, desired config.AutoScalingGroupConfig) []string {
-	var changes []string
+func compareASG(current api.ReadAutoScalingGroupDetail, desired config.AutoScalingGroupConfig) []FieldChange {
+	var changes []FieldChange
 
 	if current.Zone != desired.Zone {
-		changes = append(changes, fmt.Sprintf("Zone: %s -> %s", current.Zone, desired.Zone))
+		changes = append(changes, updateChange(ResourceAutoScalingGroup, "Zone", current.Zone, desired.Zone))
 	}
 
 	if current.WorkerServiceClassPath != desired.WorkerServiceClassPath {
-		changes = append(changes, fmt.Sprintf("WorkerServiceClassPath: %s -> %s", current.WorkerServiceClassPath, desired.WorkerServiceClassPath))
+		changes = append(changes, updateChange(ResourceAutoScalingGroup, "WorkerServiceClassPath", current.WorkerServiceClassPath, desired.WorkerServiceClassPath))
 	}
 
 	if current.MinNodes != desired.MinNodes {
-		changes = append(changes, fmt.Sprintf("MinNodes: %d -> %d", current.MinNodes, desired.MinNodes))
+		changes = append(changes, updateChange(ResourceAutoScalingGroup, "MinNodes", current.MinNodes, desired.MinNodes))
 	}
 
 	if current.MaxNodes != desired.MaxNodes {
-		changes = append(changes, fmt.Sprintf("MaxNodes: %d -> %d", current.MaxNodes, desired.MaxNodes))
+		changes = append(changes, updateChange(ResourceAutoScalingGroup, "MaxNodes", current.MaxNodes, desired.MaxNodes))
 	}
 
 	// Compare NameServers
 	if !compareNameServers(current.NameServers, desired.NameServers) {
-		changes = append(changes, fmt.Sprintf("NameServers: %v -> %v", current.NameServers, desired.NameServers))
+		changes = append(changes, updateChange(ResourceAutoScalingGroup, "NameServers", current.NameServers, desired.NameServers))
 	}
 
 	// Compare Interfaces
@@ -170,11 +384,11 @@ func compareNameServers(current []api.IPv4, desired []string) bool {
 }
 
 // compareASGInterfaces compares interface configurations
-func compareASGInterfaces(current []api.AutoScalingGroupNodeInterface, desired []config.ASGInterfaceConfig) []string {
-	var changes []string
+func compareASGInterfaces(current []api.AutoScalingGroupNodeInterface, desired []config.ASGInterfaceConfig) []FieldChange {
+	var changes []FieldChange
 
 	if len(current) != len(desired) {
-		changes = append(changes, fmt.Sprintf("Interfaces count: %d -> %d", len(current), len(desired)))
+		changes = append(changes, updateChange(ResourceAutoScalingGroup, "Interfaces", len(current), len(desired)))
 		return changes
 	}
 
@@ -192,16 +406,16 @@ func compareASGInterfaces(current []api.AutoScalingGroupNodeInterface, desired [
 	for idx, desiredIface := range desiredByIdx {
 		currentIface, exists := currentByIdx[idx]
 		if !exists {
-			changes = append(changes, fmt.Sprintf("Interface[%d]: new interface", idx))
+			changes = append(changes, addChange(ResourceAutoScalingGroup, fmt.Sprintf("Interface[%d].Upstream", idx), desiredIface.Upstream))
 			continue
 		}
 
 		if currentIface.Upstream != desiredIface.Upstream {
-			changes = append(changes, fmt.Sprintf("Interface[%d].Upstream: %s -> %s", idx, currentIface.Upstream, desiredIface.Upstream))
+			changes = append(changes, updateChange(ResourceAutoScalingGroup, fmt.Sprintf("Interface[%d].Upstream", idx), currentIface.Upstream, desiredIface.Upstream))
 		}
 
 		if currentIface.ConnectsToLB != desiredIface.ConnectsToLB {
-			changes = append(changes, fmt.Sprintf("Interface[%d].ConnectsToLB: %v -> %v", idx, currentIface.ConnectsToLB, desiredIface.ConnectsToLB))
+			changes = append(changes, updateChange(ResourceAutoScalingGroup, fmt.Sprintf("Interface[%d].ConnectsToLB", idx), currentIface.ConnectsToLB, desiredIface.ConnectsToLB))
 		}
 
 		// Compare NetmaskLen
@@ -214,7 +428,7 @@ func compareASGInterfaces(current []api.AutoScalingGroupNodeInterface, desired [
 			desiredNetmask = *desiredIface.NetmaskLen
 		}
 		if currentNetmask != desiredNetmask {
-			changes = append(changes, fmt.Sprintf("Interface[%d].NetmaskLen: %d -> %d", idx, currentNetmask, desiredNetmask))
+			changes = append(changes, updateChange(ResourceAutoScalingGroup, fmt.Sprintf("Interface[%d].NetmaskLen", idx), currentNetmask, desiredNetmask))
 		}
 
 		// Compare DefaultGateway
@@ -227,7 +441,7 @@ func compareASGInterfaces(current []api.AutoScalingGroupNodeInterface, desired [
 			desiredGW = *desiredIface.DefaultGateway
 		}
 		if currentGW != desiredGW {
-			changes = append(changes, fmt.Sprintf("Interface[%d].DefaultGateway: %s -> %s", idx, currentGW, desiredGW))
+			changes = append(changes, updateChange(ResourceAutoScalingGroup, fmt.Sprintf("Interface[%d].DefaultGateway", idx), currentGW, desiredGW))
 		}
 
 		// Compare PacketFilterID
@@ -240,12 +454,12 @@ func compareASGInterfaces(current []api.AutoScalingGroupNodeInterface, desired [
 			desiredPF = *desiredIface.PacketFilterID
 		}
 		if currentPF != desiredPF {
-			changes = append(changes, fmt.Sprintf("Interface[%d].PacketFilterID: %s -> %s", idx, currentPF, desiredPF))
+			changes = append(changes, updateChange(ResourceAutoScalingGroup, fmt.Sprintf("Interface[%d].PacketFilterID", idx), currentPF, desiredPF))
 		}
 
 		// Compare IpPool
 		if !compareIPPools(currentIface.IpPool, desiredIface.IpPool) {
-			changes = append(changes, fmt.Sprintf("Interface[%d].IpPool: changed", idx))
+			changes = append(changes, updateChange(ResourceAutoScalingGroup, fmt.Sprintf("Interface[%d].IpPool", idx), formatIPPool(currentIface.IpPool), formatIPPoolConfig(desiredIface.IpPool)))
 		}
 	}
 
@@ -265,14 +479,33 @@ func compareIPPools(current []api.IpRange, desired []config.IpRangeConfig) bool
 	return true
 }
 
-// describeASGConfig returns a description of ASG configuration for plan output
-func describeASGConfig(cfg config.AutoScalingGroupConfig) []string {
-	return []string{
-		fmt.Sprintf("Zone: %s", cfg.Zone),
-		fmt.Sprintf("WorkerServiceClassPath: %s", cfg.WorkerServiceClassPath),
-		fmt.Sprintf("MinNodes: %d, MaxNodes: %d", cfg.MinNodes, cfg.MaxNodes),
-		fmt.Sprintf("NameServers: %v", cfg.NameServers),
-		fmt.Sprintf("Interfaces: %d configured", len(cfg.Interfaces)),
+// formatIPPool formats an IP pool returned by the API for plan output
+func formatIPPool(pool []api.IpRange) string {
+	ranges := make([]string, 0, len(pool))
+	for _, r := range pool {
+		ranges = append(ranges, fmt.Sprintf("%s-%s", r.Start, r.End))
+	}
+	return fmt.Sprint(ranges)
+}
+
+// formatIPPoolConfig formats an IP pool in the config for plan output
+func formatIPPoolConfig(pool []config.IpRangeConfig) string {
+	ranges := make([]string, 0, len(pool))
+	for _, r := range pool {
+		ranges = append(ranges, fmt.Sprintf("%s-%s", r.Start, r.End))
+	}
+	return fmt.Sprint(ranges)
+}
+
+// describeASGConfig returns the configuration of a new ASG for plan output
+func describeASGConfig(cfg config.AutoScalingGroupConfig) []FieldChange {
+	return []FieldChange{
+		addChange(ResourceAutoScalingGroup, "Zone", cfg.Zone),
+		addChange(ResourceAutoScalingGroup, "WorkerServiceClassPath", cfg.WorkerServiceClassPath),
+		addChange(ResourceAutoScalingGroup, "MinNodes", cfg.MinNodes),
+		addChange(ResourceAutoScalingGroup, "MaxNodes", cfg.MaxNodes),
+		addChange(ResourceAutoScalingGroup, "NameServers", cfg.NameServers),
+		addChange(ResourceAutoScalingGroup, "Interfaces", len(cfg.Interfaces)),
 	}
 }
 
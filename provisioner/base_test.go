@@ -0,0 +1,125 @@
+package provisioner
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/api"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/testutil"
+)
+
+// setupBaseCluster creates an application whose active version 1 matches testClusterConfig(500)
+// and an unactivated experimental version 2 with a different CPU and image
+func setupBaseCluster(t *testing.T) (*testutil.MockServer, *Provisioner, api.ApplicationID, func()) {
+	mockServer, provisioner, clusterID, cleanup := setupTestCluster(t, filepath.Join(t.TempDir(), "config.yaml"), "existing-app")
+	appID := testAppID(mockServer, clusterID, "existing-app")
+	mockServer.AddApplicationVersion(appID, api.ReadApplicationVersionDetail{
+		Version:     2,
+		CPU:         1000,
+		Memory:      1024,
+		ScalingMode: api.ScalingModeManual,
+		FixedScale:  api.OptInt32{Value: 2, Set: true},
+		Image:       "nginx:experimental",
+		ExposedPorts: []api.ExposedPort{
+			{
+				TargetPort:       80,
+				LoadBalancerPort: api.NilPort{Value: 443, Null: false},
+				UseLetsEncrypt:   true,
+				HealthCheck:      api.NilHealthCheck{Null: true},
+			},
+		},
+	})
+	return mockServer, provisioner, appID, cleanup
+}
+
+func TestCreatePlan_Base_LatestByDefault(t *testing.T) {
+	_, provisioner, _, cleanup := setupBaseCluster(t)
+	defer cleanup()
+
+	plan, err := provisioner.CreatePlan(context.Background(), testClusterConfig(500), PlanOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, plan.Actions, 1)
+	action := plan.Actions[0]
+	assert.Equal(t, ActionUpdate, action.Action)
+	assert.Equal(t, 2, action.LatestVersion)
+	assert.Equal(t, 2, action.BaseVersion)
+	assert.Equal(t, []string{"CPU: 1000 -> 500"}, FormatChanges(action.Changes))
+}
+
+func TestCreatePlan_Base_Active(t *testing.T) {
+	_, provisioner, _, cleanup := setupBaseCluster(t)
+	defer cleanup()
+
+	cfg := testClusterConfig(500)
+	cfg.Base = config.BaseActive
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, plan.Actions, 1)
+	action := plan.Actions[0]
+	assert.Equal(t, ActionNoop, action.Action)
+	assert.Equal(t, 2, action.LatestVersion)
+	assert.Equal(t, 1, action.BaseVersion)
+}
+
+func TestCreatePlan_Base_ApplicationOverridesGlobal(t *testing.T) {
+	_, provisioner, _, cleanup := setupBaseCluster(t)
+	defer cleanup()
+
+	cfg := testClusterConfig(500)
+	cfg.Base = config.BaseActive
+	cfg.Applications[0].Base = config.BaseLatest
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, plan.Actions, 1)
+	assert.Equal(t, ActionUpdate, plan.Actions[0].Action)
+	assert.Equal(t, 2, plan.Actions[0].BaseVersion)
+}
+
+func TestCreatePlan_Base_ActiveFallsBackToLatest(t *testing.T) {
+	mockServer, provisioner, clusterID, cleanup := setupTestCluster(t, "", "existing-app")
+	defer cleanup()
+
+	appID := testAppID(mockServer, clusterID, "existing-app")
+	app, _ := mockServer.GetApplicationByName(clusterID, "existing-app")
+	app.ActiveVersion = api.NilInt32{Null: true}
+	mockServer.AddApplication(app)
+	createTestVersion(mockServer, appID, 2, 1000, 1024)
+
+	cfg := testClusterConfig(1000)
+	cfg.Base = config.BaseActive
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, plan.Actions, 1)
+	assert.Equal(t, ActionNoop, plan.Actions[0].Action)
+	assert.Equal(t, 2, plan.Actions[0].BaseVersion)
+}
+
+func TestApply_Base_ActiveInheritsFromActiveVersion(t *testing.T) {
+	captureLog(t)
+	mockServer, provisioner, appID, cleanup := setupBaseCluster(t)
+	defer cleanup()
+
+	cfg := testClusterConfig(600)
+	cfg.Base = config.BaseActive
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	require.Len(t, plan.Actions, 1)
+	assert.Equal(t, []string{"CPU: 500 -> 600"}, FormatChanges(plan.Actions[0].Changes))
+
+	require.NoError(t, provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{}))
+
+	newVersion, found := mockServer.GetApplicationVersionByKey(appID, 3)
+	require.True(t, found)
+	assert.Equal(t, int64(600), newVersion.CPU)
+	// The image is inherited from the active version, not from the experimental one
+	assert.Equal(t, "nginx:latest", newVersion.Image)
+}
@@ -0,0 +1,227 @@
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/api"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+)
+
+// Resources is an amount of CPU and memory
+type Resources struct {
+	CPU    int64 // mCPU
+	Memory int64 // MB
+}
+
+func (r Resources) add(other Resources) Resources {
+	return Resources{CPU: r.CPU + other.CPU, Memory: r.Memory + other.Memory}
+}
+
+func (r Resources) times(n int32) Resources {
+	return Resources{CPU: r.CPU * int64(n), Memory: r.Memory * int64(n)}
+}
+
+// ASGCapacity is the capacity of an ASG that remains after the plan is applied
+type ASGCapacity struct {
+	Name                   string
+	WorkerServiceClassPath string
+	// Node is the capacity of a worker node (zero if unknown)
+	Node     Resources
+	MinNodes int32
+	MaxNodes int32
+}
+
+// AppDemand is the resources requested by an application in the config
+type AppDemand struct {
+	Name     string
+	Instance Resources
+	MinScale int32
+	MaxScale int32
+}
+
+// CapacityProblem is a finding of the capacity check
+type CapacityProblem struct {
+	Severity Severity
+	Message  string
+}
+
+// CapacityReport compares the resources requested by the applications at their minimum and maximum scale
+// with the capacity of the ASGs at minNodes and maxNodes
+type CapacityReport struct {
+	ASGs         []ASGCapacity
+	Applications []AppDemand
+	MinSupply    Resources
+	MaxSupply    Resources
+	MinDemand    Resources
+	MaxDemand    Resources
+	Problems     []CapacityProblem
+}
+
+// HasErrors reports whether the applications cannot run within the capacity of the ASGs
+func (r *CapacityReport) HasErrors() bool {
+	return r != nil && slices.ContainsFunc(r.Problems, func(p CapacityProblem) bool { return p.Severity == SeverityError })
+}
+
+func (r *CapacityReport) addProblem(severity Severity, format string, args ...any) {
+	r.Problems = append(r.Problems, CapacityProblem{Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+var (
+	// Worker service classes do not expose their size, so it is read from the path or the name (e.g. "1core-2gb")
+	workerClassCPUPattern    = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*(?:core|vcpu|コア)`)
+	workerClassMemoryPattern = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*gb`)
+)
+
+// parseWorkerServiceClass returns the capacity of a worker node of the service class
+func parseWorkerServiceClass(class api.ReadWorkerServiceClass) (Resources, bool) {
+	find := func(pattern *regexp.Regexp) (float64, bool) {
+		for _, s := range []string{class.Path, class.Name} {
+			if m := pattern.FindStringSubmatch(s); m != nil {
+				v, err := strconv.ParseFloat(m[1], 64)
+				return v, err == nil
+			}
+		}
+		return 0, false
+	}
+
+	cores, ok := find(workerClassCPUPattern)
+	if !ok {
+		return Resources{}, false
+	}
+	gb, ok := find(workerClassMemoryPattern)
+	if !ok {
+		return Resources{}, false
+	}
+	return Resources{CPU: int64(cores * 1000), Memory: int64(gb * 1024)}, true
+}
+
+// appScale returns the minimum and maximum scale of the application, or false if they are inherited
+func appScale(spec *config.ApplicationSpec) (int32, int32, bool) {
+	if spec.ScalingMode == "manual" {
+		if spec.FixedScale == nil {
+			return 0, 0, false
+		}
+		return *spec.FixedScale, *spec.FixedScale, true
+	}
+	if spec.MinScale == nil || spec.MaxScale == nil {
+		return 0, 0, false
+	}
+	return *spec.MinScale, *spec.MaxScale, true
+}
+
+// planCapacity checks whether the applications in the config fit in the ASGs that remain after the plan.
+// ASGs the plan does not touch, including those left out by targets, keep their current size.
+// It returns nil if no ASGs remain.
+func (p *Provisioner) planCapacity(ctx context.Context, cfg *config.ClusterConfig, currentASGs []api.ReadAutoScalingGroupDetail, asgActions []ASGAction) (*CapacityReport, error) {
+	planned := make(map[string]ASGActionType)
+	for _, action := range asgActions {
+		planned[action.Name] = action.Action
+	}
+
+	var asgs []ASGCapacity
+	for _, asg := range cfg.AutoScalingGroups {
+		if _, ok := planned[asg.Name]; !ok {
+			continue
+		}
+		asgs = append(asgs, ASGCapacity{
+			Name:                   asg.Name,
+			WorkerServiceClassPath: asg.WorkerServiceClassPath,
+			MinNodes:               asg.MinNodes,
+			MaxNodes:               asg.MaxNodes,
+		})
+	}
+	for _, asg := range currentASGs {
+		// ASGs the plan creates, recreates or keeps are counted from the config, and deleted ASGs are gone
+		if action, ok := planned[asg.Name]; ok && action != ASGActionSkip {
+			continue
+		}
+		asgs = append(asgs, ASGCapacity{
+			Name:                   asg.Name,
+			WorkerServiceClassPath: asg.WorkerServiceClassPath,
+			MinNodes:               asg.MinNodes,
+			MaxNodes:               asg.MaxNodes,
+		})
+	}
+	if len(asgs) == 0 {
+		return nil, nil
+	}
+
+	resp, err := p.client.ListWorkerServiceClasses(ctx)
+	if err != nil {
+		return nil, wrapAPIError(err, "failed to list worker service classes")
+	}
+	classes := make(map[string]api.ReadWorkerServiceClass)
+	for _, class := range resp.WorkerServiceClasses {
+		classes[class.Path] = class
+	}
+
+	report := &CapacityReport{}
+	var largest Resources
+	known := true
+	for i := range asgs {
+		asg := &asgs[i]
+		class, ok := classes[asg.WorkerServiceClassPath]
+		if !ok {
+			report.addProblem(SeverityWarning, "ASG %s: worker service class %s was not found", asg.Name, asg.WorkerServiceClassPath)
+			known = false
+			continue
+		}
+		node, ok := parseWorkerServiceClass(class)
+		if !ok {
+			report.addProblem(SeverityWarning, "ASG %s: the CPU and memory of worker service class %s (%s) are unknown", asg.Name, class.Path, class.Name)
+			known = false
+			continue
+		}
+		asg.Node = node
+		report.MinSupply = report.MinSupply.add(node.times(asg.MinNodes))
+		report.MaxSupply = report.MaxSupply.add(node.times(asg.MaxNodes))
+		largest = Resources{CPU: max(largest.CPU, node.CPU), Memory: max(largest.Memory, node.Memory)}
+	}
+	report.ASGs = asgs
+
+	for _, app := range cfg.Applications {
+		minScale, maxScale, ok := appScale(&app.Spec)
+		if !ok {
+			report.addProblem(SeverityWarning, "application %s: the scale is inherited from the existing version and is not checked", app.Name)
+			continue
+		}
+		demand := AppDemand{
+			Name:     app.Name,
+			Instance: Resources{CPU: app.Spec.CPU, Memory: app.Spec.Memory},
+			MinScale: minScale,
+			MaxScale: maxScale,
+		}
+		report.Applications = append(report.Applications, demand)
+		report.MinDemand = report.MinDemand.add(demand.Instance.times(minScale))
+		report.MaxDemand = report.MaxDemand.add(demand.Instance.times(maxScale))
+
+		if known && (demand.Instance.CPU > largest.CPU || demand.Instance.Memory > largest.Memory) {
+			report.addProblem(SeverityError, "application %s needs %d mCPU and %d MB per instance, but the largest worker node has %d mCPU and %d MB",
+				app.Name, demand.Instance.CPU, demand.Instance.Memory, largest.CPU, largest.Memory)
+		}
+	}
+
+	if !known {
+		report.addProblem(SeverityWarning, "the total capacity is not checked because the capacity of some ASGs is unknown")
+		return report, nil
+	}
+
+	checkSupply := func(resource, unit string, minDemand, maxDemand, minSupply, maxSupply int64) {
+		switch {
+		case minDemand > maxSupply:
+			report.addProblem(SeverityError, "applications need %d %s of %s at their minimum scale, but the ASGs provide at most %d %s", minDemand, unit, resource, maxSupply, unit)
+		case minDemand > minSupply:
+			report.addProblem(SeverityWarning, "applications need %d %s of %s at their minimum scale, but the ASGs provide %d %s at minNodes", minDemand, unit, resource, minSupply, unit)
+		case maxDemand > maxSupply:
+			report.addProblem(SeverityWarning, "applications need up to %d %s of %s at their maximum scale, but the ASGs provide at most %d %s", maxDemand, unit, resource, maxSupply, unit)
+		}
+	}
+	checkSupply("CPU", "mCPU", report.MinDemand.CPU, report.MaxDemand.CPU, report.MinSupply.CPU, report.MaxSupply.CPU)
+	checkSupply("memory", "MB", report.MinDemand.Memory, report.MaxDemand.Memory, report.MinSupply.Memory, report.MaxSupply.Memory)
+
+	return report, nil
+}
@@ -0,0 +1,201 @@
+package provisioner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/api"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/testutil"
+)
+
+// capacityTestConfig returns a config with an ASG of 1-2 nodes of 1 core and 2 GB,
+// and an application of fixedScale 2
+func capacityTestConfig(cpu, memory int64) *config.ClusterConfig {
+	cfg := testClusterConfig(cpu)
+	cfg.Applications[0].Spec.Memory = memory
+	asg := testASGConfig("web-asg")
+	asg.WorkerServiceClassPath = "cloud/plan/ssd/1core-2gb"
+	cfg.AutoScalingGroups = []config.AutoScalingGroupConfig{asg}
+	return cfg
+}
+
+func setupCapacityCluster(t *testing.T) (*testutil.MockServer, *Provisioner, func()) {
+	mockServer, provisioner, _, cleanup := setupTestCluster(t, "")
+	mockServer.AddWorkerServiceClass("cloud/plan/ssd/1core-2gb", "1コア 2GB")
+	return mockServer, provisioner, cleanup
+}
+
+func capacityProblems(report *CapacityReport) []string {
+	var problems []string
+	for _, problem := range report.Problems {
+		problems = append(problems, string(problem.Severity)+": "+problem.Message)
+	}
+	return problems
+}
+
+func TestParseWorkerServiceClass(t *testing.T) {
+	tests := []struct {
+		class api.ReadWorkerServiceClass
+		want  Resources
+		ok    bool
+	}{
+		{class: api.ReadWorkerServiceClass{Path: "cloud/plan/ssd/1core-2gb"}, want: Resources{CPU: 1000, Memory: 2048}, ok: true},
+		{class: api.ReadWorkerServiceClass{Path: "cloud/plan/ssd/4Core-16GB"}, want: Resources{CPU: 4000, Memory: 16384}, ok: true},
+		{class: api.ReadWorkerServiceClass{Path: "cloud/plan/worker-small", Name: "2コア 4GB"}, want: Resources{CPU: 2000, Memory: 4096}, ok: true},
+		{class: api.ReadWorkerServiceClass{Path: "cloud/plan/worker", Name: "Standard"}, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.class.Path, func(t *testing.T) {
+			got, ok := parseWorkerServiceClass(tt.class)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCreatePlan_Capacity_Fits(t *testing.T) {
+	_, provisioner, cleanup := setupCapacityCluster(t)
+	defer cleanup()
+
+	plan, err := provisioner.CreatePlan(context.Background(), capacityTestConfig(500, 1024), PlanOptions{})
+	require.NoError(t, err)
+
+	report := plan.Capacity
+	require.NotNil(t, report)
+	assert.Equal(t, Resources{CPU: 1000, Memory: 2048}, report.MinDemand)
+	assert.Equal(t, Resources{CPU: 1000, Memory: 2048}, report.MaxDemand)
+	assert.Equal(t, Resources{CPU: 1000, Memory: 2048}, report.MinSupply)
+	assert.Equal(t, Resources{CPU: 2000, Memory: 4096}, report.MaxSupply)
+	assert.Empty(t, report.Problems)
+	assert.False(t, report.HasErrors())
+}
+
+func TestCreatePlan_Capacity_MaxScaleExceedsSupply(t *testing.T) {
+	_, provisioner, cleanup := setupCapacityCluster(t)
+	defer cleanup()
+
+	cfg := capacityTestConfig(500, 1024)
+	spec := &cfg.Applications[0].Spec
+	spec.ScalingMode = "cpu"
+	spec.FixedScale = nil
+	spec.MinScale = int32Ptr(1)
+	spec.MaxScale = int32Ptr(6)
+
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"warning: applications need up to 3000 mCPU of CPU at their maximum scale, but the ASGs provide at most 2000 mCPU",
+		"warning: applications need up to 6144 MB of memory at their maximum scale, but the ASGs provide at most 4096 MB",
+	}, capacityProblems(plan.Capacity))
+	assert.False(t, plan.Capacity.HasErrors())
+}
+
+func TestCreatePlan_Capacity_MinScaleExceedsSupply(t *testing.T) {
+	_, provisioner, cleanup := setupCapacityCluster(t)
+	defer cleanup()
+
+	cfg := capacityTestConfig(1000, 1024)
+	cfg.Applications[0].Spec.FixedScale = int32Ptr(3)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"error: applications need 3000 mCPU of CPU at their minimum scale, but the ASGs provide at most 2000 mCPU",
+		"warning: applications need 3072 MB of memory at their minimum scale, but the ASGs provide 2048 MB at minNodes",
+	}, capacityProblems(plan.Capacity))
+	assert.True(t, plan.Capacity.HasErrors())
+}
+
+func TestCreatePlan_Capacity_MinScaleExceedsMinNodes(t *testing.T) {
+	_, provisioner, cleanup := setupCapacityCluster(t)
+	defer cleanup()
+
+	// 3 instances fit in 2 nodes, but not in the single node at minNodes
+	cfg := capacityTestConfig(500, 512)
+	cfg.Applications[0].Spec.FixedScale = int32Ptr(3)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"warning: applications need 1500 mCPU of CPU at their minimum scale, but the ASGs provide 1000 mCPU at minNodes",
+	}, capacityProblems(plan.Capacity))
+	assert.False(t, plan.Capacity.HasErrors())
+}
+
+func TestCreatePlan_Capacity_InstanceLargerThanNode(t *testing.T) {
+	_, provisioner, cleanup := setupCapacityCluster(t)
+	defer cleanup()
+
+	cfg := capacityTestConfig(500, 4096)
+	cfg.Applications[0].Spec.FixedScale = int32Ptr(1)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"error: application existing-app needs 500 mCPU and 4096 MB per instance, but the largest worker node has 1000 mCPU and 2048 MB",
+		"warning: applications need 4096 MB of memory at their minimum scale, but the ASGs provide 2048 MB at minNodes",
+	}, capacityProblems(plan.Capacity))
+}
+
+func TestCreatePlan_Capacity_UnknownWorkerServiceClass(t *testing.T) {
+	mockServer, provisioner, cleanup := setupCapacityCluster(t)
+	defer cleanup()
+	mockServer.AddWorkerServiceClass("cloud/plan/worker", "Standard")
+
+	// An existing ASG not in the config still runs applications
+	cluster, _ := mockServer.GetClusterByName("my-cluster")
+	createTestASG(mockServer, cluster.ClusterID, "old-asg")
+
+	plan, err := provisioner.CreatePlan(context.Background(), capacityTestConfig(500, 1024), PlanOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, plan.Capacity.ASGs, 2)
+	assert.Equal(t, "old-asg", plan.Capacity.ASGs[1].Name)
+	assert.Equal(t, []string{
+		"warning: ASG old-asg: the CPU and memory of worker service class cloud/plan/worker (Standard) are unknown",
+		"warning: the total capacity is not checked because the capacity of some ASGs is unknown",
+	}, capacityProblems(plan.Capacity))
+	assert.False(t, plan.Capacity.HasErrors())
+}
+
+func TestCreatePlan_Capacity_TargetedKeepsCurrentASGs(t *testing.T) {
+	captureLog(t)
+	mockServer, provisioner, cleanup := setupCapacityCluster(t)
+	defer cleanup()
+	mockServer.AddWorkerServiceClass("cloud/plan/worker", "2コア 4GB")
+
+	// The config recreates web-asg with smaller nodes
+	cluster, _ := mockServer.GetClusterByName("my-cluster")
+	createTestASG(mockServer, cluster.ClusterID, "web-asg")
+	cfg := capacityTestConfig(500, 1024)
+
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, Resources{CPU: 2000, Memory: 4096}, plan.Capacity.MaxSupply)
+
+	// The recreation is left out by the target, so web-asg keeps its current nodes
+	plan, err = provisioner.CreatePlan(context.Background(), cfg, PlanOptions{
+		Targets: []Target{{Kind: TargetApplication, Name: "existing-app"}},
+	})
+	require.NoError(t, err)
+	require.Empty(t, plan.ASGActions)
+	require.Len(t, plan.Capacity.ASGs, 1)
+	assert.Equal(t, "cloud/plan/worker", plan.Capacity.ASGs[0].WorkerServiceClassPath)
+	assert.Equal(t, Resources{CPU: 2000, Memory: 4096}, plan.Capacity.MinSupply)
+	assert.Equal(t, Resources{CPU: 4000, Memory: 8192}, plan.Capacity.MaxSupply)
+}
+
+func TestCreatePlan_Capacity_NoASGs(t *testing.T) {
+	_, provisioner, cleanup := setupCapacityCluster(t)
+	defer cleanup()
+
+	plan, err := provisioner.CreatePlan(context.Background(), testClusterConfig(500), PlanOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, plan.Capacity)
+}
@@ -0,0 +1,116 @@
+package provisioner
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResourceKind represents the kind of resource a change belongs to
+type ResourceKind string
+
+const (
+	ResourceApplication      ResourceKind = "application"
+	ResourceAutoScalingGroup ResourceKind = "autoScalingGroup"
+	ResourceLoadBalancer     ResourceKind = "loadBalancer"
+)
+
+// ChangeKind represents how a field changes
+type ChangeKind string
+
+const (
+	ChangeAdd    ChangeKind = "add"
+	ChangeUpdate ChangeKind = "update"
+	ChangeRemove ChangeKind = "remove"
+)
+
+// envPathPrefix is the path prefix of environment variable changes (e.g., "Env.LOG_LEVEL")
+const envPathPrefix = "Env."
+
+// FieldChange represents a change of a single field.
+// Old is empty for ChangeAdd and New is empty for ChangeRemove.
+type FieldChange struct {
+	Resource ResourceKind
+	// Path is the field path, e.g. "CPU", "ExposedPorts.8080.HealthCheckPath", "Env.LOG_LEVEL"
+	// or "Interface[1].Upstream"
+	Path string
+	Kind ChangeKind
+	Old  string
+	New  string
+	// Sensitive marks changes of secret fields (secret env vars and registry password).
+	// Secret values are never stored in Old/New; they hold the secret versions instead.
+	Sensitive bool
+}
+
+// String returns a human-readable representation of the change
+func (c FieldChange) String() string {
+	if key, ok := strings.CutPrefix(c.Path, envPathPrefix); ok {
+		return c.formatEnv(key)
+	}
+
+	switch c.Kind {
+	case ChangeAdd:
+		return fmt.Sprintf("%s: (unset) -> %s", c.Path, c.New)
+	case ChangeRemove:
+		return fmt.Sprintf("%s: %s -> (unset)", c.Path, c.Old)
+	default:
+		return fmt.Sprintf("%s: %s -> %s", c.Path, c.Old, c.New)
+	}
+}
+
+// formatEnv formats a change of an environment variable
+func (c FieldChange) formatEnv(key string) string {
+	switch c.Kind {
+	case ChangeAdd:
+		if c.Sensitive {
+			return fmt.Sprintf("Env add: %s (secret)", key)
+		}
+		if c.New != "" {
+			return fmt.Sprintf("Env add: %s=%s", key, c.New)
+		}
+		return fmt.Sprintf("Env add: %s", key)
+	case ChangeRemove:
+		if c.Sensitive {
+			return fmt.Sprintf("Env remove: %s (secret)", key)
+		}
+		return fmt.Sprintf("Env remove: %s", key)
+	default:
+		if c.Sensitive {
+			oldVersion := c.Old
+			if oldVersion == "" {
+				oldVersion = "new"
+			}
+			return fmt.Sprintf("Env update: %s (secret, version: %s -> %s)", key, oldVersion, c.New)
+		}
+		return fmt.Sprintf("Env update: %s=%s -> %s", key, c.Old, c.New)
+	}
+}
+
+// FormatChanges returns human-readable representations of the changes
+func FormatChanges(changes []FieldChange) []string {
+	lines := make([]string, 0, len(changes))
+	for _, c := range changes {
+		lines = append(lines, c.String())
+	}
+	return lines
+}
+
+// updateChange creates a ChangeUpdate for the given resource kind
+func updateChange(resource ResourceKind, path string, oldValue, newValue any) FieldChange {
+	return FieldChange{
+		Resource: resource,
+		Path:     path,
+		Kind:     ChangeUpdate,
+		Old:      fmt.Sprint(oldValue),
+		New:      fmt.Sprint(newValue),
+	}
+}
+
+// addChange creates a ChangeAdd for the given resource kind
+func addChange(resource ResourceKind, path string, newValue any) FieldChange {
+	return FieldChange{
+		Resource: resource,
+		Path:     path,
+		Kind:     ChangeAdd,
+		New:      fmt.Sprint(newValue),
+	}
+}
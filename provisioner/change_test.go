@@ -0,0 +1,116 @@
+package provisioner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/api"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/state"
+)
+
+func TestFieldChange_String(t *testing.T) {
+	tests := []struct {
+		name   string
+		change FieldChange
+		want   string
+	}{
+		{
+			name:   "update",
+			change: updateChange(ResourceApplication, "CPU", 500, 1000),
+			want:   "CPU: 500 -> 1000",
+		},
+		{
+			name:   "add",
+			change: addChange(ResourceApplication, "ExposedPorts.8080.TargetPort", 8080),
+			want:   "ExposedPorts.8080.TargetPort: (unset) -> 8080",
+		},
+		{
+			name:   "remove",
+			change: FieldChange{Resource: ResourceApplication, Path: "Cmd", Kind: ChangeRemove, Old: "[serve]"},
+			want:   "Cmd: [serve] -> (unset)",
+		},
+		{
+			name:   "env add",
+			change: FieldChange{Resource: ResourceApplication, Path: "Env.APP_ENV", Kind: ChangeAdd, New: "production"},
+			want:   "Env add: APP_ENV=production",
+		},
+		{
+			name:   "secret env add",
+			change: FieldChange{Resource: ResourceApplication, Path: "Env.DB_PASSWORD", Kind: ChangeAdd, New: "1", Sensitive: true},
+			want:   "Env add: DB_PASSWORD (secret)",
+		},
+		{
+			name:   "env update",
+			change: updateChange(ResourceApplication, "Env.LOG_LEVEL", "info", "debug"),
+			want:   "Env update: LOG_LEVEL=info -> debug",
+		},
+		{
+			name:   "secret env update",
+			change: FieldChange{Resource: ResourceApplication, Path: "Env.DB_PASSWORD", Kind: ChangeUpdate, Old: "1", New: "2", Sensitive: true},
+			want:   "Env update: DB_PASSWORD (secret, version: 1 -> 2)",
+		},
+		{
+			name:   "secret env update without stored version",
+			change: FieldChange{Resource: ResourceApplication, Path: "Env.DB_PASSWORD", Kind: ChangeUpdate, New: "2", Sensitive: true},
+			want:   "Env update: DB_PASSWORD (secret, version: new -> 2)",
+		},
+		{
+			name:   "env remove",
+			change: FieldChange{Resource: ResourceApplication, Path: "Env.OLD", Kind: ChangeRemove, Old: "value"},
+			want:   "Env remove: OLD",
+		},
+		{
+			name:   "interface",
+			change: updateChange(ResourceLoadBalancer, "Interface[1].Upstream", "shared", "123456789012"),
+			want:   "Interface[1].Upstream: shared -> 123456789012",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.change.String())
+		})
+	}
+}
+
+func TestCompareEnv_SecretChangesDoNotContainValues(t *testing.T) {
+	st := state.NewState()
+	storedVersion := 1
+	st.SetSecretEnvVersion("my-app", "DB_PASSWORD", &storedVersion)
+	p := NewProvisioner(nil, st, "")
+
+	secretVersion := 2
+	changes := p.compareEnv("my-app", []api.ReadEnvironmentVariable{
+		{Key: "DB_PASSWORD", Secret: true},
+	}, []config.EnvVarConfig{
+		{Key: "DB_PASSWORD", Value: stringPtr("super-secret"), Secret: true, SecretVersion: &secretVersion},
+		{Key: "API_KEY", Value: stringPtr("another-secret"), Secret: true, SecretVersion: &secretVersion},
+	})
+
+	assert.Equal(t, []FieldChange{
+		{Resource: ResourceApplication, Path: "Env.DB_PASSWORD", Kind: ChangeUpdate, Old: "1", New: "2", Sensitive: true},
+		{Resource: ResourceApplication, Path: "Env.API_KEY", Kind: ChangeAdd, New: "2", Sensitive: true},
+	}, changes)
+}
+
+func TestCompareASG_StructuredChanges(t *testing.T) {
+	current := api.ReadAutoScalingGroupDetail{
+		Zone:                   "is1a",
+		WorkerServiceClassPath: "cloud/plan/small",
+		MinNodes:               1,
+		MaxNodes:               3,
+	}
+	desired := config.AutoScalingGroupConfig{
+		Zone:                   "is1a",
+		WorkerServiceClassPath: "cloud/plan/small",
+		MinNodes:               1,
+		MaxNodes:               5,
+	}
+
+	changes := compareASG(current, desired)
+	assert.Equal(t, []FieldChange{
+		{Resource: ResourceAutoScalingGroup, Path: "MaxNodes", Kind: ChangeUpdate, Old: "3", New: "5"},
+	}, changes)
+}
@@ -0,0 +1,162 @@
+package provisioner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/api"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+)
+
+// DriftOptions contains options for the DetectDrift operation
+type DriftOptions struct {
+	// Parallelism is the maximum number of applications checked concurrently
+	Parallelism int
+}
+
+// AppDrift contains the differences between the config, the active version and the latest version of an application
+type AppDrift struct {
+	ApplicationName string
+	// Missing is true if the application does not exist in the cluster
+	Missing       bool
+	ActiveVersion int // 0 if no active version
+	LatestVersion int // 0 if no versions exist
+	// ConfigVsActive lists changes from the active version to the config (what the serving version lacks)
+	ConfigVsActive []FieldChange
+	// ConfigVsLatest lists changes from the latest version to the config (what plan would apply)
+	ConfigVsLatest []FieldChange
+	// ActiveVsLatest lists changes from the active version to the latest version (what activation would change)
+	ActiveVsLatest []FieldChange
+}
+
+// HasDrift reports whether the config, the active version and the latest version differ
+func (d *AppDrift) HasDrift() bool {
+	return d.Missing || d.ActiveVersion != d.LatestVersion ||
+		len(d.ConfigVsActive) > 0 || len(d.ConfigVsLatest) > 0 || len(d.ActiveVsLatest) > 0
+}
+
+// DriftReport contains the drift of each application in the config
+type DriftReport struct {
+	ClusterName  string
+	ClusterID    uuid.UUID
+	Applications []AppDrift
+}
+
+// HasDrift reports whether any application has drifted
+func (r *DriftReport) HasDrift() bool {
+	for i := range r.Applications {
+		if r.Applications[i].HasDrift() {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectDrift compares each application in the config with its active and latest versions.
+// Unlike CreatePlan, which only looks at the latest version, it also reports the version actually serving traffic.
+func (p *Provisioner) DetectDrift(ctx context.Context, cfg *config.ClusterConfig, opts DriftOptions) (*DriftReport, error) {
+	clusterID, err := p.resolveClusterID(ctx, cfg.ClusterName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve cluster: %w", err)
+	}
+
+	existing, err := p.listAllApplications(ctx, clusterID)
+	if err != nil {
+		return nil, wrapAPIError(err, "failed to list applications")
+	}
+	existingByName := make(map[string]*api.ReadApplicationDetail)
+	for i := range existing {
+		existingByName[existing[i].Name] = existing[i]
+	}
+
+	report := &DriftReport{
+		ClusterName:  cfg.ClusterName,
+		ClusterID:    clusterID,
+		Applications: make([]AppDrift, len(cfg.Applications)),
+	}
+	err = runParallel(ctx, opts.Parallelism, len(cfg.Applications), func(ctx context.Context, i int) error {
+		appCfg := &cfg.Applications[i]
+		existingApp, ok := existingByName[appCfg.Name]
+		if !ok {
+			report.Applications[i] = AppDrift{ApplicationName: appCfg.Name, Missing: true}
+			return nil
+		}
+
+		drift, err := p.detectAppDrift(ctx, existingApp, appCfg)
+		if err != nil {
+			return fmt.Errorf("failed to detect drift for %s: %w", appCfg.Name, err)
+		}
+		report.Applications[i] = *drift
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// detectAppDrift compares the config of an existing application with its active and latest versions
+func (p *Provisioner) detectAppDrift(ctx context.Context, existing *api.ReadApplicationDetail, appCfg *config.ApplicationConfig) (*AppDrift, error) {
+	drift := &AppDrift{ApplicationName: appCfg.Name}
+
+	latest, err := p.getLatestVersion(ctx, existing.ApplicationID)
+	if err != nil {
+		return nil, wrapAPIError(err, "failed to get latest version")
+	}
+	if latest != nil {
+		drift.LatestVersion = int(latest.Version)
+		drift.ConfigVsLatest = p.compareVersion(ctx, appCfg.Name, latest, &appCfg.Spec)
+	}
+
+	activeNum, ok := existing.ActiveVersion.Get()
+	if !ok {
+		return drift, nil
+	}
+	drift.ActiveVersion = int(activeNum)
+
+	// Avoid fetching the same version twice
+	if latest != nil && int(latest.Version) == drift.ActiveVersion {
+		drift.ConfigVsActive = withoutStateBasedChanges(drift.ConfigVsLatest)
+		return drift, nil
+	}
+
+	activeResp, err := p.client.GetApplicationVersion(ctx, api.GetApplicationVersionParams{
+		ApplicationID: existing.ApplicationID,
+		Version:       api.ApplicationVersionNumber(activeNum),
+	})
+	if err != nil {
+		return nil, wrapAPIError(err, fmt.Sprintf("failed to get version %d", activeNum))
+	}
+	active := &activeResp.ApplicationVersion
+	drift.ConfigVsActive = withoutStateBasedChanges(p.compareVersion(ctx, appCfg.Name, active, &appCfg.Spec))
+
+	if latest != nil {
+		// Compare the versions themselves, including the image
+		changes, err := CompareSpecs(NormalizeFromAPI(active), NormalizeFromAPI(latest), CompareSpecsOptions{})
+		if err != nil {
+			return nil, err
+		}
+		envChanges, _ := p.compareVersionEnv(active.Env, latest.Env)
+		drift.ActiveVsLatest = append(changes, envChanges...)
+	}
+
+	return drift, nil
+}
+
+// withoutStateBasedChanges removes the changes of secret versions from a comparison with the active version.
+// Secret versions are compared with the state file, which records the versions last applied to the latest
+// version, so they cannot tell whether the active version has the secrets of the config.
+// Secret env vars added or removed are kept, as the keys are visible in the version.
+func withoutStateBasedChanges(changes []FieldChange) []FieldChange {
+	var observable []FieldChange
+	for _, c := range changes {
+		if c.Sensitive && (c.Path == "RegistryPasswordVersion" || c.Kind == ChangeUpdate) {
+			continue
+		}
+		observable = append(observable, c)
+	}
+	return observable
+}
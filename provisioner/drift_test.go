@@ -0,0 +1,114 @@
+package provisioner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/state"
+)
+
+func TestDetectDrift_NoDrift(t *testing.T) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	appID := createTestApplication(mockServer, clusterID, "existing-app")
+	createTestVersion(mockServer, appID, 1, 500, 1024)
+
+	provisioner := NewProvisioner(client, state.NewState(), "")
+	report, err := provisioner.DetectDrift(context.Background(), testClusterConfig(500), DriftOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, report.Applications, 1)
+	drift := report.Applications[0]
+	assert.Equal(t, 1, drift.ActiveVersion)
+	assert.Equal(t, 1, drift.LatestVersion)
+	assert.Empty(t, drift.ConfigVsActive)
+	assert.Empty(t, drift.ConfigVsLatest)
+	assert.Empty(t, drift.ActiveVsLatest)
+	assert.False(t, report.HasDrift())
+}
+
+func TestDetectDrift_LatestNotActive(t *testing.T) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	// Version 2 matches the config but version 1 is still serving traffic
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	appID := createTestApplication(mockServer, clusterID, "existing-app")
+	createTestVersion(mockServer, appID, 1, 500, 1024)
+	createTestVersion(mockServer, appID, 2, 1000, 1024)
+
+	provisioner := NewProvisioner(client, state.NewState(), "")
+	report, err := provisioner.DetectDrift(context.Background(), testClusterConfig(1000), DriftOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, report.Applications, 1)
+	drift := report.Applications[0]
+	assert.Equal(t, 1, drift.ActiveVersion)
+	assert.Equal(t, 2, drift.LatestVersion)
+	assert.Empty(t, drift.ConfigVsLatest)
+	assert.Equal(t, []string{"CPU: 500 -> 1000"}, FormatChanges(drift.ConfigVsActive))
+	assert.Equal(t, []string{"CPU: 500 -> 1000"}, FormatChanges(drift.ActiveVsLatest))
+	assert.True(t, report.HasDrift())
+}
+
+func TestDetectDrift_ConfigChanged(t *testing.T) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	appID := createTestApplication(mockServer, clusterID, "existing-app")
+	createTestVersion(mockServer, appID, 1, 500, 1024)
+
+	provisioner := NewProvisioner(client, state.NewState(), "")
+	report, err := provisioner.DetectDrift(context.Background(), testClusterConfig(1000), DriftOptions{})
+	require.NoError(t, err)
+
+	drift := report.Applications[0]
+	assert.Equal(t, []string{"CPU: 500 -> 1000"}, FormatChanges(drift.ConfigVsActive))
+	assert.Equal(t, []string{"CPU: 500 -> 1000"}, FormatChanges(drift.ConfigVsLatest))
+	assert.Empty(t, drift.ActiveVsLatest)
+	assert.True(t, report.HasDrift())
+}
+
+func TestDetectDrift_SecretVersionsOnlyComparedWithLatest(t *testing.T) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	appID := createTestApplication(mockServer, clusterID, "existing-app")
+	createTestVersion(mockServer, appID, 1, 500, 1024)
+
+	// The state file has no registry password version, so plan would set it
+	cfg := testClusterConfig(500)
+	spec := &cfg.Applications[0].Spec
+	spec.RegistryPassword = stringPtr("password")
+	spec.RegistryPasswordVersion = intPtr(1)
+
+	provisioner := NewProvisioner(client, state.NewState(), "")
+	report, err := provisioner.DetectDrift(context.Background(), cfg, DriftOptions{})
+	require.NoError(t, err)
+
+	drift := report.Applications[0]
+	assert.Equal(t, []string{"RegistryPasswordVersion: (unset) -> 1"}, FormatChanges(drift.ConfigVsLatest))
+	// The state file says nothing about the secrets of the active version
+	assert.Empty(t, drift.ConfigVsActive)
+}
+
+func TestDetectDrift_MissingApplication(t *testing.T) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+	createTestCluster(mockServer, "my-cluster")
+
+	provisioner := NewProvisioner(client, state.NewState(), "")
+	report, err := provisioner.DetectDrift(context.Background(), testClusterConfig(500), DriftOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, report.Applications, 1)
+	assert.True(t, report.Applications[0].Missing)
+	assert.True(t, report.HasDrift())
+}
@@ -0,0 +1,178 @@
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/state"
+)
+
+// resourceOperation describes the work for a single resource in the apply graph.
+// A resource kind is plugged into Apply by adding its operations to the graph.
+type resourceOperation struct {
+	// Key identifies the resource (e.g., "asg:web-asg", "lb:web-asg/web-lb", "app:webapp")
+	Key string
+	// DependsOn lists keys of resources this resource depends on (e.g., an LB depends on its ASG).
+	// Keys without operations in the graph are ignored.
+	DependsOn []string
+	// Delete deletes the resource (nil if the resource is not deleted)
+	Delete func(ctx context.Context) error
+	// Apply creates or updates the resource (nil if the resource is not created or updated)
+	Apply func(ctx context.Context) error
+}
+
+// graphNode is a single step of the apply graph
+type graphNode struct {
+	id   string
+	deps []string
+	run  func(ctx context.Context) error
+}
+
+// resourceGraph orders resource operations by their dependencies.
+//
+// For a resource X that depends on D:
+//   - apply of X runs after apply of D
+//   - delete of D runs after delete of X (deletes run in reverse dependency order)
+//   - apply of X runs after delete of X (recreate)
+type resourceGraph struct {
+	ops []resourceOperation
+}
+
+// add adds an operation to the graph
+func (g *resourceGraph) add(op resourceOperation) {
+	g.ops = append(g.ops, op)
+}
+
+// nodes returns the graph steps in topological order.
+// Independent steps keep the order in which their operations were added, deletes first.
+func (g *resourceGraph) nodes() ([]graphNode, error) {
+	byKey := make(map[string]resourceOperation)
+	for _, op := range g.ops {
+		if _, exists := byKey[op.Key]; exists {
+			return nil, fmt.Errorf("duplicate resource %s in apply graph", op.Key)
+		}
+		byKey[op.Key] = op
+	}
+
+	var candidates []graphNode
+	for _, op := range g.ops {
+		if op.Delete == nil {
+			continue
+		}
+		node := graphNode{id: deleteNodeID(op.Key), run: op.Delete}
+		// Delete dependents first
+		for _, other := range g.ops {
+			if other.Delete != nil && slices.Contains(other.DependsOn, op.Key) {
+				node.deps = append(node.deps, deleteNodeID(other.Key))
+			}
+		}
+		candidates = append(candidates, node)
+	}
+	for _, op := range g.ops {
+		if op.Apply == nil {
+			continue
+		}
+		node := graphNode{id: applyNodeID(op.Key), run: op.Apply}
+		if op.Delete != nil {
+			node.deps = append(node.deps, deleteNodeID(op.Key))
+		}
+		for _, dep := range op.DependsOn {
+			if depOp, ok := byKey[dep]; ok && depOp.Apply != nil {
+				node.deps = append(node.deps, applyNodeID(dep))
+			}
+		}
+		candidates = append(candidates, node)
+	}
+
+	return sortNodes(candidates)
+}
+
+// run executes the graph with at most parallelism concurrent steps.
+// The first error cancels the remaining steps.
+// If journal is not nil, the progress of each step is recorded in it and completed steps are skipped.
+func (g *resourceGraph) run(ctx context.Context, parallelism int, journal *state.Journal) error {
+	nodes, err := g.nodes()
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]int, len(nodes))
+	for i, node := range nodes {
+		index[node.id] = i
+	}
+	deps := make([][]int, len(nodes))
+	for i, node := range nodes {
+		for _, dep := range node.deps {
+			deps[i] = append(deps[i], index[dep])
+		}
+		if journal != nil {
+			nodes[i].run = journalStep(journal, node.id, node.run)
+		}
+	}
+
+	return runDAG(ctx, parallelism, deps, func(ctx context.Context, i int) error {
+		return nodes[i].run(ctx)
+	})
+}
+
+// sortNodes sorts nodes topologically, keeping the original order among independent nodes
+func sortNodes(nodes []graphNode) ([]graphNode, error) {
+	sorted := make([]graphNode, 0, len(nodes))
+	placed := make(map[string]bool, len(nodes))
+
+	for len(sorted) < len(nodes) {
+		progress := false
+		for _, node := range nodes {
+			if placed[node.id] || !allPlaced(node.deps, placed) {
+				continue
+			}
+			sorted = append(sorted, node)
+			placed[node.id] = true
+			progress = true
+			break
+		}
+		if !progress {
+			var blocked []string
+			for _, node := range nodes {
+				if !placed[node.id] {
+					blocked = append(blocked, node.id)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle in apply graph: %s", strings.Join(blocked, ", "))
+		}
+	}
+
+	return sorted, nil
+}
+
+func allPlaced(ids []string, placed map[string]bool) bool {
+	for _, id := range ids {
+		if !placed[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func deleteNodeID(key string) string {
+	return "delete " + key
+}
+
+func applyNodeID(key string) string {
+	return "apply " + key
+}
+
+// Resource keys use the same form as --target selectors
+func asgKey(name string) string {
+	return Target{Kind: TargetAutoScalingGroup, Name: name}.String()
+}
+
+func lbKey(asgName, name string) string {
+	return Target{Kind: TargetLoadBalancer, Name: name, ASGName: asgName}.String()
+}
+
+func appKey(name string) string {
+	return Target{Kind: TargetApplication, Name: name}.String()
+}
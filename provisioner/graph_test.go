@@ -0,0 +1,128 @@
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func noop(context.Context) error { return nil }
+
+func nodeIDs(nodes []graphNode) []string {
+	ids := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		ids = append(ids, node.id)
+	}
+	return ids
+}
+
+func TestResourceGraph_Nodes_RecreateOrder(t *testing.T) {
+	graph := &resourceGraph{}
+	graph.add(resourceOperation{Key: asgKey("web-asg"), Delete: noop, Apply: noop})
+	graph.add(resourceOperation{Key: lbKey("web-asg", "web-lb"), DependsOn: []string{asgKey("web-asg")}, Delete: noop, Apply: noop})
+	graph.add(resourceOperation{Key: appKey("webapp"), DependsOn: []string{asgKey("web-asg"), lbKey("web-asg", "web-lb")}, Apply: noop})
+
+	nodes, err := graph.nodes()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"delete lb:web-asg/web-lb",
+		"delete asg:web-asg",
+		"apply asg:web-asg",
+		"apply lb:web-asg/web-lb",
+		"apply app:webapp",
+	}, nodeIDs(nodes))
+}
+
+func TestResourceGraph_Nodes_IgnoresMissingDependencies(t *testing.T) {
+	graph := &resourceGraph{}
+	// The ASG has no operation (no changes), so the LB only waits for its own delete
+	graph.add(resourceOperation{Key: lbKey("web-asg", "web-lb"), DependsOn: []string{asgKey("web-asg")}, Delete: noop, Apply: noop})
+	graph.add(resourceOperation{Key: appKey("api"), Apply: noop})
+
+	nodes, err := graph.nodes()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"delete lb:web-asg/web-lb",
+		"apply lb:web-asg/web-lb",
+		"apply app:api",
+	}, nodeIDs(nodes))
+}
+
+func TestResourceGraph_Nodes_Cycle(t *testing.T) {
+	graph := &resourceGraph{}
+	graph.add(resourceOperation{Key: "a", DependsOn: []string{"b"}, Apply: noop})
+	graph.add(resourceOperation{Key: "b", DependsOn: []string{"a"}, Apply: noop})
+
+	_, err := graph.nodes()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dependency cycle")
+}
+
+func TestResourceGraph_Nodes_Duplicate(t *testing.T) {
+	graph := &resourceGraph{}
+	graph.add(resourceOperation{Key: appKey("webapp"), Apply: noop})
+	graph.add(resourceOperation{Key: appKey("webapp"), Apply: noop})
+
+	_, err := graph.nodes()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "duplicate resource app:webapp")
+}
+
+func TestResourceGraph_Run_RespectsDependencies(t *testing.T) {
+	captureLog(t)
+
+	var (
+		mu       sync.Mutex
+		executed []string
+	)
+	record := func(id string) func(context.Context) error {
+		return func(context.Context) error {
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			executed = append(executed, id)
+			return nil
+		}
+	}
+
+	graph := &resourceGraph{}
+	graph.add(resourceOperation{Key: asgKey("a"), Delete: record("delete a"), Apply: record("apply a")})
+	graph.add(resourceOperation{Key: asgKey("b"), Apply: record("apply b")})
+	graph.add(resourceOperation{Key: lbKey("a", "lb"), DependsOn: []string{asgKey("a")}, Delete: record("delete lb"), Apply: record("apply lb")})
+	graph.add(resourceOperation{Key: appKey("x"), DependsOn: []string{asgKey("a"), asgKey("b"), lbKey("a", "lb")}, Apply: record("apply x")})
+
+	require.NoError(t, graph.run(context.Background(), 4, nil))
+	require.Len(t, executed, 6)
+
+	before := func(first, second string) {
+		assert.Less(t, slices.Index(executed, first), slices.Index(executed, second), "%s should run before %s", first, second)
+	}
+	before("delete lb", "delete a")
+	before("delete a", "apply a")
+	before("apply a", "apply lb")
+	before("apply lb", "apply x")
+	before("apply b", "apply x")
+}
+
+func TestResourceGraph_Run_FailureSkipsDependents(t *testing.T) {
+	captureLog(t)
+	errBoom := errors.New("boom")
+	appApplied := false
+
+	graph := &resourceGraph{}
+	graph.add(resourceOperation{Key: asgKey("a"), Apply: func(context.Context) error { return errBoom }})
+	graph.add(resourceOperation{Key: appKey("x"), DependsOn: []string{asgKey("a")}, Apply: func(context.Context) error {
+		appApplied = true
+		return nil
+	}})
+
+	err := graph.run(context.Background(), 4, nil)
+	require.ErrorIs(t, err, errBoom)
+	assert.False(t, appApplied)
+}
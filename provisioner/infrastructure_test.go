@@ -0,0 +1,153 @@
+package provisioner
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/api"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/testutil"
+)
+
+// exclusiveConfig returns the shared test config with manageInfrastructure set to exclusive
+func exclusiveConfig() *config.ClusterConfig {
+	cfg := testClusterConfig(500)
+	cfg.ManageInfrastructure = config.ManageInfrastructureExclusive
+	return cfg
+}
+
+// addTestWorkerNode adds a worker node running a container of each application in the given state
+func addTestWorkerNode(mockServer *testutil.MockServer, asgID api.AutoScalingGroupID, containerState string, appIDs ...api.ApplicationID) {
+	node := api.ReadWorkerNodeDetail{
+		WorkerNodeID: api.WorkerNodeID(uuid.New()),
+		Status:       api.WorkerNodeStatusHealthy,
+		Healthy:      true,
+	}
+	for _, appID := range appIDs {
+		node.RunningContainers = append(node.RunningContainers, api.RunningContainer{
+			ContainerID:        uuid.NewString(),
+			Name:               "app",
+			State:              containerState,
+			Image:              "nginx:latest",
+			ApplicationID:      appID,
+			ApplicationVersion: 1,
+		})
+	}
+	mockServer.AddWorkerNode(asgID, node)
+}
+
+func TestCreatePlan_Infrastructure_AdditiveSkipsUnknown(t *testing.T) {
+	mockServer, provisioner, clusterID, cleanup := setupTestCluster(t, "", "existing-app")
+	defer cleanup()
+	asgID := createTestASG(mockServer, clusterID, "old-asg")
+	createTestLB(mockServer, asgID, "old-lb")
+
+	plan, err := provisioner.CreatePlan(context.Background(), testClusterConfig(500), PlanOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, plan.ASGActions, 1)
+	assert.Equal(t, ASGActionSkip, plan.ASGActions[0].Action)
+	require.Len(t, plan.LBActions, 1)
+	assert.Equal(t, LBActionSkip, plan.LBActions[0].Action)
+	assert.False(t, plan.HasChanges())
+}
+
+func TestCreatePlan_Infrastructure_ExclusiveDeletesUnknown(t *testing.T) {
+	mockServer, provisioner, clusterID, cleanup := setupTestCluster(t, "", "existing-app")
+	defer cleanup()
+	appID := testAppID(mockServer, clusterID, "existing-app")
+	asgID := createTestASG(mockServer, clusterID, "old-asg")
+	createTestLB(mockServer, asgID, "old-lb")
+	// Stopped containers do not block the deletion
+	addTestWorkerNode(mockServer, asgID, "exited", appID)
+
+	plan, err := provisioner.CreatePlan(context.Background(), exclusiveConfig(), PlanOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, plan.ASGActions, 1)
+	assert.Equal(t, ASGActionDelete, plan.ASGActions[0].Action)
+	assert.Equal(t, "old-asg", plan.ASGActions[0].Name)
+	require.Len(t, plan.LBActions, 1)
+	assert.Equal(t, LBActionDelete, plan.LBActions[0].Action)
+	assert.Equal(t, "parent ASG is being deleted", plan.LBActions[0].Reason)
+	assert.True(t, plan.HasChanges())
+}
+
+func TestCreatePlan_Infrastructure_ExclusiveBlockedByActiveNodes(t *testing.T) {
+	mockServer, provisioner, clusterID, cleanup := setupTestCluster(t, "", "existing-app")
+	defer cleanup()
+	appID := testAppID(mockServer, clusterID, "existing-app")
+	asgID := createTestASG(mockServer, clusterID, "old-asg")
+	addTestWorkerNode(mockServer, asgID, "running", appID)
+
+	_, err := provisioner.CreatePlan(context.Background(), exclusiveConfig(), PlanOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot delete ASG old-asg: applications still have active nodes on it: existing-app")
+}
+
+func TestCreatePlan_Infrastructure_ExclusiveWithPrunedApplication(t *testing.T) {
+	captureLog(t)
+	mockServer, provisioner, clusterID, cleanup := setupTestCluster(t, "", "existing-app", "orphan-app")
+	defer cleanup()
+	orphanID := testAppID(mockServer, clusterID, "orphan-app")
+	asgID := createTestASG(mockServer, clusterID, "old-asg")
+	addTestWorkerNode(mockServer, asgID, "running", orphanID)
+
+	// The application running on the ASG blocks its deletion...
+	_, err := provisioner.CreatePlan(context.Background(), exclusiveConfig(), PlanOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "orphan-app")
+
+	// ...unless the plan deletes the application first
+	plan, err := provisioner.CreatePlan(context.Background(), exclusiveConfig(), PlanOptions{Prune: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"orphan-app"}, deletedApps(plan))
+	require.Len(t, plan.ASGActions, 1)
+	assert.Equal(t, ASGActionDelete, plan.ASGActions[0].Action)
+}
+
+func TestApply_Infrastructure_ExclusiveDeletesUnknown(t *testing.T) {
+	captureLog(t)
+	mockServer, provisioner, clusterID, cleanup := setupTestCluster(t, filepath.Join(t.TempDir(), "config.yaml"), "existing-app")
+	defer cleanup()
+	asgID := createTestASG(mockServer, clusterID, "old-asg")
+	lbID := createTestLB(mockServer, asgID, "old-lb")
+
+	cfg := exclusiveConfig()
+
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	// The mock refuses to delete an ASG that still has LBs, so this also checks the order
+	require.NoError(t, provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{}))
+
+	assert.False(t, mockServer.HasLoadBalancer(asgID, lbID))
+	assert.False(t, mockServer.HasAutoScalingGroup(asgID))
+	_, found := mockServer.GetApplicationByName(clusterID, "existing-app")
+	assert.True(t, found)
+}
+
+func TestApply_Infrastructure_ExclusiveBlockedByActiveNodes(t *testing.T) {
+	captureLog(t)
+	mockServer, provisioner, clusterID, cleanup := setupTestCluster(t, filepath.Join(t.TempDir(), "config.yaml"), "existing-app")
+	defer cleanup()
+	appID := testAppID(mockServer, clusterID, "existing-app")
+	asgID := createTestASG(mockServer, clusterID, "old-asg")
+
+	cfg := exclusiveConfig()
+
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+
+	// The application is scheduled on the ASG after the plan was created
+	addTestWorkerNode(mockServer, asgID, "running", appID)
+
+	err = provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "applications still have active nodes on it: existing-app")
+	assert.True(t, mockServer.HasAutoScalingGroup(asgID))
+}
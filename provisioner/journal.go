@@ -0,0 +1,121 @@
+package provisioner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/state"
+)
+
+// stepResultKey is the context key of the result of a running journal step
+type stepResultKey struct{}
+
+// stepResult holds what a step reports to the journal
+type stepResult struct {
+	resourceID string
+	version    int
+}
+
+// recordResourceID records the ID returned by the API for the running step
+func recordResourceID(ctx context.Context, id string) {
+	if result, ok := ctx.Value(stepResultKey{}).(*stepResult); ok {
+		result.resourceID = id
+	}
+}
+
+// recordVersion records the application version created by the running step
+func recordVersion(ctx context.Context, version int) {
+	if result, ok := ctx.Value(stepResultKey{}).(*stepResult); ok {
+		result.version = version
+	}
+}
+
+// ResumePlan returns the plan of the unfinished apply recorded in the journal
+func (p *Provisioner) ResumePlan() (*Plan, error) {
+	journal, err := state.LoadJournal(p.configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load apply journal: %w", err)
+	}
+	if journal == nil {
+		return nil, fmt.Errorf("no unfinished apply to resume (%s not found)", state.GetJournalPath(p.configPath))
+	}
+
+	var plan Plan
+	if err := json.Unmarshal(journal.Plan, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan in apply journal: %w", err)
+	}
+	return &plan, nil
+}
+
+// openJournal creates the journal for a new apply, or loads it when resuming.
+// Returns nil if the provisioner has no config path to store the journal next to.
+func (p *Provisioner) openJournal(plan *Plan, opts ApplyOptions) (*state.Journal, error) {
+	if p.configPath == "" {
+		if opts.Resume {
+			return nil, fmt.Errorf("cannot resume without a config path")
+		}
+		return nil, nil
+	}
+
+	existing, err := state.LoadJournal(p.configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load apply journal: %w", err)
+	}
+
+	if opts.Resume {
+		if existing == nil {
+			return nil, fmt.Errorf("no unfinished apply to resume (%s not found)", state.GetJournalPath(p.configPath))
+		}
+		return existing, nil
+	}
+
+	if existing != nil {
+		return nil, fmt.Errorf("an unfinished apply was found in %s; run 'apply --resume' to continue it, or remove the file to discard it", state.GetJournalPath(p.configPath))
+	}
+
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal plan: %w", err)
+	}
+	journal := state.NewJournal(p.configPath, data, opts.Activate)
+	journal.AtomicActivate = opts.AtomicActivate
+	if err := journal.Save(); err != nil {
+		return nil, fmt.Errorf("failed to write apply journal: %w", err)
+	}
+	return journal, nil
+}
+
+// journalStep wraps the run function of a graph node so that its progress is recorded in the journal.
+// Steps completed by a previous apply are skipped.
+func journalStep(journal *state.Journal, id string, run func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if journal.IsCompleted(id) {
+			logf(ctx, "Skipping %s (completed by the previous apply)", id)
+			return nil
+		}
+
+		if err := journal.Record(state.JournalStep{ID: id, Status: state.StepStarted}); err != nil {
+			return fmt.Errorf("failed to write apply journal: %w", err)
+		}
+
+		result := &stepResult{}
+		err := run(context.WithValue(ctx, stepResultKey{}, result))
+
+		step := state.JournalStep{
+			ID:         id,
+			Status:     state.StepCompleted,
+			ResourceID: result.resourceID,
+			Version:    result.version,
+		}
+		if err != nil {
+			step.Status = state.StepFailed
+			step.Error = err.Error()
+		}
+		if journalErr := journal.Record(step); journalErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to write apply journal: %w", journalErr))
+		}
+		return err
+	}
+}
@@ -0,0 +1,233 @@
+package provisioner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/api"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/state"
+)
+
+func journalTestConfig() *config.ClusterConfig {
+	return &config.ClusterConfig{
+		ClusterName: "my-cluster",
+		Applications: []config.ApplicationConfig{
+			{
+				Name: "new-app",
+				Spec: config.ApplicationSpec{
+					CPU:         500,
+					Memory:      1024,
+					ScalingMode: "manual",
+					FixedScale:  int32Ptr(1),
+					Image:       "nginx:latest",
+				},
+			},
+		},
+	}
+}
+
+// writeJournal writes a journal for the plan as left behind by an interrupted apply
+func writeJournal(t *testing.T, configPath string, plan *Plan, activate bool, steps ...state.JournalStep) {
+	t.Helper()
+	data, err := json.Marshal(plan)
+	require.NoError(t, err)
+	journal := state.NewJournal(configPath, data, activate)
+	require.NoError(t, journal.Save())
+	for _, step := range steps {
+		require.NoError(t, journal.Record(step))
+	}
+}
+
+func TestApply_Journal_RemovedOnSuccess(t *testing.T) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+	clusterID := createTestCluster(mockServer, "my-cluster")
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	provisioner := NewProvisioner(client, state.NewState(), configPath)
+	cfg := journalTestConfig()
+
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	require.NoError(t, provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{Activate: true}))
+
+	_, found := mockServer.GetApplicationByName(clusterID, "new-app")
+	assert.True(t, found)
+	_, err = os.Stat(state.GetJournalPath(configPath))
+	assert.True(t, os.IsNotExist(err), "journal should be removed after a successful apply")
+}
+
+func TestApply_Journal_RefusesUnfinishedApply(t *testing.T) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+	clusterID := createTestCluster(mockServer, "my-cluster")
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	provisioner := NewProvisioner(client, state.NewState(), configPath)
+	cfg := journalTestConfig()
+
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	writeJournal(t, configPath, plan, true)
+
+	err = provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{Activate: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "apply --resume")
+
+	_, found := mockServer.GetApplicationByName(clusterID, "new-app")
+	assert.False(t, found, "nothing should be applied while an unfinished apply exists")
+}
+
+func TestApply_Resume_NoJournal(t *testing.T) {
+	_, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	provisioner := NewProvisioner(client, state.NewState(), configPath)
+
+	_, err := provisioner.ResumePlan()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no unfinished apply")
+}
+
+func TestApply_Resume_SkipsCompletedSteps(t *testing.T) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+	clusterID := createTestCluster(mockServer, "my-cluster")
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	provisioner := NewProvisioner(client, state.NewState(), configPath)
+	cfg := journalTestConfig()
+
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	writeJournal(t, configPath, plan, true, state.JournalStep{ID: "apply app:new-app", Status: state.StepCompleted})
+
+	resumed, err := provisioner.ResumePlan()
+	require.NoError(t, err)
+	require.Len(t, resumed.Actions, 1)
+	assert.Equal(t, ActionCreate, resumed.Actions[0].Action)
+
+	require.NoError(t, provisioner.Apply(context.Background(), cfg, resumed, ApplyOptions{Resume: true}))
+
+	_, found := mockServer.GetApplicationByName(clusterID, "new-app")
+	assert.False(t, found, "completed steps should not be repeated")
+	_, err = os.Stat(state.GetJournalPath(configPath))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestApply_Resume_InterruptedCreate(t *testing.T) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+	clusterID := createTestCluster(mockServer, "my-cluster")
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	provisioner := NewProvisioner(client, state.NewState(), configPath)
+	cfg := journalTestConfig()
+
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	writeJournal(t, configPath, plan, true, state.JournalStep{ID: "apply app:new-app", Status: state.StepStarted})
+
+	// The previous apply created the application and its first version, then stopped before activation
+	appID := createTestApplication(mockServer, clusterID, "new-app")
+	createTestVersion(mockServer, appID, 1, 500, 1024)
+
+	// Activation is taken from the journal
+	require.NoError(t, provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{Resume: true}))
+
+	assert.Equal(t, 1, mockServer.ApplicationCount(), "the application should not be created twice")
+	assert.Equal(t, 1, mockServer.VersionCount(appID), "the version should not be created twice")
+	app, found := mockServer.GetApplicationByName(clusterID, "new-app")
+	require.True(t, found)
+	assert.False(t, app.ActiveVersion.Null)
+	assert.Equal(t, int32(1), app.ActiveVersion.Value)
+}
+
+func TestJournalStep_RecordsProgress(t *testing.T) {
+	captureLog(t)
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	journal := state.NewJournal(configPath, json.RawMessage(`{}`), false)
+	errBoom := errors.New("boom")
+
+	graph := &resourceGraph{}
+	graph.add(resourceOperation{Key: asgKey("a"), Apply: func(ctx context.Context) error {
+		recordResourceID(ctx, "asg-id")
+		return nil
+	}})
+	graph.add(resourceOperation{Key: appKey("x"), DependsOn: []string{asgKey("a")}, Apply: func(context.Context) error {
+		return errBoom
+	}})
+
+	err := graph.run(context.Background(), 1, journal)
+	require.ErrorIs(t, err, errBoom)
+
+	loaded, err := state.LoadJournal(configPath)
+	require.NoError(t, err)
+	require.NotNil(t, loaded)
+
+	step, ok := loaded.Step("apply asg:a")
+	require.True(t, ok)
+	assert.Equal(t, state.StepCompleted, step.Status)
+	assert.Equal(t, "asg-id", step.ResourceID)
+
+	step, ok = loaded.Step("apply app:x")
+	require.True(t, ok)
+	assert.Equal(t, state.StepFailed, step.Status)
+	assert.Equal(t, "boom", step.Error)
+}
+
+func TestApply_Resume_AtomicActivateRollsBackToPlannedVersions(t *testing.T) {
+	captureLog(t)
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	appA := createTestApplication(mockServer, clusterID, "app-a")
+	createTestVersion(mockServer, appA, 1, 500, 1024)
+	appB := createTestApplication(mockServer, clusterID, "app-b")
+	createTestVersion(mockServer, appB, 1, 500, 1024)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	provisioner := NewProvisioner(client, state.NewState(), configPath)
+	cfg := &config.ClusterConfig{
+		ClusterName:  "my-cluster",
+		Applications: []config.ApplicationConfig{atomicActivateTestApp("app-a"), atomicActivateTestApp("app-b")},
+	}
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+
+	// The previous apply created both versions and was interrupted after activating app-a
+	data, err := json.Marshal(plan)
+	require.NoError(t, err)
+	journal := state.NewJournal(configPath, data, true)
+	journal.AtomicActivate = true
+	require.NoError(t, journal.Save())
+	for name, appID := range map[string]api.ApplicationID{"app-a": appA, "app-b": appB} {
+		createTestVersion(mockServer, appID, 2, 1000, 2048)
+		require.NoError(t, journal.Record(state.JournalStep{ID: "apply app:" + name, Status: state.StepCompleted, ResourceID: uuid.UUID(appID).String(), Version: 2}))
+	}
+	require.NoError(t, client.UpdateApplication(context.Background(), &api.UpdateApplication{ActiveVersion: api.NewNilInt32(2)}, api.UpdateApplicationParams{ApplicationID: appA}))
+	mockServer.FailActivation(appB, 2)
+
+	resumed, err := provisioner.ResumePlan()
+	require.NoError(t, err)
+	err = provisioner.Apply(context.Background(), cfg, resumed, ApplyOptions{Resume: true})
+	require.Error(t, err)
+
+	// app-a goes back to the version active before the interrupted apply, not the one it activated
+	assert.Contains(t, err.Error(), "rolled back activations: app-a (version 2 -> version 1)")
+	app, _ := mockServer.GetApplicationByName(clusterID, "app-a")
+	assert.Equal(t, int32(1), app.ActiveVersion.Value)
+	app, _ = mockServer.GetApplicationByName(clusterID, "app-b")
+	assert.Equal(t, int32(1), app.ActiveVersion.Value)
+}
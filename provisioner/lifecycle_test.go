@@ -0,0 +1,78 @@
+package provisioner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+)
+
+func TestCreatePlan_PreventDestroy_NoChanges(t *testing.T) {
+	_, provisioner, _, cleanup := setupInfraCluster(t, "")
+	defer cleanup()
+
+	cfg := infraTestConfig()
+	cfg.AutoScalingGroups[0].Lifecycle = &config.LifecycleConfig{PreventDestroy: true}
+	cfg.LoadBalancers[0].Lifecycle = &config.LifecycleConfig{PreventDestroy: true}
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	require.Len(t, plan.ASGActions, 1)
+	assert.Equal(t, ASGActionNoop, plan.ASGActions[0].Action)
+	require.Len(t, plan.LBActions, 1)
+	assert.Equal(t, LBActionNoop, plan.LBActions[0].Action)
+}
+
+func TestCreatePlan_PreventDestroy_Unset(t *testing.T) {
+	_, provisioner, _, cleanup := setupInfraCluster(t, "")
+	defer cleanup()
+
+	cfg := infraTestConfig()
+	cfg.AutoScalingGroups[0].NameServers = []string{"8.8.4.4"}
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	require.Len(t, plan.ASGActions, 1)
+	assert.Equal(t, ASGActionRecreate, plan.ASGActions[0].Action)
+	require.Len(t, plan.LBActions, 1)
+	assert.Equal(t, LBActionRecreate, plan.LBActions[0].Action)
+}
+
+func TestCreatePlan_PreventDestroy_ASG(t *testing.T) {
+	_, provisioner, _, cleanup := setupInfraCluster(t, "")
+	defer cleanup()
+
+	cfg := infraTestConfig()
+	cfg.AutoScalingGroups[0].NameServers = []string{"8.8.4.4"}
+	cfg.AutoScalingGroups[0].Lifecycle = &config.LifecycleConfig{PreventDestroy: true}
+	_, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ASG web-asg has lifecycle.preventDestroy set but would be recreated because these fields changed")
+	assert.Contains(t, err.Error(), "NameServers: [8.8.8.8] -> [8.8.4.4]")
+}
+
+func TestCreatePlan_PreventDestroy_LBWithRecreatedASG(t *testing.T) {
+	_, provisioner, _, cleanup := setupInfraCluster(t, "")
+	defer cleanup()
+
+	cfg := infraTestConfig()
+	cfg.AutoScalingGroups[0].NameServers = []string{"8.8.4.4"}
+	cfg.LoadBalancers[0].Lifecycle = &config.LifecycleConfig{PreventDestroy: true}
+	_, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "LB web-lb (ASG: web-asg) has lifecycle.preventDestroy set but would be recreated because its parent ASG is being recreated")
+}
+
+func TestCreatePlan_PreventDestroy_LB(t *testing.T) {
+	_, provisioner, _, cleanup := setupInfraCluster(t, "")
+	defer cleanup()
+
+	cfg := infraTestConfig()
+	cfg.LoadBalancers[0].Lifecycle = &config.LifecycleConfig{PreventDestroy: true}
+	cfg.LoadBalancers[0].ServiceClassPath = "cloud/plan/lb-large"
+	_, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "LB web-lb (ASG: web-asg) has lifecycle.preventDestroy set but would be recreated because these fields changed")
+	assert.Contains(t, err.Error(), "ServiceClassPath: cloud/plan/lb -> cloud/plan/lb-large")
+}
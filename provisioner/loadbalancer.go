@@ -4,7 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -30,51 +31,108 @@ type LBAction struct {
 	Action  LBActionType
 	Name    string
 	ASGName string
-	Changes []string
-	// For delete/recreate, we need the existing LB ID and ASG ID
+	// Reason describes why the action is needed when it is not explained by field changes
+	Reason  string
+	Changes []FieldChange
+	// ExistingID is the ID of the existing LB (nil for create).
+	// Delete/recreate use it, and saved plans use it to detect changes in the cluster.
 	ExistingID *api.LoadBalancerID
-	ASGID      *api.AutoScalingGroupID
+	// ASGID is the ID of the parent ASG (nil if the ASG does not exist yet)
+	ASGID *api.AutoScalingGroupID
+	// CreateBeforeDestroy creates the replacement and waits for it to become healthy
+	// before deleting the existing LB (recreate only)
+	CreateBeforeDestroy bool
+	// CreateName is the name the LB is created under, if it differs from Name
+	// (the temporary name of a createBeforeDestroy replacement)
+	CreateName string
+	// ExistingName is the name of the existing LB, if it differs from Name
+	// (a createBeforeDestroy replacement that still has its temporary name)
+	ExistingName string
 }
 
-// planLBChanges compares current LBs with desired and returns planned changes
-func (p *Provisioner) planLBChanges(ctx context.Context, clusterID uuid.UUID, desired []config.LoadBalancerConfig, currentASGs []api.ReadAutoScalingGroupDetail, asgActions []ASGAction) ([]LBAction, error) {
+// createName returns the name the LB is created under
+func (a *LBAction) createName() string {
+	if a.CreateName != "" {
+		return a.CreateName
+	}
+	return a.Name
+}
+
+// existingName returns the name of the existing LB
+func (a *LBAction) existingName() string {
+	if a.ExistingName != "" {
+		return a.ExistingName
+	}
+	return a.Name
+}
+
+// listLBsByASG lists the LBs of the given ASGs by ASG name.
+// ASGs are listed in parallel, since each LB needs a detail request.
+func (p *Provisioner) listLBsByASG(ctx context.Context, clusterID uuid.UUID, asgs []api.ReadAutoScalingGroupDetail, parallelism int) (map[string][]api.ReadLoadBalancerDetail, error) {
+	lbsByASG := make([][]api.ReadLoadBalancerDetail, len(asgs))
+	err := runParallel(ctx, parallelism, len(asgs), func(ctx context.Context, i int) error {
+		lbs, err := p.listAllLBs(ctx, clusterID, asgs[i].AutoScalingGroupID)
+		if err != nil {
+			return err
+		}
+		lbsByASG[i] = lbs
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string][]api.ReadLoadBalancerDetail)
+	for i, asg := range asgs {
+		byName[asg.Name] = lbsByASG[i]
+	}
+	return byName, nil
+}
+
+// planLBChanges compares current LBs (listed by listLBsByASG) with desired and returns planned changes.
+// LBs not in YAML are deleted if exclusive is true, otherwise skipped.
+func planLBChanges(desired []config.LoadBalancerConfig, currentASGs []api.ReadAutoScalingGroupDetail, lbsByASG map[string][]api.ReadLoadBalancerDetail, asgActions []ASGAction, exclusive bool) ([]LBAction, error) {
 	// Build map of ASG names to IDs
 	asgNameToID := make(map[string]api.AutoScalingGroupID)
 	for _, asg := range currentASGs {
 		asgNameToID[asg.Name] = asg.AutoScalingGroupID
 	}
 
-	// Build map of ASGs being recreated
+	// Build maps of ASGs being recreated or deleted
 	asgRecreating := make(map[string]bool)
+	asgDeleting := make(map[string]bool)
 	for _, action := range asgActions {
-		if action.Action == ASGActionRecreate {
+		switch action.Action {
+		case ASGActionRecreate:
 			asgRecreating[action.Name] = true
+		case ASGActionDelete:
+			asgDeleting[action.Name] = true
 		}
 	}
 
-	// Get current LBs for all ASGs
 	currentLBs := make(map[string]map[string]api.ReadLoadBalancerDetail) // asgName -> lbName -> LB
 	for _, asg := range currentASGs {
-		lbs, err := p.listAllLBs(ctx, clusterID, asg.AutoScalingGroupID)
-		if err != nil {
-			return nil, err
-		}
 		currentLBs[asg.Name] = make(map[string]api.ReadLoadBalancerDetail)
-		for _, lb := range lbs {
+		for _, lb := range lbsByASG[asg.Name] {
 			currentLBs[asg.Name][lb.Name] = lb
 		}
 	}
 
 	var actions []LBAction
+	var errs []error
 
-	// Check each desired LB
 	desiredLBs := make(map[string]map[string]bool) // asgName -> lbName -> exists
 	for _, desiredLB := range desired {
 		if desiredLBs[desiredLB.AutoScalingGroupName] == nil {
 			desiredLBs[desiredLB.AutoScalingGroupName] = make(map[string]bool)
 		}
 		desiredLBs[desiredLB.AutoScalingGroupName][desiredLB.Name] = true
+	}
+	// Replacements with a temporary name that are renamed back by this plan (asgName -> lbName -> true)
+	renaming := make(map[string]map[string]bool)
 
+	// Check each desired LB
+	for _, desiredLB := range desired {
 		asgID, asgExists := asgNameToID[desiredLB.AutoScalingGroupName]
 		if !asgExists {
 			// ASG doesn't exist yet - LB will be created after ASG
@@ -89,7 +147,28 @@ func (p *Provisioner) planLBChanges(ctx context.Context, clusterID uuid.UUID, de
 
 		asgLBs := currentLBs[desiredLB.AutoScalingGroupName]
 		current, exists := asgLBs[desiredLB.Name]
-		if !exists {
+		replacementName := desiredLB.ReplacementName()
+		replacement, replaced := asgLBs[replacementName]
+		if !exists && replaced && !desiredLBs[desiredLB.AutoScalingGroupName][replacementName] && !asgRecreating[desiredLB.AutoScalingGroupName] {
+			// A createBeforeDestroy replacement still has its temporary name.
+			// LBs cannot be renamed, so it is replaced again by an LB with the configured name.
+			lbID := replacement.LoadBalancerID
+			actions = append(actions, LBAction{
+				Action:              LBActionRecreate,
+				Name:                desiredLB.Name,
+				ASGName:             desiredLB.AutoScalingGroupName,
+				Reason:              fmt.Sprintf("rename the replacement %s back to %s", replacementName, desiredLB.Name),
+				Changes:             compareLB(replacement, desiredLB),
+				ExistingID:          &lbID,
+				ASGID:               &asgID,
+				CreateBeforeDestroy: true,
+				ExistingName:        replacementName,
+			})
+			if renaming[desiredLB.AutoScalingGroupName] == nil {
+				renaming[desiredLB.AutoScalingGroupName] = make(map[string]bool)
+			}
+			renaming[desiredLB.AutoScalingGroupName][replacementName] = true
+		} else if !exists {
 			// LB doesn't exist, create it
 			actions = append(actions, LBAction{
 				Action:  LBActionCreate,
@@ -101,59 +180,275 @@ func (p *Provisioner) planLBChanges(ctx context.Context, clusterID uuid.UUID, de
 		} else {
 			// LB exists, check if settings differ or if parent ASG is being recreated
 			changes := compareLB(current, desiredLB)
-			if asgRecreating[desiredLB.AutoScalingGroupName] {
+			preventDestroy := desiredLB.Lifecycle.PreventsDestroy()
+			lbName := fmt.Sprintf("LB %s (ASG: %s)", desiredLB.Name, desiredLB.AutoScalingGroupName)
+			if preventDestroy && asgRecreating[desiredLB.AutoScalingGroupName] {
+				errs = append(errs, fmt.Errorf("%s has lifecycle.preventDestroy set but would be recreated because its parent ASG is being recreated", lbName))
+			} else if preventDestroy && len(changes) > 0 {
+				errs = append(errs, preventDestroyError(lbName, changes))
+			} else if asgRecreating[desiredLB.AutoScalingGroupName] {
 				// Parent ASG is being recreated, LB must also be recreated
 				lbID := current.LoadBalancerID
-				recreateChanges := changes
-				if len(recreateChanges) == 0 {
-					recreateChanges = []string{"parent ASG is being recreated"}
-				}
 				actions = append(actions, LBAction{
 					Action:     LBActionRecreate,
 					Name:       desiredLB.Name,
 					ASGName:    desiredLB.AutoScalingGroupName,
-					Changes:    recreateChanges,
+					Reason:     "parent ASG is being recreated",
+					Changes:    changes,
 					ExistingID: &lbID,
 					ASGID:      &asgID,
 				})
 			} else if len(changes) > 0 {
 				// Settings differ, need to recreate (no update API)
 				lbID := current.LoadBalancerID
-				actions = append(actions, LBAction{
+				action := LBAction{
 					Action:     LBActionRecreate,
 					Name:       desiredLB.Name,
 					ASGName:    desiredLB.AutoScalingGroupName,
 					Changes:    changes,
 					ExistingID: &lbID,
 					ASGID:      &asgID,
-				})
+				}
+				if desiredLB.CreateBeforeDestroy() {
+					if replaced {
+						errs = append(errs, fmt.Errorf("cannot replace %s: an LB named %s already exists; resume the interrupted apply with 'apply --resume' or delete it", lbName, replacementName))
+						continue
+					}
+					action.CreateBeforeDestroy = true
+					action.CreateName = replacementName
+				}
+				actions = append(actions, action)
 			} else {
+				lbID := current.LoadBalancerID
 				actions = append(actions, LBAction{
-					Action:  LBActionNoop,
-					Name:    desiredLB.Name,
-					ASGName: desiredLB.AutoScalingGroupName,
+					Action:     LBActionNoop,
+					Name:       desiredLB.Name,
+					ASGName:    desiredLB.AutoScalingGroupName,
+					ExistingID: &lbID,
+					ASGID:      &asgID,
 				})
 			}
 		}
 	}
 
-	// Check for LBs not in YAML (skip instead of delete)
-	for asgName, lbMap := range currentLBs {
-		for lbName := range lbMap {
-			if desiredLBs[asgName] == nil || !desiredLBs[asgName][lbName] {
-				actions = append(actions, LBAction{
-					Action:  LBActionSkip,
-					Name:    lbName,
-					ASGName: asgName,
-					Changes: []string{"not in YAML, skipping"},
-				})
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	// Check for LBs not in YAML (skip unless the infrastructure is managed exclusively)
+	for _, asg := range currentASGs {
+		asgID := asg.AutoScalingGroupID
+		lbMap := currentLBs[asg.Name]
+		for _, lbName := range slices.Sorted(maps.Keys(lbMap)) {
+			if desiredLBs[asg.Name][lbName] || renaming[asg.Name][lbName] {
+				continue
+			}
+			lbID := lbMap[lbName].LoadBalancerID
+			action := LBAction{
+				Action:     LBActionSkip,
+				Name:       lbName,
+				ASGName:    asg.Name,
+				Reason:     "not in YAML, skipping",
+				ExistingID: &lbID,
+				ASGID:      &asgID,
 			}
+			switch {
+			case asgDeleting[asg.Name]:
+				// The ASG cannot be deleted while it still has LBs
+				action.Action = LBActionDelete
+				action.Reason = "parent ASG is being deleted"
+			case exclusive:
+				action.Action = LBActionDelete
+				action.Reason = "not in YAML (manageInfrastructure: exclusive)"
+			}
+			actions = append(actions, action)
 		}
 	}
 
 	return actions, nil
 }
 
+// addLBOperations adds the planned LB deletes and creates to the apply graph.
+// Each LB depends on its ASG, so it is deleted before and created after the ASG.
+func (p *Provisioner) addLBOperations(graph *resourceGraph, run *applyRun, actions []LBAction) error {
+	for _, action := range actions {
+		op := resourceOperation{
+			Key:       lbKey(action.ASGName, action.Name),
+			DependsOn: []string{asgKey(action.ASGName)},
+		}
+
+		if action.Action == LBActionDelete || (action.Action == LBActionRecreate && !action.CreateBeforeDestroy) {
+			if action.ExistingID == nil || action.ASGID == nil {
+				return fmt.Errorf("cannot delete LB %s: missing ID", action.Name)
+			}
+			asgID, lbID := *action.ASGID, *action.ExistingID
+			op.Delete = func(ctx context.Context) error {
+				if run.interrupted(deleteNodeID(op.Key)) {
+					_, err := p.client.GetLoadBalancer(ctx, api.GetLoadBalancerParams{
+						ClusterID:          api.ClusterID(run.clusterID),
+						AutoScalingGroupID: asgID,
+						LoadBalancerID:     lbID,
+					})
+					if err != nil {
+						logf(ctx, "LB %s (ASG: %s) was already deleted", action.Name, action.ASGName)
+						return nil
+					}
+				}
+
+				logf(ctx, "Deleting LB: %s (ASG: %s)", action.Name, action.ASGName)
+				err := p.client.DeleteLoadBalancer(ctx, api.DeleteLoadBalancerParams{
+					ClusterID:          api.ClusterID(run.clusterID),
+					AutoScalingGroupID: asgID,
+					LoadBalancerID:     lbID,
+				})
+				if err != nil {
+					return wrapAPIError(err, fmt.Sprintf("failed to delete LB %s", action.Name))
+				}
+
+				if err := p.waitForLBDeletion(ctx, run.clusterID, asgID, lbID, action.Name); err != nil {
+					return fmt.Errorf("failed waiting for LB deletion: %w", err)
+				}
+				return nil
+			}
+		}
+
+		if action.Action == LBActionCreate || action.Action == LBActionRecreate {
+			// Find the config for this LB
+			var lbCfg *config.LoadBalancerConfig
+			for i := range run.cfg.LoadBalancers {
+				if run.cfg.LoadBalancers[i].Name == action.Name && run.cfg.LoadBalancers[i].AutoScalingGroupName == action.ASGName {
+					lbCfg = &run.cfg.LoadBalancers[i]
+					break
+				}
+			}
+			if lbCfg == nil {
+				return fmt.Errorf("cannot create LB %s: config not found", action.Name)
+			}
+
+			if action.CreateBeforeDestroy {
+				if action.ExistingID == nil || action.ASGID == nil {
+					return fmt.Errorf("cannot replace LB %s: missing ID", action.Name)
+				}
+				op.Apply = func(ctx context.Context) error {
+					return p.replaceLB(ctx, run, action, *lbCfg, run.interrupted(applyNodeID(op.Key)))
+				}
+			} else {
+				op.Apply = func(ctx context.Context) error {
+					asgID, ok := run.asgID(action.ASGName)
+					if !ok {
+						return fmt.Errorf("cannot create LB %s: ASG %s not found", action.Name, action.ASGName)
+					}
+
+					if run.interrupted(applyNodeID(op.Key)) {
+						lbs, err := p.listAllLBs(ctx, run.clusterID, asgID)
+						if err != nil {
+							return err
+						}
+						for _, lb := range lbs {
+							if lb.Name == action.Name && (action.ExistingID == nil || lb.LoadBalancerID != *action.ExistingID) {
+								recordResourceID(ctx, uuid.UUID(lb.LoadBalancerID).String())
+								logf(ctx, "LB %s (ASG: %s) was already created by the previous apply", action.Name, action.ASGName)
+								return nil
+							}
+						}
+					}
+
+					logf(ctx, "Creating LB: %s (ASG: %s)", action.Name, action.ASGName)
+					req := buildCreateLBRequest(*lbCfg)
+					resp, err := p.client.CreateLoadBalancer(ctx, req, api.CreateLoadBalancerParams{
+						ClusterID:          api.ClusterID(run.clusterID),
+						AutoScalingGroupID: asgID,
+					})
+					if err != nil {
+						return wrapAPIError(err, fmt.Sprintf("failed to create LB %s", action.Name))
+					}
+					recordResourceID(ctx, uuid.UUID(resp.LoadBalancer.LoadBalancerID).String())
+					return nil
+				}
+			}
+		}
+
+		if op.Delete != nil || op.Apply != nil {
+			graph.add(op)
+		}
+	}
+
+	return nil
+}
+
+// replaceLB creates the replacement of an LB, waits for it to become healthy, then deletes the existing LB.
+// The existing LB keeps serving until the replacement is ready. If interrupted is true, the steps already
+// done by the previous apply are detected and skipped.
+func (p *Provisioner) replaceLB(ctx context.Context, run *applyRun, action LBAction, lbCfg config.LoadBalancerConfig, interrupted bool) error {
+	asgID, existingID := *action.ASGID, *action.ExistingID
+	createName := action.createName()
+
+	var lbID api.LoadBalancerID
+	created := false
+	if interrupted {
+		lbs, err := p.listAllLBs(ctx, run.clusterID, asgID)
+		if err != nil {
+			return err
+		}
+		for _, lb := range lbs {
+			if lb.Name == createName && lb.LoadBalancerID != existingID {
+				lbID, created = lb.LoadBalancerID, true
+				logf(ctx, "LB %s (ASG: %s) was already created by the previous apply", createName, action.ASGName)
+				break
+			}
+		}
+	}
+
+	if !created {
+		logf(ctx, "Creating LB: %s (ASG: %s) to replace %s", createName, action.ASGName, action.existingName())
+		req := buildCreateLBRequest(lbCfg)
+		req.Name = createName
+		resp, err := p.client.CreateLoadBalancer(ctx, req, api.CreateLoadBalancerParams{
+			ClusterID:          api.ClusterID(run.clusterID),
+			AutoScalingGroupID: asgID,
+		})
+		if err != nil {
+			return wrapAPIError(err, fmt.Sprintf("failed to create LB %s", createName))
+		}
+		lbID = resp.LoadBalancer.LoadBalancerID
+	}
+	recordResourceID(ctx, uuid.UUID(lbID).String())
+
+	if err := p.waitForLBHealthy(ctx, run.clusterID, asgID, lbID, createName); err != nil {
+		return fmt.Errorf("failed waiting for LB %s to become healthy (%s was not deleted): %w", createName, action.existingName(), err)
+	}
+
+	if interrupted {
+		_, err := p.client.GetLoadBalancer(ctx, api.GetLoadBalancerParams{
+			ClusterID:          api.ClusterID(run.clusterID),
+			AutoScalingGroupID: asgID,
+			LoadBalancerID:     existingID,
+		})
+		if err != nil {
+			logf(ctx, "LB %s (ASG: %s) was already deleted", action.existingName(), action.ASGName)
+			return nil
+		}
+	}
+
+	logf(ctx, "Deleting LB: %s (ASG: %s)", action.existingName(), action.ASGName)
+	err := p.client.DeleteLoadBalancer(ctx, api.DeleteLoadBalancerParams{
+		ClusterID:          api.ClusterID(run.clusterID),
+		AutoScalingGroupID: asgID,
+		LoadBalancerID:     existingID,
+	})
+	if err != nil {
+		return wrapAPIError(err, fmt.Sprintf("failed to delete LB %s", action.existingName()))
+	}
+	if err := p.waitForLBDeletion(ctx, run.clusterID, asgID, existingID, action.existingName()); err != nil {
+		return fmt.Errorf("failed waiting for LB deletion: %w", err)
+	}
+
+	if createName != action.Name {
+		logf(ctx, "LB %s (ASG: %s) was replaced by %s; the next apply renames it back to %s", action.Name, action.ASGName, createName, action.Name)
+	}
+	return nil
+}
+
 // listAllLBs retrieves all LBs for an ASG (handling pagination)
 func (p *Provisioner) listAllLBs(ctx context.Context, clusterID uuid.UUID, asgID api.AutoScalingGroupID) ([]api.ReadLoadBalancerDetail, error) {
 	var allLBs []api.ReadLoadBalancerDetail
@@ -193,16 +488,16 @@ func (p *Provisioner) listAllLBs(ctx context.Context, clusterID uuid.UUID, asgID
 }
 
 // compareLB compares current LB with desired config and returns differences
-func compareLB(current api.ReadLoadBalancerDetail, desired config.LoadBalancerConfig) []string {
-	var changes []string
+func compareLB(current api.ReadLoadBalancerDetail, desired config.LoadBalancerConfig) []FieldChange {
+	var changes []FieldChange
 
 	if current.ServiceClassPath != desired.ServiceClassPath {
-		changes = append(changes, fmt.Sprintf("ServiceClassPath: %s -> %s", current.ServiceClassPath, desired.ServiceClassPath))
+		changes = append(changes, updateChange(ResourceLoadBalancer, "ServiceClassPath", current.ServiceClassPath, desired.ServiceClassPath))
 	}
 
 	// Compare NameServers
 	if !compareLBNameServers(current.NameServers, desired.NameServers) {
-		changes = append(changes, fmt.Sprintf("NameServers: %v -> %v", current.NameServers, desired.NameServers))
+		changes = append(changes, updateChange(ResourceLoadBalancer, "NameServers", current.NameServers, desired.NameServers))
 	}
 
 	// Compare Interfaces
@@ -226,11 +521,11 @@ func compareLBNameServers(current []api.IPv4, desired []string) bool {
 }
 
 // compareLBInterfaces compares interface configurations
-func compareLBInterfaces(current []api.LoadBalancerInterface, desired []config.LBInterfaceConfig) []string {
-	var changes []string
+func compareLBInterfaces(current []api.LoadBalancerInterface, desired []config.LBInterfaceConfig) []FieldChange {
+	var changes []FieldChange
 
 	if len(current) != len(desired) {
-		changes = append(changes, fmt.Sprintf("Interfaces count: %d -> %d", len(current), len(desired)))
+		changes = append(changes, updateChange(ResourceLoadBalancer, "Interfaces", len(current), len(desired)))
 		return changes
 	}
 
@@ -248,12 +543,12 @@ func compareLBInterfaces(current []api.LoadBalancerInterface, desired []config.L
 	for idx, desiredIface := range desiredByIdx {
 		currentIface, exists := currentByIdx[idx]
 		if !exists {
-			changes = append(changes, fmt.Sprintf("Interface[%d]: new interface", idx))
+			changes = append(changes, addChange(ResourceLoadBalancer, fmt.Sprintf("Interface[%d].Upstream", idx), desiredIface.Upstream))
 			continue
 		}
 
 		if currentIface.Upstream != desiredIface.Upstream {
-			changes = append(changes, fmt.Sprintf("Interface[%d].Upstream: %s -> %s", idx, currentIface.Upstream, desiredIface.Upstream))
+			changes = append(changes, updateChange(ResourceLoadBalancer, fmt.Sprintf("Interface[%d].Upstream", idx), currentIface.Upstream, desiredIface.Upstream))
 		}
 
 		// Compare NetmaskLen
@@ -266,7 +561,7 @@ func compareLBInterfaces(current []api.LoadBalancerInterface, desired []config.L
 			desiredNetmask = *desiredIface.NetmaskLen
 		}
 		if currentNetmask != desiredNetmask {
-			changes = append(changes, fmt.Sprintf("Interface[%d].NetmaskLen: %d -> %d", idx, currentNetmask, desiredNetmask))
+			changes = append(changes, updateChange(ResourceLoadBalancer, fmt.Sprintf("Interface[%d].NetmaskLen", idx), currentNetmask, desiredNetmask))
 		}
 
 		// Compare DefaultGateway
@@ -279,7 +574,7 @@ func compareLBInterfaces(current []api.LoadBalancerInterface, desired []config.L
 			desiredGW = *desiredIface.DefaultGateway
 		}
 		if currentGW != desiredGW {
-			changes = append(changes, fmt.Sprintf("Interface[%d].DefaultGateway: %s -> %s", idx, currentGW, desiredGW))
+			changes = append(changes, updateChange(ResourceLoadBalancer, fmt.Sprintf("Interface[%d].DefaultGateway", idx), currentGW, desiredGW))
 		}
 
 		// Compare Vip
@@ -292,7 +587,7 @@ func compareLBInterfaces(current []api.LoadBalancerInterface, desired []config.L
 			desiredVip = *desiredIface.Vip
 		}
 		if currentVip != desiredVip {
-			changes = append(changes, fmt.Sprintf("Interface[%d].Vip: %s -> %s", idx, currentVip, desiredVip))
+			changes = append(changes, updateChange(ResourceLoadBalancer, fmt.Sprintf("Interface[%d].Vip", idx), currentVip, desiredVip))
 		}
 
 		// Compare VirtualRouterID
@@ -305,7 +600,7 @@ func compareLBInterfaces(current []api.LoadBalancerInterface, desired []config.L
 			desiredVRID = *desiredIface.VirtualRouterID
 		}
 		if currentVRID != desiredVRID {
-			changes = append(changes, fmt.Sprintf("Interface[%d].VirtualRouterID: %d -> %d", idx, currentVRID, desiredVRID))
+			changes = append(changes, updateChange(ResourceLoadBalancer, fmt.Sprintf("Interface[%d].VirtualRouterID", idx), currentVRID, desiredVRID))
 		}
 
 		// Compare PacketFilterID
@@ -318,12 +613,12 @@ func compareLBInterfaces(current []api.LoadBalancerInterface, desired []config.L
 			desiredPF = *desiredIface.PacketFilterID
 		}
 		if currentPF != desiredPF {
-			changes = append(changes, fmt.Sprintf("Interface[%d].PacketFilterID: %s -> %s", idx, currentPF, desiredPF))
+			changes = append(changes, updateChange(ResourceLoadBalancer, fmt.Sprintf("Interface[%d].PacketFilterID", idx), currentPF, desiredPF))
 		}
 
 		// Compare IpPool
 		if !compareLBIPPools(currentIface.IpPool, desiredIface.IpPool) {
-			changes = append(changes, fmt.Sprintf("Interface[%d].IpPool: changed", idx))
+			changes = append(changes, updateChange(ResourceLoadBalancer, fmt.Sprintf("Interface[%d].IpPool", idx), formatIPPool(currentIface.IpPool), formatIPPoolConfig(desiredIface.IpPool)))
 		}
 	}
 
@@ -343,13 +638,13 @@ func compareLBIPPools(current []api.IpRange, desired []config.IpRangeConfig) boo
 	return true
 }
 
-// describeLBConfig returns a description of LB configuration for plan output
-func describeLBConfig(cfg config.LoadBalancerConfig) []string {
-	return []string{
-		fmt.Sprintf("AutoScalingGroup: %s", cfg.AutoScalingGroupName),
-		fmt.Sprintf("ServiceClassPath: %s", cfg.ServiceClassPath),
-		fmt.Sprintf("NameServers: %v", cfg.NameServers),
-		fmt.Sprintf("Interfaces: %d configured", len(cfg.Interfaces)),
+// describeLBConfig returns the configuration of a new LB for plan output
+func describeLBConfig(cfg config.LoadBalancerConfig) []FieldChange {
+	return []FieldChange{
+		addChange(ResourceLoadBalancer, "AutoScalingGroup", cfg.AutoScalingGroupName),
+		addChange(ResourceLoadBalancer, "ServiceClassPath", cfg.ServiceClassPath),
+		addChange(ResourceLoadBalancer, "NameServers", cfg.NameServers),
+		addChange(ResourceLoadBalancer, "Interfaces", len(cfg.Interfaces)),
 	}
 }
 
@@ -403,6 +698,74 @@ func buildCreateLBRequest(cfg config.LoadBalancerConfig) *api.CreateLoadBalancer
 	return req
 }
 
+// listAllLBNodes retrieves all nodes of an LB (handling pagination)
+func (p *Provisioner) listAllLBNodes(ctx context.Context, clusterID uuid.UUID, asgID api.AutoScalingGroupID, lbID api.LoadBalancerID) ([]api.ReadLoadBalancerNodeSummary, error) {
+	var allNodes []api.ReadLoadBalancerNodeSummary
+
+	params := api.ListLoadBalancerNodesParams{
+		ClusterID:          api.ClusterID(clusterID),
+		AutoScalingGroupID: asgID,
+		LoadBalancerID:     lbID,
+		MaxItems:           30,
+	}
+
+	for {
+		resp, err := p.client.ListLoadBalancerNodes(ctx, params)
+		if err != nil {
+			return nil, wrapAPIError(err, "failed to list load balancer nodes")
+		}
+
+		allNodes = append(allNodes, resp.LoadBalancerNodes...)
+
+		if !resp.NextCursor.Set {
+			break
+		}
+		params.Cursor = api.NewOptLoadBalancerID(api.LoadBalancerID(resp.NextCursor.Value))
+	}
+
+	return allNodes, nil
+}
+
+// waitForLBHealthy polls until all nodes of the LB are healthy or timeout
+func (p *Provisioner) waitForLBHealthy(ctx context.Context, clusterID uuid.UUID, asgID api.AutoScalingGroupID, lbID api.LoadBalancerID, lbName string) error {
+	startTime := time.Now()
+	pollInterval := 3 * time.Second
+	timeout := 10 * time.Minute
+
+	for {
+		elapsed := time.Since(startTime)
+		if elapsed > timeout {
+			return fmt.Errorf("timeout waiting for LB %s to become healthy after %v", lbName, elapsed)
+		}
+
+		nodes, err := p.listAllLBNodes(ctx, clusterID, asgID, lbID)
+		if err != nil {
+			return err
+		}
+
+		healthy := 0
+		for _, node := range nodes {
+			if msg, ok := node.CreateErrorMessage.Get(); ok && msg != "" {
+				return fmt.Errorf("failed to create node of LB %s: %s", lbName, msg)
+			}
+			if node.Status == api.LoadBalancerNodeStatusHealthy {
+				healthy++
+			}
+		}
+		if len(nodes) > 0 && healthy == len(nodes) {
+			logf(ctx, "LB %s is healthy (elapsed: %.1fs)", lbName, elapsed.Seconds())
+			return nil
+		}
+
+		logf(ctx, "Waiting for LB %s to become healthy... (%d/%d nodes healthy, elapsed: %.1fs)", lbName, healthy, len(nodes), elapsed.Seconds())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
 // waitForLBDeletion polls until the LB is deleted or timeout
 func (p *Provisioner) waitForLBDeletion(ctx context.Context, clusterID uuid.UUID, asgID api.AutoScalingGroupID, lbID api.LoadBalancerID, lbName string) error {
 	startTime := time.Now()
@@ -430,11 +793,15 @@ func (p *Provisioner) waitForLBDeletion(ctx context.Context, clusterID uuid.UUID
 				return fmt.Errorf("failed to check LB status: %w", err)
 			}
 			// Assume deleted if we get an error (typically 404)
-			log.Printf("LB %s deleted (elapsed: %.1fs)", lbName, elapsed.Seconds())
+			logf(ctx, "LB %s deleted (elapsed: %.1fs)", lbName, elapsed.Seconds())
 			return nil
 		}
 
-		log.Printf("Waiting for LB %s deletion... (elapsed: %.1fs)", lbName, elapsed.Seconds())
-		time.Sleep(pollInterval)
+		logf(ctx, "Waiting for LB %s deletion... (elapsed: %.1fs)", lbName, elapsed.Seconds())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
 	}
 }
@@ -160,38 +160,41 @@ type CompareSpecsOptions struct {
 	SkipImage bool
 }
 
-// CompareSpecs compares two normalized specs and returns human-readable changes
-func CompareSpecs(from, to *NormalizedSpec, opts CompareSpecsOptions) ([]string, error) {
+// CompareSpecs compares two normalized specs and returns the changed fields
+func CompareSpecs(from, to *NormalizedSpec, opts CompareSpecsOptions) ([]FieldChange, error) {
 	changelog, err := diff.Diff(from, to)
 	if err != nil {
 		return nil, fmt.Errorf("failed to diff specs: %w", err)
 	}
 
-	changes := make([]string, 0, len(changelog))
+	changes := make([]FieldChange, 0, len(changelog))
 	for _, change := range changelog {
 		// Skip Image field if requested
 		if opts.SkipImage && len(change.Path) > 0 && change.Path[0] == "Image" {
 			continue
 		}
-		changes = append(changes, formatChange(change))
+		changes = append(changes, toFieldChange(change))
 	}
 
 	return changes, nil
 }
 
-// formatChange converts a diff.Change to a human-readable string
-func formatChange(c diff.Change) string {
+// toFieldChange converts a diff.Change to a FieldChange
+func toFieldChange(c diff.Change) FieldChange {
 	path := strings.Join(c.Path, ".")
 
 	switch c.Type {
 	case diff.CREATE:
-		return fmt.Sprintf("%s: (unset) -> %v", path, formatValue(c.To))
-	case diff.UPDATE:
-		return fmt.Sprintf("%s: %v -> %v", path, formatValue(c.From), formatValue(c.To))
+		return addChange(ResourceApplication, path, formatValue(c.To))
 	case diff.DELETE:
-		return fmt.Sprintf("%s: %v -> (unset)", path, formatValue(c.From))
+		return FieldChange{
+			Resource: ResourceApplication,
+			Path:     path,
+			Kind:     ChangeRemove,
+			Old:      fmt.Sprint(formatValue(c.From)),
+		}
 	default:
-		return fmt.Sprintf("%s: %v -> %v", path, formatValue(c.From), formatValue(c.To))
+		return updateChange(ResourceApplication, path, formatValue(c.From), formatValue(c.To))
 	}
 }
 
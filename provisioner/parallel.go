@@ -0,0 +1,157 @@
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+)
+
+// taskLoggerKey is the context key of the per-task logger
+type taskLoggerKey struct{}
+
+// logf logs a message using the task logger in ctx, or the standard logger outside of tasks.
+// Messages of parallel tasks are buffered so that the output stays in task order.
+func logf(ctx context.Context, format string, args ...any) {
+	if logger, ok := ctx.Value(taskLoggerKey{}).(*log.Logger); ok {
+		logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// taskLog is the output of a task logger. It keeps the messages separately, so that they
+// can be written through the standard logger when flushed.
+type taskLog []string
+
+func (l *taskLog) Write(p []byte) (int, error) {
+	*l = append(*l, string(p))
+	return len(p), nil
+}
+
+// flush writes the messages through the standard logger, whose lock serializes them
+// with the messages other goroutines log with log.Printf at the same time
+func (l taskLog) flush() {
+	for _, msg := range l {
+		_ = log.Output(2, msg)
+	}
+}
+
+// runParallel runs fn for indexes 0..n-1 with at most parallelism concurrent calls.
+// The first error cancels the context passed to the remaining calls, and tasks not yet started are skipped.
+// Log messages written with logf are flushed in index order, so the output is the same as a sequential run.
+// It returns the first error that occurred.
+func runParallel(ctx context.Context, parallelism, n int, fn func(ctx context.Context, i int) error) error {
+	return runDAG(ctx, parallelism, make([][]int, n), fn)
+}
+
+// runDAG runs fn for indexes 0..len(deps)-1 with at most parallelism concurrent calls.
+// Task i starts only after all tasks in deps[i] have succeeded; ready tasks start in index order.
+// Errors, cancellation and logging behave as in runParallel.
+func runDAG(ctx context.Context, parallelism int, deps [][]int, fn func(ctx context.Context, i int) error) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	n := len(deps)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	logs := make([]taskLog, n)
+	done := make([]chan struct{}, n)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	// Flush buffered logs in order as tasks complete
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		for i := range n {
+			<-done[i]
+			logs[i].flush()
+		}
+	}()
+
+	// Count unfinished dependencies of each task
+	waiting := make([]int, n)
+	dependents := make([][]int, n)
+	var ready []int
+	for i, ds := range deps {
+		waiting[i] = len(ds)
+		for _, d := range ds {
+			dependents[d] = append(dependents[d], i)
+		}
+		if len(ds) == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	type result struct {
+		index int
+		err   error
+	}
+	results := make(chan result)
+	closed := make([]bool, n)
+	running, completed := 0, 0
+	var firstErr error
+
+	for completed < n {
+		// Start ready tasks while there are free slots and nothing has failed
+		for firstErr == nil && ctx.Err() == nil && running < parallelism && len(ready) > 0 {
+			i := ready[0]
+			ready = ready[1:]
+			running++
+			go func(i int) {
+				// The standard logger adds the prefix and flags when the messages are flushed
+				logger := log.New(&logs[i], "", 0)
+				results <- result{index: i, err: fn(context.WithValue(ctx, taskLoggerKey{}, logger), i)}
+			}(i)
+		}
+		if running == 0 {
+			// Nothing left that can start: failed, cancelled or blocked by a cycle
+			break
+		}
+
+		r := <-results
+		running--
+		completed++
+		closed[r.index] = true
+		close(done[r.index])
+
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+				cancel()
+			}
+			continue
+		}
+		for _, dependent := range dependents[r.index] {
+			waiting[dependent]--
+			if waiting[dependent] == 0 {
+				pos, _ := slices.BinarySearch(ready, dependent)
+				ready = slices.Insert(ready, pos, dependent)
+			}
+		}
+	}
+
+	// Release skipped tasks so that the flusher finishes
+	for i := range n {
+		if !closed[i] {
+			close(done[i])
+		}
+	}
+	<-flushed
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		// Report cancellation of the parent context
+		return err
+	}
+	if completed < n {
+		return errors.New("dependency cycle detected")
+	}
+	return nil
+}
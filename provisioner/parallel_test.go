@@ -0,0 +1,154 @@
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/state"
+)
+
+// captureLog redirects the standard logger to a buffer for the duration of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	writer, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(writer)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+func TestRunParallel_LogsInOrder(t *testing.T) {
+	buf := captureLog(t)
+
+	err := runParallel(context.Background(), 4, 8, func(ctx context.Context, i int) error {
+		// Later tasks finish first
+		time.Sleep(time.Duration(8-i) * time.Millisecond)
+		logf(ctx, "task %d start", i)
+		logf(ctx, "task %d done", i)
+		return nil
+	})
+	require.NoError(t, err)
+
+	var want bytes.Buffer
+	for i := range 8 {
+		fmt.Fprintf(&want, "task %d start\ntask %d done\n", i, i)
+	}
+	assert.Equal(t, want.String(), buf.String())
+}
+
+func TestRunParallel_FlushesThroughStandardLogger(t *testing.T) {
+	buf := captureLog(t)
+	log.SetPrefix("[test] ")
+	t.Cleanup(func() { log.SetPrefix("") })
+
+	// Running tasks log directly while the logs of finished tasks are flushed
+	err := runParallel(context.Background(), 4, 16, func(ctx context.Context, i int) error {
+		logf(ctx, "task %d buffered", i)
+		for range 10 {
+			log.Printf("task %d live", i)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 16*11)
+	for _, line := range lines {
+		// Each message is written whole, with the prefix of the standard logger added once
+		assert.Regexp(t, `^\[test\] task \d+ (buffered|live)$`, line)
+	}
+}
+
+func TestRunParallel_BoundsConcurrency(t *testing.T) {
+	var running, maxRunning atomic.Int32
+
+	err := runParallel(context.Background(), 3, 20, func(ctx context.Context, i int) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			current := maxRunning.Load()
+			if n <= current || maxRunning.CompareAndSwap(current, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.LessOrEqual(t, maxRunning.Load(), int32(3))
+}
+
+func TestRunParallel_FirstErrorCancelsRest(t *testing.T) {
+	captureLog(t)
+	errBoom := errors.New("boom")
+	var started atomic.Int32
+
+	err := runParallel(context.Background(), 2, 10, func(ctx context.Context, i int) error {
+		started.Add(1)
+		if i == 0 {
+			return errBoom
+		}
+		// Wait until cancelled by the failing task
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return errors.New("not cancelled")
+		}
+	})
+
+	require.ErrorIs(t, err, errBoom)
+	assert.Less(t, started.Load(), int32(10), "tasks after the failure should not start")
+}
+
+func TestCreatePlan_Parallel(t *testing.T) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	clusterID := createTestCluster(mockServer, "my-cluster")
+
+	cfg := &config.ClusterConfig{ClusterName: "my-cluster"}
+	for i := range 10 {
+		name := fmt.Sprintf("app-%02d", i)
+		appID := createTestApplication(mockServer, clusterID, name)
+		createTestVersion(mockServer, appID, 1, 500, 1024)
+		cfg.Applications = append(cfg.Applications, config.ApplicationConfig{
+			Name: name,
+			Spec: config.ApplicationSpec{
+				CPU:         1000,
+				Memory:      1024,
+				ScalingMode: "manual",
+				FixedScale:  int32Ptr(2),
+				ExposedPorts: []config.ExposedPortConfig{
+					{TargetPort: 80, LoadBalancerPort: int32Ptr(443), UseLetsEncrypt: true},
+				},
+			},
+		})
+	}
+
+	provisioner := NewProvisioner(client, state.NewState(), "")
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{Parallelism: 4})
+	require.NoError(t, err)
+
+	require.Len(t, plan.Actions, 10)
+	for i, action := range plan.Actions {
+		assert.Equal(t, fmt.Sprintf("app-%02d", i), action.ApplicationName)
+		assert.Equal(t, ActionUpdate, action.Action)
+		assert.Contains(t, FormatChanges(action.Changes), "CPU: 500 -> 1000")
+	}
+}
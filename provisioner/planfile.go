@@ -0,0 +1,184 @@
+package provisioner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/api"
+)
+
+// planFileFormatVersion is the format version of saved plan files
+const planFileFormatVersion = 2
+
+// planFile is the on-disk representation of a saved plan
+type planFile struct {
+	FormatVersion int   `json:"formatVersion"`
+	Plan          *Plan `json:"plan"`
+}
+
+// SavePlan writes the plan to the given path so that it can be applied later
+func SavePlan(path string, plan *Plan) error {
+	data, err := json.MarshalIndent(planFile{
+		FormatVersion: planFileFormatVersion,
+		Plan:          plan,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan: %w", err)
+	}
+
+	// The plan may contain non-secret env values, so keep it private
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write plan file: %w", err)
+	}
+	return nil
+}
+
+// LoadPlan reads a plan saved by SavePlan
+func LoadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file: %w", err)
+	}
+
+	var pf planFile
+	if err := json.Unmarshal(data, &pf); err != nil {
+		return nil, fmt.Errorf("failed to parse plan file: %w", err)
+	}
+	if pf.FormatVersion != planFileFormatVersion {
+		return nil, fmt.Errorf("unsupported plan file format version %d (expected %d)", pf.FormatVersion, planFileFormatVersion)
+	}
+	if pf.Plan == nil {
+		return nil, fmt.Errorf("plan file contains no plan")
+	}
+
+	return pf.Plan, nil
+}
+
+// VerifyPlan checks that the cluster has not changed since the plan was created.
+// It compares the cluster ID, the ASG/LB IDs and the latest application version numbers
+// recorded in the plan with the current state, and returns an error describing every mismatch.
+func (p *Provisioner) VerifyPlan(ctx context.Context, plan *Plan) error {
+	clusterID, err := p.resolveClusterID(ctx, plan.ClusterName)
+	if err != nil {
+		return fmt.Errorf("failed to resolve cluster: %w", err)
+	}
+	if clusterID != plan.ClusterID {
+		return fmt.Errorf("cluster %q ID changed: %s -> %s", plan.ClusterName, plan.ClusterID, clusterID)
+	}
+
+	var problems []error
+
+	// Verify ASGs
+	currentASGs, err := p.listAllASGs(ctx, clusterID)
+	if err != nil {
+		return fmt.Errorf("failed to list ASGs: %w", err)
+	}
+	asgNameToID := make(map[string]api.AutoScalingGroupID)
+	for _, asg := range currentASGs {
+		asgNameToID[asg.Name] = asg.AutoScalingGroupID
+	}
+
+	for _, action := range plan.ASGActions {
+		currentID, exists := asgNameToID[action.Name]
+		switch {
+		case action.ExistingID == nil && exists:
+			problems = append(problems, fmt.Errorf("ASG %s was created", action.Name))
+		case action.ExistingID != nil && !exists:
+			problems = append(problems, fmt.Errorf("ASG %s was deleted", action.Name))
+		case action.ExistingID != nil && *action.ExistingID != currentID:
+			problems = append(problems, fmt.Errorf("ASG %s ID changed: %s -> %s", action.Name, uuid.UUID(*action.ExistingID), uuid.UUID(currentID)))
+		}
+	}
+
+	// Verify LBs (only for ASGs that existed at plan time)
+	lbsByASG := make(map[api.AutoScalingGroupID]map[string]api.LoadBalancerID)
+	for _, action := range plan.LBActions {
+		if action.ASGID == nil {
+			continue
+		}
+		if _, exists := asgNameToID[action.ASGName]; !exists {
+			// Already reported as an ASG problem
+			continue
+		}
+
+		lbs, ok := lbsByASG[*action.ASGID]
+		if !ok {
+			list, err := p.listAllLBs(ctx, clusterID, *action.ASGID)
+			if err != nil {
+				return fmt.Errorf("failed to list LBs for ASG %s: %w", action.ASGName, err)
+			}
+			lbs = make(map[string]api.LoadBalancerID)
+			for _, lb := range list {
+				lbs[lb.Name] = lb.LoadBalancerID
+			}
+			lbsByASG[*action.ASGID] = lbs
+		}
+
+		name := action.existingName()
+		currentID, exists := lbs[name]
+		switch {
+		case action.ExistingID == nil && exists:
+			problems = append(problems, fmt.Errorf("LB %s (ASG: %s) was created", name, action.ASGName))
+		case action.ExistingID != nil && !exists:
+			problems = append(problems, fmt.Errorf("LB %s (ASG: %s) was deleted", name, action.ASGName))
+		case action.ExistingID != nil && *action.ExistingID != currentID:
+			problems = append(problems, fmt.Errorf("LB %s (ASG: %s) ID changed: %s -> %s", name, action.ASGName, uuid.UUID(*action.ExistingID), uuid.UUID(currentID)))
+		}
+	}
+
+	// Verify applications
+	existing, err := p.listAllApplications(ctx, clusterID)
+	if err != nil {
+		return wrapAPIError(err, "failed to list applications")
+	}
+	existingByName := make(map[string]*api.ReadApplicationDetail)
+	for i := range existing {
+		existingByName[existing[i].Name] = existing[i]
+	}
+
+	for _, action := range plan.Actions {
+		app, exists := existingByName[action.ApplicationName]
+		switch {
+		case action.ApplicationID == nil && exists:
+			problems = append(problems, fmt.Errorf("application %s was created", action.ApplicationName))
+			continue
+		case action.ApplicationID == nil:
+			continue
+		case !exists:
+			problems = append(problems, fmt.Errorf("application %s was deleted", action.ApplicationName))
+			continue
+		case *action.ApplicationID != app.ApplicationID:
+			problems = append(problems, fmt.Errorf("application %s ID changed: %s -> %s", action.ApplicationName, uuid.UUID(*action.ApplicationID), uuid.UUID(app.ApplicationID)))
+			continue
+		}
+
+		if action.Action == ActionDelete {
+			// The application is deleted regardless of its versions
+			continue
+		}
+
+		latest, err := p.getLatestVersionNumber(ctx, app.ApplicationID)
+		if err != nil {
+			return wrapAPIError(err, fmt.Sprintf("failed to get latest version of %s", action.ApplicationName))
+		}
+		if int(latest) != action.LatestVersion {
+			problems = append(problems, fmt.Errorf("application %s latest version changed: %d -> %d", action.ApplicationName, action.LatestVersion, latest))
+		}
+		if action.BaseVersion != action.LatestVersion {
+			// The plan was based on the active version
+			if active, _ := app.ActiveVersion.Get(); int(active) != action.BaseVersion {
+				problems = append(problems, fmt.Errorf("application %s active version changed: %d -> %d", action.ApplicationName, action.BaseVersion, active))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("cluster has changed since the plan was created:\n%w", errors.Join(problems...))
+	}
+	return nil
+}
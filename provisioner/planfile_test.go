@@ -0,0 +1,138 @@
+package provisioner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/state"
+)
+
+func planFileTestConfig() *config.ClusterConfig {
+	return &config.ClusterConfig{
+		ClusterName: "my-cluster",
+		Applications: []config.ApplicationConfig{
+			{
+				Name: "existing-app",
+				Spec: config.ApplicationSpec{
+					CPU:         1000, // Changed
+					Memory:      1024,
+					ScalingMode: "manual",
+					FixedScale:  int32Ptr(2),
+					ExposedPorts: []config.ExposedPortConfig{
+						{TargetPort: 80, LoadBalancerPort: int32Ptr(443), UseLetsEncrypt: true},
+					},
+				},
+			},
+			{
+				Name: "new-app",
+				Spec: config.ApplicationSpec{
+					CPU:         500,
+					Memory:      1024,
+					ScalingMode: "manual",
+					FixedScale:  int32Ptr(1),
+					Image:       "nginx:latest",
+				},
+			},
+		},
+	}
+}
+
+func TestSavePlan_LoadPlan_RoundTrip(t *testing.T) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	appID := createTestApplication(mockServer, clusterID, "existing-app")
+	createTestVersion(mockServer, appID, 1, 500, 1024)
+
+	provisioner := NewProvisioner(client, state.NewState(), "")
+	cfg := planFileTestConfig()
+
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, plan.ConfigHash)
+
+	path := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, SavePlan(path, plan))
+
+	loaded, err := LoadPlan(path)
+	require.NoError(t, err)
+	assert.Equal(t, plan, loaded)
+
+	require.Len(t, loaded.Actions, 2)
+	assert.Equal(t, ActionUpdate, loaded.Actions[0].Action)
+	require.NotNil(t, loaded.Actions[0].ApplicationID)
+	assert.Equal(t, appID, *loaded.Actions[0].ApplicationID)
+	assert.Equal(t, 1, loaded.Actions[0].LatestVersion)
+	assert.Nil(t, loaded.Actions[1].ApplicationID)
+}
+
+func TestVerifyPlan_Unchanged(t *testing.T) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	appID := createTestApplication(mockServer, clusterID, "existing-app")
+	createTestVersion(mockServer, appID, 1, 500, 1024)
+
+	provisioner := NewProvisioner(client, state.NewState(), "")
+	plan, err := provisioner.CreatePlan(context.Background(), planFileTestConfig(), PlanOptions{})
+	require.NoError(t, err)
+
+	assert.NoError(t, provisioner.VerifyPlan(context.Background(), plan))
+}
+
+func TestVerifyPlan_NewLatestVersion(t *testing.T) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	appID := createTestApplication(mockServer, clusterID, "existing-app")
+	createTestVersion(mockServer, appID, 1, 500, 1024)
+
+	provisioner := NewProvisioner(client, state.NewState(), "")
+	plan, err := provisioner.CreatePlan(context.Background(), planFileTestConfig(), PlanOptions{})
+	require.NoError(t, err)
+
+	// Someone creates a new version after the plan was saved
+	createTestVersion(mockServer, appID, 2, 2000, 1024)
+
+	err = provisioner.VerifyPlan(context.Background(), plan)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "application existing-app latest version changed: 1 -> 2")
+}
+
+func TestVerifyPlan_ApplicationCreated(t *testing.T) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	appID := createTestApplication(mockServer, clusterID, "existing-app")
+	createTestVersion(mockServer, appID, 1, 500, 1024)
+
+	provisioner := NewProvisioner(client, state.NewState(), "")
+	plan, err := provisioner.CreatePlan(context.Background(), planFileTestConfig(), PlanOptions{})
+	require.NoError(t, err)
+
+	// The application planned for creation appears in the meantime
+	createTestApplication(mockServer, clusterID, "new-app")
+
+	err = provisioner.VerifyPlan(context.Background(), plan)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "application new-app was created")
+}
+
+func TestLoadPlan_UnsupportedFormatVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"formatVersion": 99, "plan": {}}`), 0600))
+
+	_, err := LoadPlan(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported plan file format version 99")
+}
@@ -0,0 +1,428 @@
+package provisioner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+)
+
+// Severity is the severity of a policy violation or a capacity problem
+type Severity string
+
+const (
+	// SeverityError fails plan and apply
+	SeverityError Severity = "error"
+	// SeverityWarning is reported without failing
+	SeverityWarning Severity = "warning"
+)
+
+// Resources that policy rules are evaluated against
+const (
+	PolicyResourceApplication            = "application"
+	PolicyResourceExposedPort            = "exposedPort"
+	PolicyResourceAutoScalingGroup       = "autoScalingGroup"
+	PolicyResourceLoadBalancer           = "loadBalancer"
+	PolicyResourceApplicationAction      = "applicationAction"
+	PolicyResourceAutoScalingGroupAction = "autoScalingGroupAction"
+	PolicyResourceLoadBalancerAction     = "loadBalancerAction"
+)
+
+var policyResources = []string{
+	PolicyResourceApplication,
+	PolicyResourceExposedPort,
+	PolicyResourceAutoScalingGroup,
+	PolicyResourceLoadBalancer,
+	PolicyResourceApplicationAction,
+	PolicyResourceAutoScalingGroupAction,
+	PolicyResourceLoadBalancerAction,
+}
+
+// PolicyRule is a rule loaded from a policy file.
+// A resource violates the rule when all When conditions match the evaluation context
+// and all Deny conditions match the resource.
+type PolicyRule struct {
+	Name     string   `yaml:"name"`
+	Severity Severity `yaml:"severity"`
+	Message  string   `yaml:"message"`
+	// Resource selects the config entries or plan actions the rule is evaluated against
+	Resource string `yaml:"resource"`
+	// When conditions are evaluated against the context (clusterName, weekday)
+	When []PolicyCondition `yaml:"when"`
+	Deny []PolicyCondition `yaml:"deny"`
+
+	// file is the policy file the rule was loaded from
+	file string
+}
+
+// PolicyCondition matches the value of a field.
+// Exactly one of the operators must be set.
+type PolicyCondition struct {
+	// Field is a dot-separated path such as "spec.image"
+	Field       string   `yaml:"field"`
+	Equals      any      `yaml:"equals"`
+	In          []any    `yaml:"in"`
+	Matches     string   `yaml:"matches"`
+	Exists      *bool    `yaml:"exists"`
+	GreaterThan *float64 `yaml:"greaterThan"`
+	LessThan    *float64 `yaml:"lessThan"`
+
+	re *regexp.Regexp
+}
+
+// PolicySet is the set of rules loaded from a policy directory
+type PolicySet struct {
+	Rules []PolicyRule
+}
+
+// PolicyViolation is a resource that violates a policy rule
+type PolicyViolation struct {
+	Rule     string
+	File     string
+	Severity Severity
+	// Resource describes the violating config entry or plan action
+	Resource string
+	Message  string
+}
+
+func (v PolicyViolation) String() string {
+	return fmt.Sprintf("[%s] %s: %s: %s", v.Severity, v.Rule, v.Resource, v.Message)
+}
+
+// policyFile is the on-disk representation of a policy file
+type policyFile struct {
+	Rules []PolicyRule `yaml:"rules"`
+}
+
+// LoadPolicies reads all YAML policy files (*.yaml, *.yml) in the directory
+func LoadPolicies(dir string) (*PolicySet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy directory: %w", err)
+	}
+
+	set := &PolicySet{}
+	names := map[string]string{}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read policy file: %w", err)
+		}
+
+		var pf policyFile
+		if err := yaml.Unmarshal(data, &pf); err != nil {
+			return nil, fmt.Errorf("failed to parse policy file %s: %w", path, err)
+		}
+		for i := range pf.Rules {
+			rule := &pf.Rules[i]
+			rule.file = entry.Name()
+			if err := rule.compile(); err != nil {
+				return nil, fmt.Errorf("invalid policy file %s: rules[%d]: %w", path, i, err)
+			}
+			if other, ok := names[rule.Name]; ok {
+				return nil, fmt.Errorf("invalid policy file %s: rule %q is also defined in %s", path, rule.Name, other)
+			}
+			names[rule.Name] = entry.Name()
+			set.Rules = append(set.Rules, *rule)
+		}
+	}
+
+	return set, nil
+}
+
+// compile validates the rule and prepares its conditions for evaluation
+func (r *PolicyRule) compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch r.Severity {
+	case "":
+		r.Severity = SeverityError
+	case SeverityError, SeverityWarning:
+	default:
+		return fmt.Errorf("%s: severity must be '%s' or '%s'", r.Name, SeverityError, SeverityWarning)
+	}
+	if r.Message == "" {
+		return fmt.Errorf("%s: message is required", r.Name)
+	}
+	if !slices.Contains(policyResources, r.Resource) {
+		return fmt.Errorf("%s: resource must be one of %s", r.Name, strings.Join(policyResources, ", "))
+	}
+	if len(r.Deny) == 0 {
+		return fmt.Errorf("%s: at least one deny condition is required", r.Name)
+	}
+	for i := range r.When {
+		if err := r.When[i].compile(); err != nil {
+			return fmt.Errorf("%s: when[%d]: %w", r.Name, i, err)
+		}
+	}
+	for i := range r.Deny {
+		if err := r.Deny[i].compile(); err != nil {
+			return fmt.Errorf("%s: deny[%d]: %w", r.Name, i, err)
+		}
+	}
+	return nil
+}
+
+func (c *PolicyCondition) compile() error {
+	if c.Field == "" {
+		return fmt.Errorf("field is required")
+	}
+	operators := 0
+	for _, set := range []bool{c.Equals != nil, c.In != nil, c.Matches != "", c.Exists != nil, c.GreaterThan != nil, c.LessThan != nil} {
+		if set {
+			operators++
+		}
+	}
+	if operators != 1 {
+		return fmt.Errorf("exactly one of equals, in, matches, exists, greaterThan and lessThan is required")
+	}
+	if c.Matches != "" {
+		re, err := regexp.Compile(c.Matches)
+		if err != nil {
+			return fmt.Errorf("invalid matches pattern: %w", err)
+		}
+		c.re = re
+	}
+	return nil
+}
+
+// match reports whether the field of the object satisfies the condition
+func (c *PolicyCondition) match(object map[string]any) bool {
+	value, ok := lookupPolicyField(object, c.Field)
+	if c.Exists != nil {
+		return ok == *c.Exists
+	}
+	if !ok {
+		return false
+	}
+
+	switch {
+	case c.Equals != nil:
+		return fmt.Sprint(value) == fmt.Sprint(c.Equals)
+	case c.In != nil:
+		return slices.ContainsFunc(c.In, func(v any) bool { return fmt.Sprint(value) == fmt.Sprint(v) })
+	case c.re != nil:
+		return c.re.MatchString(fmt.Sprint(value))
+	case c.GreaterThan != nil:
+		n, ok := policyNumber(value)
+		return ok && n > *c.GreaterThan
+	case c.LessThan != nil:
+		n, ok := policyNumber(value)
+		return ok && n < *c.LessThan
+	}
+	return false
+}
+
+// lookupPolicyField follows a dot-separated path through nested maps
+func lookupPolicyField(object map[string]any, path string) (any, bool) {
+	var value any = object
+	for _, key := range strings.Split(path, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		value, ok = m[key]
+		if !ok || value == nil {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func policyNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// policySubject is a config entry or plan action that rules are evaluated against
+type policySubject struct {
+	resource    string
+	description string
+	object      map[string]any
+}
+
+// Evaluate evaluates the rules against the config and the plan, and returns the violations.
+// now is used for the weekday of the evaluation context.
+func (s *PolicySet) Evaluate(cfg *config.ClusterConfig, plan *Plan, now time.Time) ([]PolicyViolation, error) {
+	if s == nil || len(s.Rules) == 0 {
+		return nil, nil
+	}
+
+	subjects, err := policySubjects(cfg, plan)
+	if err != nil {
+		return nil, err
+	}
+	evalContext := map[string]any{
+		"clusterName": cfg.ClusterName,
+		"weekday":     now.Weekday().String(),
+	}
+
+	var violations []PolicyViolation
+	for i := range s.Rules {
+		rule := &s.Rules[i]
+		if !matchAll(rule.When, evalContext) {
+			continue
+		}
+		for _, subject := range subjects {
+			if subject.resource != rule.Resource || !matchAll(rule.Deny, subject.object) {
+				continue
+			}
+			violations = append(violations, PolicyViolation{
+				Rule:     rule.Name,
+				File:     rule.file,
+				Severity: rule.Severity,
+				Resource: subject.description,
+				Message:  rule.Message,
+			})
+		}
+	}
+	return violations, nil
+}
+
+func matchAll(conditions []PolicyCondition, object map[string]any) bool {
+	for i := range conditions {
+		if !conditions[i].match(object) {
+			return false
+		}
+	}
+	return true
+}
+
+// HasPolicyErrors reports whether any violation has error severity
+func HasPolicyErrors(violations []PolicyViolation) bool {
+	return slices.ContainsFunc(violations, func(v PolicyViolation) bool { return v.Severity == SeverityError })
+}
+
+// policySubjects converts the config entries and plan actions to generic objects,
+// using the same field names as the config file
+func policySubjects(cfg *config.ClusterConfig, plan *Plan) ([]policySubject, error) {
+	var subjects []policySubject
+
+	for _, app := range cfg.Applications {
+		object, err := toPolicyObject(app)
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, policySubject{
+			resource:    PolicyResourceApplication,
+			description: fmt.Sprintf("application %s", app.Name),
+			object:      object,
+		})
+
+		ports, _ := lookupPolicyField(object, "spec.exposedPorts")
+		portList, _ := ports.([]any)
+		for i, port := range portList {
+			portObject, ok := port.(map[string]any)
+			if !ok {
+				continue
+			}
+			subjects = append(subjects, policySubject{
+				resource:    PolicyResourceExposedPort,
+				description: fmt.Sprintf("application %s exposedPorts[%d]", app.Name, i),
+				object:      portObject,
+			})
+		}
+	}
+
+	for _, asg := range cfg.AutoScalingGroups {
+		object, err := toPolicyObject(asg)
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, policySubject{
+			resource:    PolicyResourceAutoScalingGroup,
+			description: fmt.Sprintf("ASG %s", asg.Name),
+			object:      object,
+		})
+	}
+
+	for _, lb := range cfg.LoadBalancers {
+		object, err := toPolicyObject(lb)
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, policySubject{
+			resource:    PolicyResourceLoadBalancer,
+			description: fmt.Sprintf("LB %s (ASG: %s)", lb.Name, lb.AutoScalingGroupName),
+			object:      object,
+		})
+	}
+
+	if plan == nil {
+		return subjects, nil
+	}
+
+	for _, action := range plan.Actions {
+		subjects = append(subjects, policySubject{
+			resource:    PolicyResourceApplicationAction,
+			description: fmt.Sprintf("application %s (%s)", action.ApplicationName, action.Action),
+			object:      policyActionObject(action.ApplicationName, string(action.Action), action.Reason),
+		})
+	}
+	for _, action := range plan.ASGActions {
+		subjects = append(subjects, policySubject{
+			resource:    PolicyResourceAutoScalingGroupAction,
+			description: fmt.Sprintf("ASG %s (%s)", action.Name, action.Action),
+			object:      policyActionObject(action.Name, string(action.Action), action.Reason),
+		})
+	}
+	for _, action := range plan.LBActions {
+		object := policyActionObject(action.Name, string(action.Action), action.Reason)
+		object["autoScalingGroupName"] = action.ASGName
+		subjects = append(subjects, policySubject{
+			resource:    PolicyResourceLoadBalancerAction,
+			description: fmt.Sprintf("LB %s (ASG: %s, %s)", action.Name, action.ASGName, action.Action),
+			object:      object,
+		})
+	}
+
+	return subjects, nil
+}
+
+// toPolicyObject converts a config entry to a generic object through its YAML representation
+func toPolicyObject(v any) (map[string]any, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config for policy evaluation: %w", err)
+	}
+	var object map[string]any
+	if err := yaml.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config for policy evaluation: %w", err)
+	}
+	return object, nil
+}
+
+// policyActionObject builds the object of a plan action
+func policyActionObject(name, action, reason string) map[string]any {
+	return map[string]any{
+		"name":   name,
+		"action": action,
+		"reason": reason,
+	}
+}
@@ -0,0 +1,271 @@
+package provisioner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+)
+
+const houseRulesPolicy = `
+rules:
+  - name: no-latest-image
+    message: images must be pinned to a version
+    resource: application
+    deny:
+      - field: spec.image
+        matches: ":latest$"
+  - name: staging-max-scale
+    message: maxScale must be 10 or less in staging
+    resource: application
+    when:
+      - field: clusterName
+        equals: staging
+    deny:
+      - field: spec.maxScale
+        greaterThan: 10
+  - name: exposed-port-health-check
+    severity: warning
+    message: exposed ports must have a health check
+    resource: exposedPort
+    deny:
+      - field: healthCheck
+        exists: false
+  - name: no-friday-asg-recreate
+    message: ASGs must not be recreated on Fridays
+    resource: autoScalingGroupAction
+    when:
+      - field: weekday
+        in: [Friday]
+    deny:
+      - field: action
+        in: [recreate, delete]
+`
+
+var (
+	thursday = time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	friday   = time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+)
+
+func writePolicy(t *testing.T, content string) string {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "house-rules.yaml"), []byte(content), 0644))
+	return dir
+}
+
+func policyTestConfig(clusterName, image string, maxScale int32) *config.ClusterConfig {
+	return &config.ClusterConfig{
+		ClusterName: clusterName,
+		Applications: []config.ApplicationConfig{
+			{
+				Name: "webapp",
+				Spec: config.ApplicationSpec{
+					CPU:         500,
+					Memory:      1024,
+					ScalingMode: "cpu",
+					MinScale:    int32Ptr(1),
+					MaxScale:    int32Ptr(maxScale),
+					Image:       image,
+					ExposedPorts: []config.ExposedPortConfig{
+						{TargetPort: 80, LoadBalancerPort: int32Ptr(443), UseLetsEncrypt: true},
+					},
+				},
+			},
+		},
+	}
+}
+
+func violatedRules(violations []PolicyViolation) []string {
+	var rules []string
+	for _, v := range violations {
+		rules = append(rules, v.Rule)
+	}
+	return rules
+}
+
+func TestPolicySet_Evaluate(t *testing.T) {
+	policies, err := LoadPolicies(writePolicy(t, houseRulesPolicy))
+	require.NoError(t, err)
+	require.Len(t, policies.Rules, 4)
+
+	recreatePlan := &Plan{
+		ASGActions: []ASGAction{{Name: "web-asg", Action: ASGActionRecreate}},
+	}
+
+	tests := []struct {
+		name  string
+		cfg   *config.ClusterConfig
+		plan  *Plan
+		now   time.Time
+		rules []string
+	}{
+		{
+			name:  "latest image",
+			cfg:   policyTestConfig("production", "nginx:latest", 20),
+			now:   thursday,
+			rules: []string{"no-latest-image", "exposed-port-health-check"},
+		},
+		{
+			name:  "maxScale in staging",
+			cfg:   policyTestConfig("staging", "nginx:1.27", 20),
+			now:   thursday,
+			rules: []string{"staging-max-scale", "exposed-port-health-check"},
+		},
+		{
+			name:  "ASG recreate on Thursday",
+			cfg:   policyTestConfig("production", "nginx:1.27", 20),
+			plan:  recreatePlan,
+			now:   thursday,
+			rules: []string{"exposed-port-health-check"},
+		},
+		{
+			name:  "ASG recreate on Friday",
+			cfg:   policyTestConfig("production", "nginx:1.27", 20),
+			plan:  recreatePlan,
+			now:   friday,
+			rules: []string{"exposed-port-health-check", "no-friday-asg-recreate"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := policies.Evaluate(tt.cfg, tt.plan, tt.now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.rules, violatedRules(violations))
+		})
+	}
+}
+
+func TestPolicySet_EvaluateViolation(t *testing.T) {
+	policies, err := LoadPolicies(writePolicy(t, houseRulesPolicy))
+	require.NoError(t, err)
+
+	cfg := policyTestConfig("production", "nginx:1.27", 3)
+	cfg.Applications[0].Spec.ExposedPorts[0].HealthCheck = &config.HealthCheckConfig{Path: "/health", IntervalSeconds: 10, TimeoutSeconds: 5}
+	violations, err := policies.Evaluate(cfg, &Plan{ASGActions: []ASGAction{{Name: "web-asg", Action: ASGActionDelete}}}, friday)
+	require.NoError(t, err)
+
+	require.Len(t, violations, 1)
+	assert.Equal(t, PolicyViolation{
+		Rule:     "no-friday-asg-recreate",
+		File:     "house-rules.yaml",
+		Severity: SeverityError,
+		Resource: "ASG web-asg (delete)",
+		Message:  "ASGs must not be recreated on Fridays",
+	}, violations[0])
+	assert.True(t, HasPolicyErrors(violations))
+}
+
+func TestPolicySet_WarningsOnly(t *testing.T) {
+	policies, err := LoadPolicies(writePolicy(t, houseRulesPolicy))
+	require.NoError(t, err)
+
+	violations, err := policies.Evaluate(policyTestConfig("production", "nginx:1.27", 3), nil, thursday)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"exposed-port-health-check"}, violatedRules(violations))
+	assert.False(t, HasPolicyErrors(violations))
+}
+
+func TestPolicySet_Nil(t *testing.T) {
+	var policies *PolicySet
+	violations, err := policies.Evaluate(policyTestConfig("production", "nginx:latest", 3), nil, thursday)
+	require.NoError(t, err)
+	assert.Empty(t, violations)
+}
+
+func TestLoadPolicies_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "unknown resource",
+			content: `
+rules:
+  - name: r
+    message: m
+    resource: cluster
+    deny:
+      - field: name
+        equals: x
+`,
+			wantErr: "r: resource must be one of",
+		},
+		{
+			name: "two operators",
+			content: `
+rules:
+  - name: r
+    message: m
+    resource: application
+    deny:
+      - field: name
+        equals: x
+        exists: true
+`,
+			wantErr: "r: deny[0]: exactly one of",
+		},
+		{
+			name: "invalid pattern",
+			content: `
+rules:
+  - name: r
+    message: m
+    resource: application
+    deny:
+      - field: name
+        matches: "("
+`,
+			wantErr: "r: deny[0]: invalid matches pattern",
+		},
+		{
+			name: "no deny",
+			content: `
+rules:
+  - name: r
+    message: m
+    resource: application
+`,
+			wantErr: "r: at least one deny condition is required",
+		},
+		{
+			name: "invalid severity",
+			content: `
+rules:
+  - name: r
+    severity: fatal
+    message: m
+    resource: application
+    deny:
+      - field: name
+        exists: true
+`,
+			wantErr: "r: severity must be 'error' or 'warning'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadPolicies(writePolicy(t, tt.content))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestLoadPolicies_DuplicateName(t *testing.T) {
+	dir := writePolicy(t, houseRulesPolicy)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "more.yml"), []byte(houseRulesPolicy), 0644))
+	// Files other than YAML are ignored
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# policies"), 0644))
+
+	_, err := LoadPolicies(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `rule "no-latest-image" is also defined in house-rules.yaml`)
+}
@@ -0,0 +1,103 @@
+package provisioner
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+)
+
+// pruneApps are the managed application and two applications not in the config
+var pruneApps = []string{"existing-app", "orphan-app", "legacy-app"}
+
+func deletedApps(plan *Plan) []string {
+	var names []string
+	for _, action := range plan.Actions {
+		if action.Action == ActionDelete {
+			names = append(names, action.ApplicationName)
+		}
+	}
+	return names
+}
+
+func TestCreatePlan_Prune_Disabled(t *testing.T) {
+	captureLog(t)
+	_, provisioner, _, cleanup := setupTestCluster(t, "", pruneApps...)
+	defer cleanup()
+
+	plan, err := provisioner.CreatePlan(context.Background(), testClusterConfig(500), PlanOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, deletedApps(plan))
+	assert.False(t, plan.HasChanges())
+}
+
+func TestCreatePlan_Prune_Option(t *testing.T) {
+	captureLog(t)
+	_, provisioner, _, cleanup := setupTestCluster(t, "", pruneApps...)
+	defer cleanup()
+
+	plan, err := provisioner.CreatePlan(context.Background(), testClusterConfig(500), PlanOptions{Prune: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"legacy-app", "orphan-app"}, deletedApps(plan))
+	assert.True(t, plan.HasChanges())
+}
+
+func TestCreatePlan_Prune_ConfigWithKeep(t *testing.T) {
+	captureLog(t)
+	_, provisioner, _, cleanup := setupTestCluster(t, "", pruneApps...)
+	defer cleanup()
+
+	cfg := testClusterConfig(500)
+	cfg.Prune = &config.PruneConfig{Enabled: true, Keep: []string{"legacy-app"}}
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"orphan-app"}, deletedApps(plan))
+
+	// prune.keep also protects applications from --prune
+	plan, err = provisioner.CreatePlan(context.Background(), cfg, PlanOptions{Prune: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"orphan-app"}, deletedApps(plan))
+}
+
+func TestCreatePlan_Prune_IgnoredWithTargets(t *testing.T) {
+	captureLog(t)
+	_, provisioner, _, cleanup := setupTestCluster(t, "", pruneApps...)
+	defer cleanup()
+
+	plan, err := provisioner.CreatePlan(context.Background(), testClusterConfig(500), PlanOptions{
+		Prune:   true,
+		Targets: []Target{{Kind: TargetApplication, Name: "existing-app"}},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, deletedApps(plan))
+}
+
+func TestApply_Prune_DeletesApplication(t *testing.T) {
+	captureLog(t)
+	mockServer, provisioner, clusterID, cleanup := setupTestCluster(t, filepath.Join(t.TempDir(), "config.yaml"), "existing-app", "orphan-app")
+	defer cleanup()
+
+	st := provisioner.state
+	st.SetSecretEnvVersion("orphan-app", "API_KEY", intPtr(1))
+	cfg := testClusterConfig(500)
+
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{Prune: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"orphan-app"}, deletedApps(plan))
+
+	require.NoError(t, provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{}))
+
+	_, found := mockServer.GetApplicationByName(clusterID, "orphan-app")
+	assert.False(t, found)
+	_, found = mockServer.GetApplicationByName(clusterID, "existing-app")
+	assert.True(t, found)
+	assert.Nil(t, st.GetSecretEnvVersion("orphan-app", "API_KEY"), "state of the deleted application should be removed")
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -0,0 +1,108 @@
+package provisioner
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+)
+
+// createBeforeDestroyTestConfig returns a config that changes the service class of the LB created by setupInfraCluster
+func createBeforeDestroyTestConfig() *config.ClusterConfig {
+	cfg := infraTestConfig()
+	cfg.LoadBalancers[0].ServiceClassPath = "cloud/plan/lb-large"
+	cfg.LoadBalancers[0].ReplaceStrategy = config.ReplaceCreateBeforeDestroy
+	return cfg
+}
+
+func TestCreatePlan_CreateBeforeDestroy(t *testing.T) {
+	_, provisioner, _, cleanup := setupInfraCluster(t, filepath.Join(t.TempDir(), "config.yaml"))
+	defer cleanup()
+
+	plan, err := provisioner.CreatePlan(context.Background(), createBeforeDestroyTestConfig(), PlanOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, plan.LBActions, 1)
+	action := plan.LBActions[0]
+	assert.Equal(t, LBActionRecreate, action.Action)
+	assert.True(t, action.CreateBeforeDestroy)
+	assert.Equal(t, "web-lb-next", action.CreateName)
+	assert.Equal(t, []string{"ServiceClassPath: cloud/plan/lb -> cloud/plan/lb-large"}, FormatChanges(action.Changes))
+}
+
+func TestApply_CreateBeforeDestroy_RenamedBackByNextApply(t *testing.T) {
+	captureLog(t)
+	mockServer, provisioner, asgID, cleanup := setupInfraCluster(t, filepath.Join(t.TempDir(), "config.yaml"))
+	defer cleanup()
+	cfg := createBeforeDestroyTestConfig()
+
+	// The replacement is created under a temporary name and the old LB is deleted
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	require.NoError(t, provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{}))
+	assert.Equal(t, []string{"web-lb-next"}, mockServer.LoadBalancerNames(asgID))
+
+	// The next apply replaces it again with the configured name
+	plan, err = provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	require.Len(t, plan.LBActions, 1)
+	action := plan.LBActions[0]
+	assert.Equal(t, LBActionRecreate, action.Action)
+	assert.True(t, action.CreateBeforeDestroy)
+	assert.Equal(t, "web-lb-next", action.ExistingName)
+	assert.Empty(t, action.Changes)
+	assert.Equal(t, "rename the replacement web-lb-next back to web-lb", action.Reason)
+
+	require.NoError(t, provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{}))
+	assert.Equal(t, []string{"web-lb"}, mockServer.LoadBalancerNames(asgID))
+
+	plan, err = provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	require.Len(t, plan.LBActions, 1)
+	assert.Equal(t, LBActionNoop, plan.LBActions[0].Action)
+}
+
+func TestApply_CreateBeforeDestroy_KeepsExistingWhenReplacementFails(t *testing.T) {
+	captureLog(t)
+	mockServer, provisioner, asgID, cleanup := setupInfraCluster(t, filepath.Join(t.TempDir(), "config.yaml"))
+	defer cleanup()
+	cfg := createBeforeDestroyTestConfig()
+	mockServer.FailLoadBalancerNodes("web-lb-next", "out of capacity")
+
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	err = provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "web-lb was not deleted")
+	assert.Contains(t, err.Error(), "out of capacity")
+
+	assert.Equal(t, []string{"web-lb", "web-lb-next"}, mockServer.LoadBalancerNames(asgID))
+
+	// A new plan does not create a second replacement
+	_, err = provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "an LB named web-lb-next already exists")
+}
+
+func TestWaitForLBHealthy_Cancelled(t *testing.T) {
+	captureLog(t)
+	mockServer, provisioner, clusterID, cleanup := setupTestCluster(t, "")
+	defer cleanup()
+	asgID := createTestASG(mockServer, clusterID, "web-asg")
+	// The LB has no nodes, so it never becomes healthy
+	lbID := createTestLB(mockServer, asgID, "web-lb")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := provisioner.waitForLBHealthy(ctx, uuid.UUID(clusterID), asgID, lbID, "web-lb")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	// The wait stops without sleeping for the rest of the poll interval
+	assert.Less(t, time.Since(start), time.Second)
+}
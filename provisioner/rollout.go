@@ -0,0 +1,158 @@
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/api"
+)
+
+// rolloutPollInterval is the interval between checks of the rollout status
+var rolloutPollInterval = 5 * time.Second
+
+// containerStateRunning is the state of a running container
+const containerStateRunning = "running"
+
+// RolloutStatus is the progress of the rollout of an activated version
+type RolloutStatus struct {
+	Version int
+	// DesiredNodes is the number of instances the version should run (fixedScale, or minScale with CPU scaling)
+	DesiredNodes int64
+	// ActiveNodes is the number of nodes running the version
+	ActiveNodes int64
+	// RunningContainers is the number of running containers of the version
+	RunningContainers int64
+	// OldNodes and OldContainers are the nodes and containers still running other versions
+	OldNodes      int64
+	OldContainers int64
+}
+
+// Complete reports whether the version runs at the desired count and the other versions have drained
+func (s *RolloutStatus) Complete() bool {
+	return s.ActiveNodes >= s.DesiredNodes && s.RunningContainers >= s.DesiredNodes && s.OldNodes == 0 && s.OldContainers == 0
+}
+
+func (s *RolloutStatus) String() string {
+	msg := fmt.Sprintf("version %d active on %d/%d nodes, %d/%d containers running", s.Version, s.ActiveNodes, s.DesiredNodes, s.RunningContainers, s.DesiredNodes)
+	if s.OldNodes > 0 || s.OldContainers > 0 {
+		msg += fmt.Sprintf(", old versions draining (%d nodes, %d containers)", s.OldNodes, s.OldContainers)
+	}
+	return msg
+}
+
+// desiredInstances returns the number of instances the version runs at least (fixedScale, or minScale with CPU scaling)
+func desiredInstances(detail *api.ReadApplicationVersionDetail) int64 {
+	if detail.ScalingMode == api.ScalingModeManual {
+		if scale, ok := detail.FixedScale.Get(); ok {
+			return int64(scale)
+		}
+	} else if scale, ok := detail.MinScale.Get(); ok {
+		return int64(scale)
+	}
+	return 1
+}
+
+// getRolloutStatus returns the rollout status of the given version of the application
+func (p *Provisioner) getRolloutStatus(ctx context.Context, appID api.ApplicationID, version api.ApplicationVersionNumber) (*RolloutStatus, error) {
+	versionResp, err := p.client.GetApplicationVersion(ctx, api.GetApplicationVersionParams{
+		ApplicationID: appID,
+		Version:       version,
+	})
+	if err != nil {
+		return nil, wrapAPIError(err, fmt.Sprintf("failed to get version %d", version))
+	}
+	status := &RolloutStatus{Version: int(version), DesiredNodes: desiredInstances(&versionResp.ApplicationVersion)}
+
+	versions, err := p.listAllVersions(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if v.Version == version {
+			status.ActiveNodes = v.ActiveNodeCount
+		} else {
+			status.OldNodes += v.ActiveNodeCount
+		}
+	}
+
+	containersResp, err := p.client.GetApplicationContainers(ctx, api.GetApplicationContainersParams{ApplicationID: appID})
+	if err != nil {
+		return nil, wrapAPIError(err, "failed to get containers")
+	}
+	for _, node := range containersResp.Nodes {
+		stats, ok := node.ContainersStats.Get()
+		if !ok {
+			continue
+		}
+		for _, container := range stats.Containers {
+			switch {
+			case container.ApplicationVersion != int64(version):
+				status.OldContainers++
+			case container.State == containerStateRunning:
+				status.RunningContainers++
+			}
+		}
+	}
+
+	return status, nil
+}
+
+// waitForRollouts waits until the activated versions run at their desired count and older versions have drained.
+// Applications are waited for concurrently, and the errors of all applications are returned.
+func (p *Provisioner) waitForRollouts(ctx context.Context, rollouts []pendingActivation, timeout time.Duration) error {
+	if len(rollouts) == 0 {
+		return nil
+	}
+
+	log.Printf("Waiting up to %s for the rollout of %d applications", timeout, len(rollouts))
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Progress is logged as it happens instead of being buffered per task like runParallel does
+	errs := make([]error, len(rollouts))
+	var wg sync.WaitGroup
+	for i, rollout := range rollouts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = p.waitForRollout(ctx, rollout, timeout)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// waitForRollout polls the rollout status of an application until it completes or the context is done
+func (p *Provisioner) waitForRollout(ctx context.Context, rollout pendingActivation, timeout time.Duration) error {
+	last := "status unknown"
+	for {
+		status, err := p.getRolloutStatus(ctx, rollout.appID, rollout.version)
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("failed to check the rollout of application %s: %w", rollout.appName, err)
+		}
+		if err == nil {
+			if status.Complete() {
+				log.Printf("Application %q: rollout of version %d completed (%d/%d containers running)", rollout.appName, rollout.version, status.RunningContainers, status.DesiredNodes)
+				return nil
+			}
+			if s := status.String(); s != last {
+				log.Printf("Application %q: %s", rollout.appName, s)
+				last = s
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("rollout of application %s did not complete within %s: %s", rollout.appName, timeout, last)
+			}
+			return ctx.Err()
+		case <-time.After(rolloutPollInterval):
+		}
+	}
+}
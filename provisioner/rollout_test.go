@@ -0,0 +1,163 @@
+package provisioner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/api"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/state"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/testutil"
+)
+
+// fastRolloutPolling shortens the rollout poll interval for the test
+func fastRolloutPolling(t *testing.T) {
+	interval := rolloutPollInterval
+	rolloutPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { rolloutPollInterval = interval })
+}
+
+func runningContainers(version int64, n int) []api.ApplicationContainerSummary {
+	var containers []api.ApplicationContainerSummary
+	for range n {
+		containers = append(containers, api.ApplicationContainerSummary{State: "running", ApplicationVersion: version})
+	}
+	return containers
+}
+
+// setupRunningApp creates existing-app with version 1 running on 2 nodes
+func setupRunningApp(t *testing.T) (*testutil.MockServer, *Provisioner, api.ClusterID, api.ApplicationID, func()) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	appID := createTestApplication(mockServer, clusterID, "existing-app")
+	createTestVersion(mockServer, appID, 1, 500, 1024)
+	mockServer.SetActiveNodeCount(appID, 1, 2)
+	mockServer.SetApplicationContainers(appID, runningContainers(1, 2)...)
+	return mockServer, NewProvisioner(client, state.NewState(), ""), clusterID, appID, cleanup
+}
+
+// rolloutWhenActivated replaces version 1 with version 2 once version 2 is activated
+func rolloutWhenActivated(mockServer *testutil.MockServer, clusterID api.ClusterID, appID api.ApplicationID) {
+	go func() {
+		for {
+			app, _ := mockServer.GetApplicationByName(clusterID, "existing-app")
+			if app.ActiveVersion.Value == 2 {
+				break
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		time.Sleep(50 * time.Millisecond)
+		mockServer.SetActiveNodeCount(appID, 2, 2)
+		mockServer.SetActiveNodeCount(appID, 1, 0)
+		mockServer.SetApplicationContainers(appID, runningContainers(2, 2)...)
+	}()
+}
+
+func TestGetRolloutStatus(t *testing.T) {
+	mockServer, provisioner, _, appID, cleanup := setupRunningApp(t)
+	defer cleanup()
+
+	createTestVersion(mockServer, appID, 2, 1000, 1024)
+	mockServer.SetActiveNodeCount(appID, 1, 1)
+	mockServer.SetActiveNodeCount(appID, 2, 1)
+	mockServer.SetApplicationContainers(appID,
+		api.ApplicationContainerSummary{State: "running", ApplicationVersion: 1},
+		api.ApplicationContainerSummary{State: "running", ApplicationVersion: 2},
+		api.ApplicationContainerSummary{State: "created", ApplicationVersion: 2},
+	)
+
+	status, err := provisioner.getRolloutStatus(context.Background(), appID, 2)
+	require.NoError(t, err)
+	assert.Equal(t, &RolloutStatus{
+		Version:           2,
+		DesiredNodes:      2,
+		ActiveNodes:       1,
+		RunningContainers: 1,
+		OldNodes:          1,
+		OldContainers:     1,
+	}, status)
+	assert.False(t, status.Complete())
+	assert.Equal(t, "version 2 active on 1/2 nodes, 1/2 containers running, old versions draining (1 nodes, 1 containers)", status.String())
+
+	mockServer.SetActiveNodeCount(appID, 1, 0)
+	mockServer.SetActiveNodeCount(appID, 2, 2)
+	mockServer.SetApplicationContainers(appID, runningContainers(2, 2)...)
+	status, err = provisioner.getRolloutStatus(context.Background(), appID, 2)
+	require.NoError(t, err)
+	assert.True(t, status.Complete())
+}
+
+func TestApply_Wait(t *testing.T) {
+	logs := captureLog(t)
+	fastRolloutPolling(t)
+	mockServer, provisioner, clusterID, appID, cleanup := setupRunningApp(t)
+	defer cleanup()
+
+	cfg := testClusterConfig(1000)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+
+	rolloutWhenActivated(mockServer, clusterID, appID)
+	require.NoError(t, provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{Activate: true, Wait: true, WaitTimeout: 5 * time.Second}))
+
+	assert.Contains(t, logs.String(), `Application "existing-app": version 2 active on 0/2 nodes`)
+	assert.Contains(t, logs.String(), `Application "existing-app": rollout of version 2 completed (2/2 containers running)`)
+}
+
+func TestApply_Wait_AtomicActivate(t *testing.T) {
+	logs := captureLog(t)
+	fastRolloutPolling(t)
+	mockServer, provisioner, clusterID, appID, cleanup := setupRunningApp(t)
+	defer cleanup()
+
+	cfg := testClusterConfig(1000)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+
+	rolloutWhenActivated(mockServer, clusterID, appID)
+	require.NoError(t, provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{Activate: true, AtomicActivate: true, Wait: true, WaitTimeout: 5 * time.Second}))
+
+	assert.Contains(t, logs.String(), `Application "existing-app": rollout of version 2 completed`)
+}
+
+func TestApply_Wait_Timeout(t *testing.T) {
+	captureLog(t)
+	fastRolloutPolling(t)
+	mockServer, provisioner, clusterID, _, cleanup := setupRunningApp(t)
+	defer cleanup()
+
+	cfg := testClusterConfig(1000)
+	cfg.Applications = append(cfg.Applications, config.ApplicationConfig{
+		Name: "new-app",
+		Spec: cfg.Applications[0].Spec,
+	})
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+
+	err = provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{Activate: true, Wait: true, WaitTimeout: 100 * time.Millisecond})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rollout of application existing-app did not complete within 100ms: version 2 active on 0/2 nodes, 0/2 containers running, old versions draining (2 nodes, 2 containers)")
+	assert.Contains(t, err.Error(), "rollout of application new-app did not complete within 100ms: version 1 active on 0/2 nodes, 0/2 containers running")
+
+	// The versions stay activated
+	app, found := mockServer.GetApplicationByName(clusterID, "existing-app")
+	require.True(t, found)
+	assert.Equal(t, int32(2), app.ActiveVersion.Value)
+}
+
+func TestApply_Wait_WithoutActivate(t *testing.T) {
+	captureLog(t)
+	_, provisioner, _, _, cleanup := setupRunningApp(t)
+	defer cleanup()
+
+	cfg := testClusterConfig(1000)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+
+	// Nothing is activated, so there is no rollout to wait for
+	require.NoError(t, provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{Wait: true, WaitTimeout: time.Millisecond}))
+}
@@ -0,0 +1,126 @@
+package provisioner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/api"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+)
+
+// Snapshot holds the resources fetched while planning.
+// Apply reuses it instead of fetching everything again, so that new versions inherit
+// from the same version the plan was compared with.
+type Snapshot struct {
+	ASGs []api.ReadAutoScalingGroupDetail
+	// LBs holds the LBs of each ASG by ASG name
+	LBs map[string][]api.ReadLoadBalancerDetail
+	// Applications holds the applications of the cluster by name
+	Applications map[string]*ApplicationSnapshot
+}
+
+// ApplicationSnapshot is an application and the versions its plan was based on
+type ApplicationSnapshot struct {
+	Application *api.ReadApplicationDetail
+	// LatestVersion is the latest version (nil if no versions exist or the application was not planned)
+	LatestVersion *api.ReadApplicationVersionDetail
+	// BaseVersion is the version the changes were compared with and settings are inherited from.
+	// It differs from LatestVersion when the application uses base "active".
+	BaseVersion *api.ReadApplicationVersionDetail
+}
+
+// latestVersionNumber returns the number of the latest version (0 if no versions exist)
+func (s *ApplicationSnapshot) latestVersionNumber() api.ApplicationVersionNumber {
+	if s.LatestVersion == nil {
+		return 0
+	}
+	return s.LatestVersion.Version
+}
+
+// asgIDs returns the IDs of the ASGs in the snapshot by name
+func (s *Snapshot) asgIDs() map[string]api.AutoScalingGroupID {
+	ids := make(map[string]api.AutoScalingGroupID)
+	for _, asg := range s.ASGs {
+		ids[asg.Name] = asg.AutoScalingGroupID
+	}
+	return ids
+}
+
+// snapshotApplication fetches the latest version of the application and the version to compare with
+func (p *Provisioner) snapshotApplication(ctx context.Context, app *api.ReadApplicationDetail, base string) (*ApplicationSnapshot, error) {
+	snapshot := &ApplicationSnapshot{Application: app}
+
+	latest, err := p.getLatestVersion(ctx, app.ApplicationID)
+	if err != nil {
+		return nil, wrapAPIError(err, "failed to get latest version")
+	}
+	if latest == nil {
+		return snapshot, nil
+	}
+	snapshot.LatestVersion = latest
+
+	snapshot.BaseVersion, err = p.getBaseVersion(ctx, app, latest, base)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// checkApplicationSnapshot checks that the versions of the application have not changed since the snapshot was taken.
+// Only the latest version number is compared (and the active version with base "active"),
+// since versions cannot be modified once created.
+func (p *Provisioner) checkApplicationSnapshot(ctx context.Context, snapshot *ApplicationSnapshot, base string) error {
+	appID := snapshot.Application.ApplicationID
+
+	latest, err := p.getLatestVersionNumber(ctx, appID)
+	if err != nil {
+		return wrapAPIError(err, "failed to get latest version")
+	}
+	if planned := snapshot.latestVersionNumber(); latest != planned {
+		return fmt.Errorf("application %s changed since the plan was created: latest version %d -> %d; run plan again", snapshot.Application.Name, planned, latest)
+	}
+
+	if base != config.BaseActive {
+		return nil
+	}
+	resp, err := p.client.GetApplication(ctx, api.GetApplicationParams{ApplicationID: appID})
+	if err != nil {
+		return wrapAPIError(err, fmt.Sprintf("failed to get application %s", uuid.UUID(appID)))
+	}
+	planned, _ := snapshot.Application.ActiveVersion.Get()
+	if active, _ := resp.Application.ActiveVersion.Get(); active != planned {
+		return fmt.Errorf("application %s changed since the plan was created: active version %d -> %d; run plan again", snapshot.Application.Name, planned, active)
+	}
+	return nil
+}
+
+// refreshSnapshot returns a copy of the snapshot with the current ASGs and application details.
+// A resumed apply uses it, since the interrupted apply has changed the cluster since the plan was created.
+// Version details are kept, as versions cannot be modified once created.
+func (p *Provisioner) refreshSnapshot(ctx context.Context, clusterID uuid.UUID, snapshot *Snapshot) (*Snapshot, error) {
+	asgs, err := p.listAllASGs(ctx, clusterID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ASGs: %w", err)
+	}
+	apps, err := p.listAllApplications(ctx, clusterID)
+	if err != nil {
+		return nil, wrapAPIError(err, "failed to list applications")
+	}
+
+	refreshed := &Snapshot{
+		ASGs:         asgs,
+		LBs:          snapshot.LBs,
+		Applications: make(map[string]*ApplicationSnapshot),
+	}
+	for _, app := range apps {
+		appSnapshot := &ApplicationSnapshot{Application: app}
+		if planned, ok := snapshot.Applications[app.Name]; ok && planned.Application.ApplicationID == app.ApplicationID {
+			appSnapshot.LatestVersion = planned.LatestVersion
+			appSnapshot.BaseVersion = planned.BaseVersion
+		}
+		refreshed.Applications[app.Name] = appSnapshot
+	}
+	return refreshed, nil
+}
@@ -0,0 +1,94 @@
+package provisioner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/api"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/state"
+)
+
+func TestCreatePlan_Snapshot(t *testing.T) {
+	captureLog(t)
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	asgID := createTestASG(mockServer, clusterID, "web-asg")
+	createTestLB(mockServer, asgID, "web-lb")
+	appID := createTestApplication(mockServer, clusterID, "existing-app")
+	createTestVersion(mockServer, appID, 1, 500, 1024)
+	createTestApplication(mockServer, clusterID, "unmanaged-app")
+
+	provisioner := NewProvisioner(client, state.NewState(), "")
+	plan, err := provisioner.CreatePlan(context.Background(), testClusterConfig(1000), PlanOptions{})
+	require.NoError(t, err)
+	snapshot := plan.Snapshot
+	require.NotNil(t, snapshot)
+
+	require.Len(t, snapshot.ASGs, 1)
+	assert.Equal(t, asgID, snapshot.ASGs[0].AutoScalingGroupID)
+	require.Len(t, snapshot.LBs["web-asg"], 1)
+	assert.Equal(t, "web-lb", snapshot.LBs["web-asg"][0].Name)
+
+	app := snapshot.Applications["existing-app"]
+	require.NotNil(t, app)
+	assert.Equal(t, appID, app.Application.ApplicationID)
+	require.NotNil(t, app.LatestVersion)
+	assert.Equal(t, api.ApplicationVersionNumber(1), app.LatestVersion.Version)
+	assert.Equal(t, app.LatestVersion, app.BaseVersion)
+
+	// Applications that are not planned are recorded without versions
+	unmanaged := snapshot.Applications["unmanaged-app"]
+	require.NotNil(t, unmanaged)
+	assert.Nil(t, unmanaged.LatestVersion)
+}
+
+func TestApply_LatestVersionChangedSincePlan(t *testing.T) {
+	captureLog(t)
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	appID := createTestApplication(mockServer, clusterID, "existing-app")
+	createTestVersion(mockServer, appID, 1, 500, 1024)
+
+	provisioner := NewProvisioner(client, state.NewState(), "")
+	cfg := testClusterConfig(1000)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+
+	// A version is created by someone else between plan and apply
+	createTestVersion(mockServer, appID, 2, 2000, 1024)
+
+	err = provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "application existing-app changed since the plan was created: latest version 1 -> 2; run plan again")
+	assert.Equal(t, 2, mockServer.VersionCount(appID))
+}
+
+func TestApply_ActiveVersionChangedSincePlan(t *testing.T) {
+	captureLog(t)
+	mockServer, provisioner, appID, cleanup := setupBaseCluster(t)
+	defer cleanup()
+
+	cfg := testClusterConfig(600)
+	cfg.Base = config.BaseActive
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 1, plan.Actions[0].BaseVersion)
+
+	// The experimental version is activated between plan and apply
+	req := &api.UpdateApplication{}
+	req.ActiveVersion.SetTo(2)
+	require.NoError(t, mockServer.UpdateApplication(context.Background(), req, api.UpdateApplicationParams{ApplicationID: appID}))
+
+	err = provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "application existing-app changed since the plan was created: active version 1 -> 2; run plan again")
+	assert.Equal(t, 2, mockServer.VersionCount(appID))
+}
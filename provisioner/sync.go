@@ -4,7 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"iter"
 	"log"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,24 +27,108 @@ const (
 	ActionCreate ActionType = "create"
 	ActionUpdate ActionType = "update"
 	ActionNoop   ActionType = "noop"
+	ActionDelete ActionType = "delete" // not in config, deleted by prune
 )
 
 // PlannedAction represents a planned change
 type PlannedAction struct {
 	ApplicationName string
 	Action          ActionType
-	Changes         []string // Description of changes
+	// Reason describes why the action is needed when it is not explained by field changes
+	Reason  string
+	Changes []FieldChange
+	// ApplicationID is the ID of the existing application (nil for create)
+	ApplicationID *api.ApplicationID
+	// LatestVersion is the latest version number the plan was based on (0 if no versions exist)
+	LatestVersion int
+	// BaseVersion is the version the changes are compared with (0 if no versions exist).
+	// It differs from LatestVersion when the application uses base "active".
+	BaseVersion int
 }
 
 // Plan represents the execution plan
 type Plan struct {
 	ClusterName string
 	ClusterID   uuid.UUID
+	// ConfigHash is the hash of the configuration the plan was created from
+	ConfigHash string
+	// Targets limits the plan to the selected resources (empty means the whole config)
+	Targets []Target
 	// Infrastructure actions
 	ASGActions []ASGAction
 	LBActions  []LBAction
 	// Application actions
 	Actions []PlannedAction
+	// Capacity compares the applications with the capacity of the ASGs (nil if the cluster has no ASGs)
+	Capacity *CapacityReport
+	// Snapshot holds the resources fetched while planning, for reuse by apply
+	Snapshot *Snapshot
+}
+
+// HasChanges reports whether the plan contains any action to apply
+func (plan *Plan) HasChanges() bool {
+	// Check for ASG changes (skip doesn't count as a change)
+	for _, action := range plan.ASGActions {
+		if action.Action != ASGActionNoop && action.Action != ASGActionSkip {
+			return true
+		}
+	}
+
+	// Check for LB changes (skip doesn't count as a change)
+	for _, action := range plan.LBActions {
+		if action.Action != LBActionNoop && action.Action != LBActionSkip {
+			return true
+		}
+	}
+
+	// Check for Application changes
+	for _, action := range plan.Actions {
+		if action.Action != ActionNoop {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasDestructiveChanges reports whether the plan recreates or deletes any resource
+func (plan *Plan) HasDestructiveChanges() bool {
+	for _, action := range plan.ASGActions {
+		if action.Action == ASGActionRecreate || action.Action == ASGActionDelete {
+			return true
+		}
+	}
+
+	for _, action := range plan.LBActions {
+		if action.Action == LBActionRecreate || action.Action == LBActionDelete {
+			return true
+		}
+	}
+
+	for _, action := range plan.Actions {
+		if action.Action == ActionDelete {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsTargeted reports whether the plan covers only part of the config
+func (plan *Plan) IsTargeted() bool {
+	return len(plan.Targets) > 0
+}
+
+// PlanOptions contains options for the CreatePlan operation
+type PlanOptions struct {
+	// Targets limits the plan to the selected resources and their dependencies.
+	// If empty, the whole config is planned.
+	Targets []Target
+	// Parallelism is the maximum number of applications (and ASGs for LB listing) planned concurrently
+	Parallelism int
+	// Prune plans deletion of applications that exist in the cluster but not in the config,
+	// except those listed in prune.keep. Pruning is also enabled by prune.enabled in the config.
+	Prune bool
 }
 
 // ApplyOptions contains options for the Apply operation
@@ -48,6 +137,27 @@ type ApplyOptions struct {
 	// If false (default), only creates/updates the version without activating.
 	// If true, also activates the version.
 	Activate bool
+	// AtomicActivate defers activation until versions of all applications have been created.
+	// If an activation fails, the applications activated so far are rolled back to their previous active version.
+	// Only effective together with Activate.
+	AtomicActivate bool
+	// Parallelism is the maximum number of applications applied concurrently
+	Parallelism int
+	// Resume continues the unfinished apply recorded in the journal, skipping completed steps
+	Resume bool
+	// Wait waits after activation until the activated versions run at their desired count
+	// and older versions have drained. Only effective together with Activate.
+	Wait bool
+	// WaitTimeout is the maximum time to wait for the rollouts with Wait
+	WaitTimeout time.Duration
+	// Verify watches the containers of the activated versions for VerifyWindow after activation (and after Wait),
+	// and reactivates the previous version of applications that keep restarting or do not reach running.
+	// Only effective together with Activate.
+	Verify bool
+	// VerifyWindow is how long the containers are watched with Verify
+	VerifyWindow time.Duration
+	// VerifyMaxRestarts is the number of container restarts allowed during the verify window
+	VerifyMaxRestarts int
 }
 
 // VersionInfo contains information about a single version
@@ -72,7 +182,7 @@ type VersionList struct {
 type VersionDiff struct {
 	FromVersion    int
 	ToVersion      int
-	Changes        []string
+	Changes        []FieldChange
 	HasSecretEnv   bool // true if secret env vars exist (values cannot be compared)
 	HasRegistryPwd bool // true if registryPassword exists (value cannot be compared)
 }
@@ -94,38 +204,67 @@ func NewProvisioner(client *api.Client, st *state.State, configPath string) *Pro
 }
 
 // CreatePlan creates an execution plan by comparing config with current state
-func (p *Provisioner) CreatePlan(ctx context.Context, cfg *config.ClusterConfig) (*Plan, error) {
+func (p *Provisioner) CreatePlan(ctx context.Context, cfg *config.ClusterConfig, opts PlanOptions) (*Plan, error) {
+	var targets *targetSet
+	if len(opts.Targets) > 0 {
+		var err error
+		targets, err = newTargetSet(cfg, opts.Targets)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Resolve cluster name to ID
 	clusterID, err := p.resolveClusterID(ctx, cfg.ClusterName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve cluster: %w", err)
 	}
 
+	configHash, err := cfg.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash config: %w", err)
+	}
+
 	plan := &Plan{
 		ClusterName: cfg.ClusterName,
 		ClusterID:   clusterID,
+		ConfigHash:  configHash,
+		Targets:     opts.Targets,
 	}
 
-	// Get current ASGs for planning
+	// Get current ASGs and LBs for planning
 	currentASGs, err := p.listAllASGs(ctx, clusterID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list ASGs: %w", err)
 	}
+	currentLBs, err := p.listLBsByASG(ctx, clusterID, currentASGs, opts.Parallelism)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list LBs: %w", err)
+	}
+	plan.Snapshot = &Snapshot{
+		ASGs:         currentASGs,
+		LBs:          currentLBs,
+		Applications: make(map[string]*ApplicationSnapshot),
+	}
 
 	// Plan ASG changes
-	asgActions, err := p.planASGChanges(ctx, clusterID, cfg.AutoScalingGroups)
+	asgActions, err := planASGChanges(currentASGs, cfg.AutoScalingGroups, cfg.ExclusiveInfrastructure())
 	if err != nil {
 		return nil, fmt.Errorf("failed to plan ASG changes: %w", err)
 	}
 	plan.ASGActions = asgActions
 
 	// Plan LB changes (pass ASG actions to handle ASG recreate scenario)
-	lbActions, err := p.planLBChanges(ctx, clusterID, cfg.LoadBalancers, currentASGs, asgActions)
+	lbActions, err := planLBChanges(cfg.LoadBalancers, currentASGs, currentLBs, asgActions, cfg.ExclusiveInfrastructure())
 	if err != nil {
 		return nil, fmt.Errorf("failed to plan LB changes: %w", err)
 	}
 	plan.LBActions = lbActions
 
+	if targets != nil {
+		plan.ASGActions, plan.LBActions = targets.filterInfraActions(plan.ASGActions, plan.LBActions)
+	}
+
 	// Get existing applications
 	existing, err := p.listAllApplications(ctx, clusterID)
 	if err != nil {
@@ -138,279 +277,533 @@ func (p *Provisioner) CreatePlan(ctx context.Context, cfg *config.ClusterConfig)
 		existingByName[existing[i].Name] = existing[i]
 	}
 
-	// Process each application in the config
-	for _, appCfg := range cfg.Applications {
-		if existingApp, ok := existingByName[appCfg.Name]; ok {
-			// Application exists, check if update is needed
-			action, err := p.planUpdate(ctx, existingApp, &appCfg)
-			if err != nil {
-				return nil, fmt.Errorf("failed to plan update for %s: %w", appCfg.Name, err)
-			}
-			plan.Actions = append(plan.Actions, *action)
-			delete(existingByName, appCfg.Name)
-		} else {
+	// Select the applications to plan
+	var appCfgs []*config.ApplicationConfig
+	for i := range cfg.Applications {
+		if targets == nil || targets.apps[cfg.Applications[i].Name] {
+			appCfgs = append(appCfgs, &cfg.Applications[i])
+		}
+	}
+
+	// Plan each application in parallel (actions keep the config order)
+	actions := make([]PlannedAction, len(appCfgs))
+	appSnapshots := make([]*ApplicationSnapshot, len(appCfgs))
+	err = runParallel(ctx, opts.Parallelism, len(appCfgs), func(ctx context.Context, i int) error {
+		appCfg := appCfgs[i]
+		existingApp, ok := existingByName[appCfg.Name]
+		if !ok {
 			// Application doesn't exist, plan to create it
-			plan.Actions = append(plan.Actions, PlannedAction{
+			actions[i] = PlannedAction{
 				ApplicationName: appCfg.Name,
 				Action:          ActionCreate,
-				Changes:         []string{"Create new application and version"},
-			})
+				Reason:          "Create new application and version",
+			}
+			return nil
+		}
+
+		// Application exists, check if update is needed
+		snapshot, err := p.snapshotApplication(ctx, existingApp, cfg.VersionBase(appCfg))
+		if err != nil {
+			return fmt.Errorf("failed to plan update for %s: %w", appCfg.Name, err)
 		}
+		actions[i] = *p.planUpdate(ctx, snapshot, appCfg)
+		appSnapshots[i] = snapshot
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	plan.Actions = actions
 
-	// Warn about applications not in config
-	for name := range existingByName {
-		log.Printf("WARNING: Application %q exists in AppRun but not in config", name)
+	for _, app := range existing {
+		plan.Snapshot.Applications[app.Name] = &ApplicationSnapshot{Application: app}
+	}
+	for _, snapshot := range appSnapshots {
+		if snapshot != nil {
+			plan.Snapshot.Applications[snapshot.Application.Name] = snapshot
+		}
+	}
+
+	for _, appCfg := range cfg.Applications {
+		delete(existingByName, appCfg.Name)
+	}
+
+	if targets != nil {
+		log.Printf("WARNING: Plan is limited to targets %v; the rest of the config was not checked against the cluster", opts.Targets)
+	} else {
+		// Applications not in config are deleted when pruning, otherwise only reported
+		unmanaged := slices.Sorted(maps.Keys(existingByName))
+		prune := opts.Prune || cfg.PruneEnabled()
+		for _, name := range unmanaged {
+			switch {
+			case !prune:
+				log.Printf("WARNING: Application %q exists in AppRun but not in config", name)
+			case cfg.IsPruneProtected(name):
+				log.Printf("Application %q is not in config but is kept by prune.keep", name)
+			default:
+				appID := existingByName[name].ApplicationID
+				plan.Actions = append(plan.Actions, PlannedAction{
+					ApplicationName: name,
+					Action:          ActionDelete,
+					Reason:          "Delete application not in config (prune)",
+					ApplicationID:   &appID,
+				})
+			}
+		}
+	}
+
+	if err := p.checkASGDeletions(ctx, clusterID, plan); err != nil {
+		return nil, err
+	}
+
+	// The demand is checked for the whole config, even when the plan is targeted
+	plan.Capacity, err = p.planCapacity(ctx, cfg, currentASGs, plan.ASGActions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check capacity: %w", err)
 	}
 
 	return plan, nil
 }
 
-// Apply executes the given plan
-func (p *Provisioner) Apply(ctx context.Context, cfg *config.ClusterConfig, plan *Plan, opts ApplyOptions) error {
-	// Use cluster ID from the plan (already resolved)
-	clusterID := plan.ClusterID
+// checkASGDeletions returns an error if an ASG planned for deletion still runs applications.
+// Applications deleted by the plan itself do not block the deletion, since they are deleted first.
+func (p *Provisioner) checkASGDeletions(ctx context.Context, clusterID uuid.UUID, plan *Plan) error {
+	deletedApps := make(map[string]bool)
+	for _, action := range plan.Actions {
+		if action.Action == ActionDelete {
+			deletedApps[action.ApplicationName] = true
+		}
+	}
 
-	// 1. Delete LBs first (before deleting ASGs, since ASG has-a LB)
-	// Build current ASG name->ID map for LB operations
-	currentASGs, err := p.listAllASGs(ctx, clusterID)
+	for _, action := range plan.ASGActions {
+		if action.Action != ASGActionDelete || action.ExistingID == nil {
+			continue
+		}
+		apps, err := p.applicationsOnASG(ctx, clusterID, *action.ExistingID)
+		if err != nil {
+			return fmt.Errorf("failed to check applications on ASG %s: %w", action.Name, err)
+		}
+		apps = slices.DeleteFunc(apps, func(name string) bool { return deletedApps[name] })
+		if len(apps) > 0 {
+			return fmt.Errorf("cannot delete ASG %s: applications still have active nodes on it: %s", action.Name, strings.Join(apps, ", "))
+		}
+	}
+	return nil
+}
+
+// Apply executes the given plan.
+// Resource operations are run as a dependency graph: deletes run in reverse dependency order
+// (LBs before their ASG), creates in dependency order (ASGs, then LBs, then applications),
+// and independent operations run concurrently up to opts.Parallelism.
+func (p *Provisioner) Apply(ctx context.Context, cfg *config.ClusterConfig, plan *Plan, opts ApplyOptions) error {
+	// Record progress in a journal so that an interrupted apply can be resumed
+	journal, err := p.openJournal(plan, opts)
 	if err != nil {
-		return fmt.Errorf("failed to list ASGs: %w", err)
+		return err
 	}
-	asgNameToID := make(map[string]api.AutoScalingGroupID)
-	for _, asg := range currentASGs {
-		asgNameToID[asg.Name] = asg.AutoScalingGroupID
+	if opts.Resume {
+		// Continue with the options of the interrupted apply
+		opts.Activate = journal.Activate
+		opts.AtomicActivate = journal.AtomicActivate
 	}
 
-	// Delete LBs that need to be deleted or recreated
-	// Collect LBs to delete and wait for them
-	type lbToDelete struct {
-		name  string
-		asgID api.AutoScalingGroupID
-		lbID  api.LoadBalancerID
+	// Reuse the resources fetched by the plan, unless an interrupted apply has changed them since
+	snapshot := plan.Snapshot
+	if snapshot == nil {
+		return fmt.Errorf("the plan has no snapshot of the cluster; run plan again")
 	}
-	var lbsToWait []lbToDelete
-
-	for _, action := range plan.LBActions {
-		if action.Action == LBActionDelete || action.Action == LBActionRecreate {
-			if action.ExistingID == nil || action.ASGID == nil {
-				return fmt.Errorf("cannot delete LB %s: missing ID", action.Name)
-			}
-			log.Printf("Deleting LB: %s (ASG: %s)", action.Name, action.ASGName)
-			err := p.client.DeleteLoadBalancer(ctx, api.DeleteLoadBalancerParams{
-				ClusterID:          api.ClusterID(clusterID),
-				AutoScalingGroupID: *action.ASGID,
-				LoadBalancerID:     *action.ExistingID,
-			})
-			if err != nil {
-				return wrapAPIError(err, fmt.Sprintf("failed to delete LB %s", action.Name))
-			}
-			lbsToWait = append(lbsToWait, lbToDelete{
-				name:  action.Name,
-				asgID: *action.ASGID,
-				lbID:  *action.ExistingID,
-			})
+	if opts.Resume {
+		snapshot, err = p.refreshSnapshot(ctx, plan.ClusterID, snapshot)
+		if err != nil {
+			return err
 		}
 	}
 
-	// Wait for all LBs to be deleted
-	for _, lb := range lbsToWait {
-		if err := p.waitForLBDeletion(ctx, clusterID, lb.asgID, lb.lbID, lb.name); err != nil {
-			return fmt.Errorf("failed waiting for LB deletion: %w", err)
-		}
+	// Use cluster ID from the plan (already resolved)
+	run := &applyRun{
+		clusterID:   plan.ClusterID,
+		cfg:         cfg,
+		opts:        opts,
+		journal:     journal,
+		snapshot:    snapshot,
+		planned:     plan.Snapshot,
+		asgNameToID: snapshot.asgIDs(),
 	}
 
-	// 2. Delete ASGs that need to be deleted or recreated
-	for _, action := range plan.ASGActions {
-		if action.Action == ASGActionDelete || action.Action == ASGActionRecreate {
-			if action.ExistingID == nil {
-				return fmt.Errorf("cannot delete ASG %s: missing ID", action.Name)
-			}
-			log.Printf("Deleting ASG: %s", action.Name)
-			err := p.client.DeleteAutoScalingGroup(ctx, api.DeleteAutoScalingGroupParams{
-				ClusterID:          api.ClusterID(clusterID),
-				AutoScalingGroupID: *action.ExistingID,
-			})
-			if err != nil {
-				return wrapAPIError(err, fmt.Sprintf("failed to delete ASG %s", action.Name))
-			}
+	graph := &resourceGraph{}
+	if err := p.addASGOperations(graph, run, plan.ASGActions); err != nil {
+		return err
+	}
+	if err := p.addLBOperations(graph, run, plan.LBActions); err != nil {
+		return err
+	}
+	applied, err := p.addApplicationOperations(graph, run, plan.Actions)
+	if err != nil {
+		return err
+	}
+
+	applyErr := graph.run(ctx, opts.Parallelism, journal)
 
-			// Wait for ASG to be deleted
-			if err := p.waitForASGDeletion(ctx, clusterID, *action.ExistingID, action.Name); err != nil {
-				return fmt.Errorf("failed waiting for ASG deletion: %w", err)
+	// Record secret versions of the applied applications, even if another operation failed.
+	// When resuming, applications applied by the previous run are recorded as well.
+	stateModified := false
+	for i, action := range plan.Actions {
+		if action.Action != ActionNoop && journal != nil && journal.IsCompleted(appNodeID(action)) {
+			applied[i] = true
+		}
+		if !applied[i] {
+			continue
+		}
+		if action.Action == ActionDelete {
+			// Forget the versions of the deleted application
+			if p.state.RemoveApplication(action.ApplicationName) {
+				stateModified = true
 			}
+		} else if p.updateStateVersions(run.appConfig(action.ApplicationName)) {
+			stateModified = true
+		}
+	}
 
-			delete(asgNameToID, action.Name)
+	// Save state file if modified
+	if stateModified {
+		if err := p.state.Save(p.configPath); err != nil {
+			return errors.Join(applyErr, fmt.Errorf("failed to save state file: %w", err))
 		}
+		log.Printf("State file updated: %s", state.GetStatePath(p.configPath))
 	}
 
-	// 4. Create ASGs that need to be created or recreated
-	for _, action := range plan.ASGActions {
-		if action.Action == ASGActionCreate || action.Action == ASGActionRecreate {
-			// Find the config for this ASG
-			var asgCfg *config.AutoScalingGroupConfig
-			for i := range cfg.AutoScalingGroups {
-				if cfg.AutoScalingGroups[i].Name == action.Name {
-					asgCfg = &cfg.AutoScalingGroups[i]
-					break
-				}
-			}
-			if asgCfg == nil {
-				return fmt.Errorf("cannot create ASG %s: config not found", action.Name)
-			}
+	if applyErr != nil {
+		return applyErr
+	}
 
-			log.Printf("Creating ASG: %s", action.Name)
-			req := buildCreateASGRequest(*asgCfg)
-			resp, err := p.client.CreateAutoScalingGroup(ctx, req, api.CreateAutoScalingGroupParams{
-				ClusterID: api.ClusterID(clusterID),
-			})
-			if err != nil {
-				return wrapAPIError(err, fmt.Sprintf("failed to create ASG %s", action.Name))
-			}
-			asgNameToID[action.Name] = resp.AutoScalingGroup.AutoScalingGroupID
+	// All versions were created, so activate them together
+	if opts.Activate && opts.AtomicActivate {
+		if err := p.activateAll(ctx, run); err != nil {
+			return err
 		}
 	}
 
-	// 5. Create LBs that need to be created or recreated
-	for _, action := range plan.LBActions {
-		if action.Action == LBActionCreate || action.Action == LBActionRecreate {
-			// Find the config for this LB
-			var lbCfg *config.LoadBalancerConfig
-			for i := range cfg.LoadBalancers {
-				if cfg.LoadBalancers[i].Name == action.Name && cfg.LoadBalancers[i].AutoScalingGroupName == action.ASGName {
-					lbCfg = &cfg.LoadBalancers[i]
-					break
-				}
-			}
-			if lbCfg == nil {
-				return fmt.Errorf("cannot create LB %s: config not found", action.Name)
-			}
+	// The apply completed, so there is nothing left to resume
+	if journal != nil {
+		if err := state.RemoveJournal(p.configPath); err != nil {
+			return fmt.Errorf("failed to remove apply journal: %w", err)
+		}
+	}
 
-			asgID, ok := asgNameToID[action.ASGName]
-			if !ok {
-				return fmt.Errorf("cannot create LB %s: ASG %s not found", action.Name, action.ASGName)
+	if !opts.Activate {
+		return nil
+	}
+	var activated []pendingActivation
+	for _, activation := range run.activated {
+		if activation != nil {
+			activated = append(activated, *activation)
+		}
+	}
+	if opts.Wait {
+		if err := p.waitForRollouts(ctx, activated, opts.WaitTimeout); err != nil {
+			err = fmt.Errorf("the apply completed, but the rollout did not: %w", err)
+			if !opts.Verify {
+				return err
 			}
-
-			log.Printf("Creating LB: %s (ASG: %s)", action.Name, action.ASGName)
-			req := buildCreateLBRequest(*lbCfg)
-			_, err := p.client.CreateLoadBalancer(ctx, req, api.CreateLoadBalancerParams{
-				ClusterID:          api.ClusterID(clusterID),
-				AutoScalingGroupID: asgID,
-			})
-			if err != nil {
-				return wrapAPIError(err, fmt.Sprintf("failed to create LB %s", action.Name))
+			// A version that never comes up is as unhealthy as one that fails verification.
+			// Roll back even if the wait was cancelled.
+			rollback := make([]*pendingActivation, len(activated))
+			for i := range activated {
+				rollback[i] = &activated[i]
 			}
+			return errors.Join(err, p.rollbackActivations(context.WithoutCancel(ctx), rollback))
+		}
+	}
+	if opts.Verify {
+		if err := p.verifyActivations(ctx, activated, opts); err != nil {
+			return fmt.Errorf("the apply completed, but the activated versions are not healthy: %w", err)
 		}
 	}
+	return nil
+}
 
-	// 6. Apply Application changes
-	existing, err := p.listAllApplications(ctx, clusterID)
-	if err != nil {
-		return wrapAPIError(err, "failed to list applications")
+// applyRun holds the state shared by the operations of a single Apply
+type applyRun struct {
+	clusterID uuid.UUID
+	cfg       *config.ClusterConfig
+	opts      ApplyOptions
+
+	// journal records the progress of the apply (nil if not journaled)
+	journal *state.Journal
+	// snapshot holds the resources fetched by the plan (refreshed when resuming)
+	snapshot *Snapshot
+	// planned holds the resources as fetched by the plan, before an interrupted apply changed them
+	planned *Snapshot
+
+	// mu guards asgNameToID, which is updated as ASGs are deleted and created,
+	// and activations and activated, which are filled as application versions are created and activated
+	mu          sync.Mutex
+	asgNameToID map[string]api.AutoScalingGroupID
+	// activations holds the deferred activation of each planned application (AtomicActivate only)
+	activations []*pendingActivation
+	// activated holds the version of each planned application activated by this apply, for Wait and Verify
+	activated []*pendingActivation
+}
+
+// interrupted reports whether the step was started by a previous apply but did not complete.
+// Such steps may have partially taken effect, so they check the cluster before repeating API calls.
+func (r *applyRun) interrupted(stepID string) bool {
+	if !r.opts.Resume || r.journal == nil {
+		return false
 	}
+	step, ok := r.journal.Step(stepID)
+	return ok && step.Status != state.StepCompleted
+}
 
-	existingByName := make(map[string]*api.ReadApplicationDetail)
-	for i := range existing {
-		existingByName[existing[i].Name] = existing[i]
+func (r *applyRun) asgID(name string) (api.AutoScalingGroupID, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id, ok := r.asgNameToID[name]
+	return id, ok
+}
+
+func (r *applyRun) setASGID(name string, id api.AutoScalingGroupID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.asgNameToID[name] = id
+}
+
+func (r *applyRun) deleteASGID(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.asgNameToID, name)
+}
+
+// appConfig returns the config of the named application (nil if not in config)
+func (r *applyRun) appConfig(name string) *config.ApplicationConfig {
+	for i := range r.cfg.Applications {
+		if r.cfg.Applications[i].Name == name {
+			return &r.cfg.Applications[i]
+		}
 	}
+	return nil
+}
 
-	// Build config map
-	configByName := make(map[string]*config.ApplicationConfig)
-	for i := range cfg.Applications {
-		configByName[cfg.Applications[i].Name] = &cfg.Applications[i]
+// addApplicationOperations adds the planned application changes to the apply graph.
+// Applications depend on all infrastructure operations in the graph.
+// The returned slice reports, per plan action, whether the application was applied.
+func (p *Provisioner) addApplicationOperations(graph *resourceGraph, run *applyRun, actions []PlannedAction) ([]bool, error) {
+	applied := make([]bool, len(actions))
+	run.activations = make([]*pendingActivation, len(actions))
+	run.activated = make([]*pendingActivation, len(actions))
+
+	var infraKeys []string
+	for _, op := range graph.ops {
+		infraKeys = append(infraKeys, op.Key)
 	}
 
-	stateModified := false
+	for i, action := range actions {
+		if action.Action == ActionDelete {
+			graph.add(p.deleteApplicationOperation(run, action, infraKeys, func() { applied[i] = true }))
+			continue
+		}
 
-	for _, action := range plan.Actions {
-		appCfg, ok := configByName[action.ApplicationName]
-		if !ok {
+		appCfg := run.appConfig(action.ApplicationName)
+		if appCfg == nil {
 			continue
 		}
 
+		op := resourceOperation{
+			Key:       appKey(action.ApplicationName),
+			DependsOn: infraKeys,
+		}
 		switch action.Action {
 		case ActionCreate:
-			if err := p.createApplication(ctx, clusterID, appCfg, opts); err != nil {
-				return fmt.Errorf("failed to create application %s: %w", action.ApplicationName, err)
-			}
-			// Update state with password version
-			if appCfg.Spec.RegistryPasswordVersion != nil {
-				p.state.SetPasswordVersion(appCfg.Name, appCfg.Spec.RegistryPasswordVersion)
-				stateModified = true
-			}
-			// Update state with secret env versions
-			if p.updateSecretEnvVersions(appCfg) {
-				stateModified = true
+			op.Apply = func(ctx context.Context) error {
+				if run.interrupted(applyNodeID(op.Key)) {
+					// The application may have been created by the previous apply
+					apps, err := p.listAllApplications(ctx, run.clusterID)
+					if err != nil {
+						return wrapAPIError(err, "failed to list applications")
+					}
+					for _, app := range apps {
+						if app.Name == action.ApplicationName {
+							versionNum, err := p.resumeApplication(ctx, &ApplicationSnapshot{Application: app}, appCfg, run.cfg.VersionBase(appCfg), 0)
+							if err == nil {
+								err = p.activateApplication(ctx, run, i, pendingActivation{
+									appID:    app.ApplicationID,
+									appName:  appCfg.Name,
+									version:  versionNum,
+									previous: run.previousActiveVersion(appCfg.Name),
+								})
+							}
+							if err != nil {
+								return fmt.Errorf("failed to create application %s: %w", action.ApplicationName, err)
+							}
+							applied[i] = true
+							return nil
+						}
+					}
+				}
+				appID, versionNum, err := p.createApplication(ctx, run.clusterID, appCfg)
+				if err == nil {
+					err = p.activateApplication(ctx, run, i, pendingActivation{
+						appID:    appID,
+						appName:  appCfg.Name,
+						version:  versionNum,
+						previous: api.NilInt32{Null: true},
+					})
+				}
+				if err != nil {
+					return fmt.Errorf("failed to create application %s: %w", action.ApplicationName, err)
+				}
+				applied[i] = true
+				return nil
 			}
 		case ActionUpdate:
-			existingApp := existingByName[action.ApplicationName]
-			if err := p.updateApplication(ctx, existingApp, appCfg, opts); err != nil {
-				return fmt.Errorf("failed to update application %s: %w", action.ApplicationName, err)
+			snapshot, ok := run.snapshot.Applications[action.ApplicationName]
+			if !ok {
+				return nil, fmt.Errorf("cannot update application %s: not found", action.ApplicationName)
 			}
-			// Update state with password version
-			storedVersion := p.state.GetPasswordVersion(appCfg.Name)
-			desiredVersion := appCfg.Spec.RegistryPasswordVersion
-			if desiredVersion != nil {
-				if storedVersion == nil || *storedVersion != *desiredVersion {
-					p.state.SetPasswordVersion(appCfg.Name, desiredVersion)
-					stateModified = true
+			op.Apply = func(ctx context.Context) error {
+				var (
+					versionNum api.ApplicationVersionNumber
+					err        error
+				)
+				if run.interrupted(applyNodeID(op.Key)) {
+					// A version may have been created by the previous apply
+					versionNum, err = p.resumeApplication(ctx, snapshot, appCfg, run.cfg.VersionBase(appCfg), action.LatestVersion)
+				} else {
+					versionNum, err = p.updateApplication(ctx, snapshot, appCfg, run.cfg.VersionBase(appCfg))
 				}
-			} else if storedVersion != nil {
-				// Remove version if password was removed
-				p.state.SetPasswordVersion(appCfg.Name, nil)
-				stateModified = true
-			}
-			// Update state with secret env versions
-			if p.updateSecretEnvVersions(appCfg) {
-				stateModified = true
+				if err == nil {
+					err = p.activateApplication(ctx, run, i, pendingActivation{
+						appID:    snapshot.Application.ApplicationID,
+						appName:  appCfg.Name,
+						version:  versionNum,
+						previous: run.previousActiveVersion(appCfg.Name),
+					})
+				}
+				if err != nil {
+					return fmt.Errorf("failed to update application %s: %w", action.ApplicationName, err)
+				}
+				applied[i] = true
+				return nil
 			}
 		case ActionNoop:
-			log.Printf("Application %q is up to date", action.ApplicationName)
+			op.Apply = func(ctx context.Context) error {
+				logf(ctx, "Application %q is up to date", action.ApplicationName)
+				return nil
+			}
+		}
+		if action.Action != ActionNoop {
+			// A version created by the previous apply is activated together with the others
+			if err := run.restoreActivation(i, appCfg.Name); err != nil {
+				return nil, err
+			}
 		}
+		graph.add(op)
 	}
 
-	// Save state file if modified
-	if stateModified {
-		if err := p.state.Save(p.configPath); err != nil {
-			return fmt.Errorf("failed to save state file: %w", err)
+	return applied, nil
+}
+
+// deleteApplicationOperation returns the operation deleting an application pruned from the config.
+// The application is deleted before the infrastructure it may run on.
+func (p *Provisioner) deleteApplicationOperation(run *applyRun, action PlannedAction, infraKeys []string, onDeleted func()) resourceOperation {
+	op := resourceOperation{
+		Key:       appKey(action.ApplicationName),
+		DependsOn: infraKeys,
+	}
+	op.Delete = func(ctx context.Context) error {
+		if action.ApplicationID == nil {
+			return fmt.Errorf("cannot delete application %s: ID is unknown", action.ApplicationName)
 		}
-		log.Printf("State file updated: %s", state.GetStatePath(p.configPath))
+		appID := *action.ApplicationID
+		if run.interrupted(deleteNodeID(op.Key)) {
+			// The application may have been deleted by the previous apply
+			apps, err := p.listAllApplications(ctx, run.clusterID)
+			if err != nil {
+				return wrapAPIError(err, "failed to list applications")
+			}
+			if !slices.ContainsFunc(apps, func(app *api.ReadApplicationDetail) bool { return app.ApplicationID == appID }) {
+				logf(ctx, "Application %q was already deleted", action.ApplicationName)
+				onDeleted()
+				return nil
+			}
+		}
+
+		logf(ctx, "Deleting application %q (ID: %s)", action.ApplicationName, uuid.UUID(appID))
+		recordResourceID(ctx, uuid.UUID(appID).String())
+		if err := p.client.DeleteApplication(ctx, api.DeleteApplicationParams{ApplicationID: appID}); err != nil {
+			return wrapAPIError(err, fmt.Sprintf("failed to delete application %s", action.ApplicationName))
+		}
+		logf(ctx, "Deleted application %q", action.ApplicationName)
+		onDeleted()
+		return nil
 	}
+	return op
+}
 
-	return nil
+// appNodeID returns the ID of the graph step that carries out the planned application action
+func appNodeID(action PlannedAction) string {
+	if action.Action == ActionDelete {
+		return deleteNodeID(appKey(action.ApplicationName))
+	}
+	return applyNodeID(appKey(action.ApplicationName))
+}
+
+// updateStateVersions records the registry password version and secret env versions of an applied application.
+// Returns true if the state was modified.
+func (p *Provisioner) updateStateVersions(appCfg *config.ApplicationConfig) bool {
+	modified := false
+
+	// Update state with password version
+	storedVersion := p.state.GetPasswordVersion(appCfg.Name)
+	desiredVersion := appCfg.Spec.RegistryPasswordVersion
+	if desiredVersion != nil {
+		if storedVersion == nil || *storedVersion != *desiredVersion {
+			p.state.SetPasswordVersion(appCfg.Name, desiredVersion)
+			modified = true
+		}
+	} else if storedVersion != nil {
+		// Remove version if password was removed
+		p.state.SetPasswordVersion(appCfg.Name, nil)
+		modified = true
+	}
+
+	// Update state with secret env versions
+	if p.updateSecretEnvVersions(appCfg) {
+		modified = true
+	}
+
+	return modified
 }
 
 // planUpdate checks what changes would be needed for an existing application
-func (p *Provisioner) planUpdate(ctx context.Context, existing *api.ReadApplicationDetail, appCfg *config.ApplicationConfig) (*PlannedAction, error) {
+func (p *Provisioner) planUpdate(ctx context.Context, snapshot *ApplicationSnapshot, appCfg *config.ApplicationConfig) *PlannedAction {
+	appID := snapshot.Application.ApplicationID
 	action := &PlannedAction{
 		ApplicationName: appCfg.Name,
 		Action:          ActionNoop,
+		ApplicationID:   &appID,
 	}
 
-	// Get the latest version
-	latestVersion, err := p.getLatestVersion(ctx, existing.ApplicationID)
-	if err != nil {
-		return nil, wrapAPIError(err, "failed to get latest version")
-	}
-
-	if latestVersion == nil {
+	if snapshot.LatestVersion == nil {
 		action.Action = ActionUpdate
-		action.Changes = append(action.Changes, "Create initial version (no versions exist)")
-		return action, nil
+		action.Reason = "Create initial version (no versions exist)"
+		return action
 	}
+	action.LatestVersion = int(snapshot.LatestVersion.Version)
+	action.BaseVersion = int(snapshot.BaseVersion.Version)
 
 	// Compare settings (excluding image)
-	changes := p.compareVersion(appCfg.Name, latestVersion, &appCfg.Spec)
+	changes := p.compareVersion(ctx, appCfg.Name, snapshot.BaseVersion, &appCfg.Spec)
 	if len(changes) > 0 {
 		action.Action = ActionUpdate
 		action.Changes = changes
 	}
 
-	return action, nil
+	return action
 }
 
 // compareVersion compares the current version with desired config and returns list of changes
-func (p *Provisioner) compareVersion(appName string, current *api.ReadApplicationVersionDetail, desired *config.ApplicationSpec) []string {
+func (p *Provisioner) compareVersion(ctx context.Context, appName string, current *api.ReadApplicationVersionDetail, desired *config.ApplicationSpec) []FieldChange {
 	// Use normalized structs for comparison (excluding Image which is inherited)
 	currentNorm := NormalizeFromAPI(current)
 	desiredNorm := NormalizeFromConfig(desired)
@@ -419,7 +812,7 @@ func (p *Provisioner) compareVersion(appName string, current *api.ReadApplicatio
 		SkipImage: desired.InheritImage || desired.Image == "",
 	})
 	if err != nil {
-		log.Printf("Warning: failed to compare specs: %v", err)
+		logf(ctx, "Warning: failed to compare specs: %v", err)
 	}
 
 	changes := specChanges
@@ -434,22 +827,32 @@ func (p *Provisioner) compareVersion(appName string, current *api.ReadApplicatio
 
 	if desiredVersion != nil {
 		if storedVersion == nil {
-			changes = append(changes, fmt.Sprintf("RegistryPasswordVersion: (new) -> %d", *desiredVersion))
+			change := addChange(ResourceApplication, "RegistryPasswordVersion", *desiredVersion)
+			change.Sensitive = true
+			changes = append(changes, change)
 		} else if *storedVersion != *desiredVersion {
-			changes = append(changes, fmt.Sprintf("RegistryPasswordVersion: %d -> %d", *storedVersion, *desiredVersion))
+			change := updateChange(ResourceApplication, "RegistryPasswordVersion", *storedVersion, *desiredVersion)
+			change.Sensitive = true
+			changes = append(changes, change)
 		}
 		// If versions match, no change needed
 	} else if storedVersion != nil {
 		// Password was removed from YAML
-		changes = append(changes, fmt.Sprintf("RegistryPasswordVersion: %d -> (removed)", *storedVersion))
+		changes = append(changes, FieldChange{
+			Resource:  ResourceApplication,
+			Path:      "RegistryPasswordVersion",
+			Kind:      ChangeRemove,
+			Old:       fmt.Sprint(*storedVersion),
+			Sensitive: true,
+		})
 	}
 
 	return changes
 }
 
 // compareEnv compares environment variables and returns list of changes
-func (p *Provisioner) compareEnv(appName string, current []api.ReadEnvironmentVariable, desired []config.EnvVarConfig) []string {
-	var changes []string
+func (p *Provisioner) compareEnv(appName string, current []api.ReadEnvironmentVariable, desired []config.EnvVarConfig) []FieldChange {
+	var changes []FieldChange
 
 	// Build maps for comparison
 	currentByKey := make(map[string]api.ReadEnvironmentVariable)
@@ -464,16 +867,19 @@ func (p *Provisioner) compareEnv(appName string, current []api.ReadEnvironmentVa
 
 	// Check for added and changed env vars
 	for _, desiredEnv := range desired {
+		path := envPathPrefix + desiredEnv.Key
 		currentEnv, exists := currentByKey[desiredEnv.Key]
 		if !exists {
-			// New env var
+			// New env var (secret values are never recorded)
+			change := FieldChange{Resource: ResourceApplication, Path: path, Kind: ChangeAdd, Sensitive: desiredEnv.Secret}
 			if desiredEnv.Secret {
-				changes = append(changes, fmt.Sprintf("Env add: %s (secret)", desiredEnv.Key))
+				if desiredEnv.SecretVersion != nil {
+					change.New = fmt.Sprint(*desiredEnv.SecretVersion)
+				}
 			} else if desiredEnv.Value != nil {
-				changes = append(changes, fmt.Sprintf("Env add: %s=%s", desiredEnv.Key, *desiredEnv.Value))
-			} else {
-				changes = append(changes, fmt.Sprintf("Env add: %s", desiredEnv.Key))
+				change.New = *desiredEnv.Value
 			}
+			changes = append(changes, change)
 			continue
 		}
 
@@ -482,10 +888,18 @@ func (p *Provisioner) compareEnv(appName string, current []api.ReadEnvironmentVa
 			// For secrets, compare using secretVersion in state file
 			storedVersion := p.state.GetSecretEnvVersion(appName, desiredEnv.Key)
 			if desiredEnv.SecretVersion != nil {
-				if storedVersion == nil {
-					changes = append(changes, fmt.Sprintf("Env update: %s (secret, version: new -> %d)", desiredEnv.Key, *desiredEnv.SecretVersion))
-				} else if *storedVersion != *desiredEnv.SecretVersion {
-					changes = append(changes, fmt.Sprintf("Env update: %s (secret, version: %d -> %d)", desiredEnv.Key, *storedVersion, *desiredEnv.SecretVersion))
+				if storedVersion == nil || *storedVersion != *desiredEnv.SecretVersion {
+					change := FieldChange{
+						Resource:  ResourceApplication,
+						Path:      path,
+						Kind:      ChangeUpdate,
+						New:       fmt.Sprint(*desiredEnv.SecretVersion),
+						Sensitive: true,
+					}
+					if storedVersion != nil {
+						change.Old = fmt.Sprint(*storedVersion)
+					}
+					changes = append(changes, change)
 				}
 			}
 		} else {
@@ -499,7 +913,7 @@ func (p *Provisioner) compareEnv(appName string, current []api.ReadEnvironmentVa
 				desiredValue = *desiredEnv.Value
 			}
 			if currentValue != desiredValue {
-				changes = append(changes, fmt.Sprintf("Env update: %s=%s -> %s", desiredEnv.Key, currentValue, desiredValue))
+				changes = append(changes, updateChange(ResourceApplication, path, currentValue, desiredValue))
 			}
 		}
 	}
@@ -507,17 +921,27 @@ func (p *Provisioner) compareEnv(appName string, current []api.ReadEnvironmentVa
 	// Check for removed env vars
 	for _, currentEnv := range current {
 		if _, exists := desiredByKey[currentEnv.Key]; !exists {
-			if currentEnv.Secret {
-				changes = append(changes, fmt.Sprintf("Env remove: %s (secret)", currentEnv.Key))
-			} else {
-				changes = append(changes, fmt.Sprintf("Env remove: %s", currentEnv.Key))
-			}
+			changes = append(changes, removedEnvChange(currentEnv))
 		}
 	}
 
 	return changes
 }
 
+// removedEnvChange returns the change for an env var that no longer exists
+func removedEnvChange(env api.ReadEnvironmentVariable) FieldChange {
+	change := FieldChange{
+		Resource:  ResourceApplication,
+		Path:      envPathPrefix + env.Key,
+		Kind:      ChangeRemove,
+		Sensitive: env.Secret,
+	}
+	if !env.Secret && !env.Value.IsNull() {
+		change.Old = env.Value.Value
+	}
+	return change
+}
+
 // updateSecretEnvVersions updates the state with secret env versions from config
 func (p *Provisioner) updateSecretEnvVersions(appCfg *config.ApplicationConfig) bool {
 	modified := false
@@ -591,28 +1015,32 @@ func (p *Provisioner) listAllApplications(ctx context.Context, clusterID uuid.UU
 	return apps, nil
 }
 
+// getLatestVersionNumber returns the highest version number of the application (0 if no versions exist)
+func (p *Provisioner) getLatestVersionNumber(ctx context.Context, appID api.ApplicationID) (api.ApplicationVersionNumber, error) {
+	var latestVersionNum api.ApplicationVersionNumber
+	for v, err := range p.versions(ctx, appID) {
+		if err != nil {
+			return 0, err
+		}
+		if v.Version > latestVersionNum {
+			latestVersionNum = v.Version
+		}
+	}
+
+	return latestVersionNum, nil
+}
+
 // getLatestVersion returns the latest version of the application
 func (p *Provisioner) getLatestVersion(ctx context.Context, appID api.ApplicationID) (*api.ReadApplicationVersionDetail, error) {
-	resp, err := p.client.ListApplicationVersions(ctx, api.ListApplicationVersionsParams{
-		ApplicationID: appID,
-		MaxItems:      30,
-	})
+	latestVersionNum, err := p.getLatestVersionNumber(ctx, appID)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(resp.Versions) == 0 {
+	if latestVersionNum == 0 {
 		return nil, nil
 	}
 
-	// Find the latest version (highest version number)
-	var latestVersionNum api.ApplicationVersionNumber
-	for _, v := range resp.Versions {
-		if v.Version > latestVersionNum {
-			latestVersionNum = v.Version
-		}
-	}
-
 	// Get the full details of the latest version
 	versionResp, err := p.client.GetApplicationVersion(ctx, api.GetApplicationVersionParams{
 		ApplicationID: appID,
@@ -625,9 +1053,31 @@ func (p *Provisioner) getLatestVersion(ctx context.Context, appID api.Applicatio
 	return &versionResp.ApplicationVersion, nil
 }
 
-// createApplication creates a new application with the given configuration
-func (p *Provisioner) createApplication(ctx context.Context, clusterID uuid.UUID, appCfg *config.ApplicationConfig, opts ApplyOptions) error {
-	log.Printf("Creating application %q", appCfg.Name)
+// getBaseVersion returns the version to compare with and inherit settings from.
+// With base "active" it is the active version, falling back to latest when no version is active.
+func (p *Provisioner) getBaseVersion(ctx context.Context, existing *api.ReadApplicationDetail, latest *api.ReadApplicationVersionDetail, base string) (*api.ReadApplicationVersionDetail, error) {
+	if base != config.BaseActive {
+		return latest, nil
+	}
+	activeNum, ok := existing.ActiveVersion.Get()
+	if !ok || latest == nil || int(activeNum) == int(latest.Version) {
+		return latest, nil
+	}
+
+	resp, err := p.client.GetApplicationVersion(ctx, api.GetApplicationVersionParams{
+		ApplicationID: existing.ApplicationID,
+		Version:       api.ApplicationVersionNumber(activeNum),
+	})
+	if err != nil {
+		return nil, wrapAPIError(err, fmt.Sprintf("failed to get active version %d", activeNum))
+	}
+	return &resp.ApplicationVersion, nil
+}
+
+// createApplication creates a new application with the given configuration.
+// It returns the IDs of the created application and version; activation is left to the caller.
+func (p *Provisioner) createApplication(ctx context.Context, clusterID uuid.UUID, appCfg *config.ApplicationConfig) (api.ApplicationID, api.ApplicationVersionNumber, error) {
+	logf(ctx, "Creating application %q", appCfg.Name)
 
 	// Create the application
 	createResp, err := p.client.CreateApplication(ctx, &api.CreateApplication{
@@ -635,11 +1085,12 @@ func (p *Provisioner) createApplication(ctx context.Context, clusterID uuid.UUID
 		ClusterID: api.ClusterID(clusterID),
 	})
 	if err != nil {
-		return wrapAPIError(err, "failed to create application")
+		return api.ApplicationID{}, 0, wrapAPIError(err, "failed to create application")
 	}
 
 	appID := createResp.Application.ApplicationID
-	log.Printf("Created application %q with ID %s", appCfg.Name, uuid.UUID(appID))
+	recordResourceID(ctx, uuid.UUID(appID).String())
+	logf(ctx, "Created application %q with ID %s", appCfg.Name, uuid.UUID(appID))
 
 	// Create the version (using image from config for new applications)
 	versionReq := p.buildCreateVersionRequest(&appCfg.Spec)
@@ -647,67 +1098,80 @@ func (p *Provisioner) createApplication(ctx context.Context, clusterID uuid.UUID
 		ApplicationID: appID,
 	})
 	if err != nil {
-		return wrapAPIError(err, "failed to create version")
+		return appID, 0, wrapAPIError(err, "failed to create version")
 	}
 
 	versionNum := versionResp.ApplicationVersion.Version
-	log.Printf("Created version %d for application %q", versionNum, appCfg.Name)
-
-	// Activate the version only if requested
-	if opts.Activate {
-		updateReq := &api.UpdateApplication{}
-		updateReq.ActiveVersion.SetTo(int32(versionNum))
-		err = p.client.UpdateApplication(ctx, updateReq, api.UpdateApplicationParams{
-			ApplicationID: appID,
-		})
-		if err != nil {
-			return wrapAPIError(err, "failed to activate version")
-		}
+	recordVersion(ctx, int(versionNum))
+	logf(ctx, "Created version %d for application %q", versionNum, appCfg.Name)
 
-		log.Printf("Activated version %d for application %q", versionNum, appCfg.Name)
-	} else {
-		log.Printf("Skipped activation for application %q (use --activate to activate)", appCfg.Name)
-	}
-	return nil
+	return appID, versionNum, nil
 }
 
-// updateApplication creates a new version and optionally activates it
-func (p *Provisioner) updateApplication(ctx context.Context, existing *api.ReadApplicationDetail, appCfg *config.ApplicationConfig, opts ApplyOptions) error {
-	log.Printf("Updating application %q", appCfg.Name)
+// updateApplication creates a new version and returns its number; activation is left to the caller.
+// Settings are inherited from the base version in the snapshot, after checking that the application has not changed since.
+func (p *Provisioner) updateApplication(ctx context.Context, snapshot *ApplicationSnapshot, appCfg *config.ApplicationConfig, base string) (api.ApplicationVersionNumber, error) {
+	logf(ctx, "Updating application %q", appCfg.Name)
+	appID := snapshot.Application.ApplicationID
+	recordResourceID(ctx, uuid.UUID(appID).String())
 
-	// Get the latest version to inherit settings
-	latestVersion, err := p.getLatestVersion(ctx, existing.ApplicationID)
-	if err != nil {
-		return wrapAPIError(err, "failed to get latest version")
+	if err := p.checkApplicationSnapshot(ctx, snapshot, base); err != nil {
+		return 0, err
 	}
 
 	// Create the new version (merge with existing settings)
-	versionReq := p.buildCreateVersionRequestWithBase(&appCfg.Spec, latestVersion)
+	versionReq := p.buildCreateVersionRequestWithBase(&appCfg.Spec, snapshot.BaseVersion)
 	versionResp, err := p.client.CreateApplicationVersion(ctx, versionReq, api.CreateApplicationVersionParams{
-		ApplicationID: existing.ApplicationID,
+		ApplicationID: appID,
 	})
 	if err != nil {
-		return wrapAPIError(err, "failed to create version")
+		return 0, wrapAPIError(err, "failed to create version")
 	}
 
 	versionNum := versionResp.ApplicationVersion.Version
-	log.Printf("Created version %d for application %q", versionNum, appCfg.Name)
-
-	// Activate the version only if requested
-	if opts.Activate {
-		updateReq := &api.UpdateApplication{}
-		updateReq.ActiveVersion.SetTo(int32(versionNum))
-		err = p.client.UpdateApplication(ctx, updateReq, api.UpdateApplicationParams{
-			ApplicationID: existing.ApplicationID,
-		})
-		if err != nil {
-			return wrapAPIError(err, "failed to activate version")
-		}
+	recordVersion(ctx, int(versionNum))
+	logf(ctx, "Created version %d for application %q", versionNum, appCfg.Name)
 
-		log.Printf("Activated version %d for application %q", versionNum, appCfg.Name)
-	} else {
-		log.Printf("Skipped activation for application %q (use --activate to activate)", appCfg.Name)
+	return versionNum, nil
+}
+
+// resumeApplication continues an application step interrupted in a previous apply and returns the version to activate.
+// If a version newer than plannedVersion exists, it was created by the previous apply and is reused;
+// otherwise a new version is created as in updateApplication.
+func (p *Provisioner) resumeApplication(ctx context.Context, snapshot *ApplicationSnapshot, appCfg *config.ApplicationConfig, base string, plannedVersion int) (api.ApplicationVersionNumber, error) {
+	appID := snapshot.Application.ApplicationID
+	latest, err := p.getLatestVersionNumber(ctx, appID)
+	if err != nil {
+		return 0, wrapAPIError(err, "failed to get latest version")
 	}
+	if int(latest) <= plannedVersion {
+		return p.updateApplication(ctx, snapshot, appCfg, base)
+	}
+
+	recordResourceID(ctx, uuid.UUID(appID).String())
+	recordVersion(ctx, int(latest))
+	logf(ctx, "Version %d for application %q was created by the previous apply", latest, appCfg.Name)
+
+	return latest, nil
+}
+
+// activateIfRequested activates the version if opts.Activate is set
+func (p *Provisioner) activateIfRequested(ctx context.Context, appID api.ApplicationID, appName string, versionNum api.ApplicationVersionNumber, opts ApplyOptions) error {
+	if !opts.Activate {
+		logf(ctx, "Skipped activation for application %q (use --activate to activate)", appName)
+		return nil
+	}
+
+	updateReq := &api.UpdateApplication{}
+	updateReq.ActiveVersion.SetTo(int32(versionNum))
+	err := p.client.UpdateApplication(ctx, updateReq, api.UpdateApplicationParams{
+		ApplicationID: appID,
+	})
+	if err != nil {
+		return wrapAPIError(err, "failed to activate version")
+	}
+
+	logf(ctx, "Activated version %d for application %q", versionNum, appName)
 	return nil
 }
 
@@ -914,29 +1378,6 @@ func (p *Provisioner) ListVersions(ctx context.Context, clusterName, appName str
 		activeVersion = int(v)
 	}
 
-	// List all versions
-	var allVersions []api.ApplicationVersionDeploymentStatus
-	var cursor api.OptApplicationVersionNumber
-
-	for {
-		resp, err := p.client.ListApplicationVersions(ctx, api.ListApplicationVersionsParams{
-			ApplicationID: app.ApplicationID,
-			MaxItems:      30,
-			Cursor:        cursor,
-		})
-		if err != nil {
-			return nil, wrapAPIError(err, "failed to list versions")
-		}
-
-		allVersions = append(allVersions, resp.Versions...)
-
-		if resp.NextCursor.Set {
-			cursor = resp.NextCursor
-		} else {
-			break
-		}
-	}
-
 	// Build result
 	result := &VersionList{
 		ApplicationName: appName,
@@ -945,7 +1386,10 @@ func (p *Provisioner) ListVersions(ctx context.Context, clusterName, appName str
 	}
 
 	latestVersion := 0
-	for _, v := range allVersions {
+	for v, err := range p.versions(ctx, app.ApplicationID) {
+		if err != nil {
+			return nil, wrapAPIError(err, "failed to list versions")
+		}
 		versionNum := int(v.Version)
 		if versionNum > latestVersion {
 			latestVersion = versionNum
@@ -964,6 +1408,50 @@ func (p *Provisioner) ListVersions(ctx context.Context, clusterName, appName str
 	return result, nil
 }
 
+// listAllVersions returns the deployment status of all versions of the application
+func (p *Provisioner) listAllVersions(ctx context.Context, appID api.ApplicationID) ([]api.ApplicationVersionDeploymentStatus, error) {
+	var allVersions []api.ApplicationVersionDeploymentStatus
+	for v, err := range p.versions(ctx, appID) {
+		if err != nil {
+			return nil, wrapAPIError(err, "failed to list versions")
+		}
+		allVersions = append(allVersions, v)
+	}
+
+	return allVersions, nil
+}
+
+// versions iterates over the deployment status of all versions of the application, following the pagination cursor.
+// If a page cannot be listed, the error is yielded and the iteration stops.
+func (p *Provisioner) versions(ctx context.Context, appID api.ApplicationID) iter.Seq2[api.ApplicationVersionDeploymentStatus, error] {
+	return func(yield func(api.ApplicationVersionDeploymentStatus, error) bool) {
+		var cursor api.OptApplicationVersionNumber
+
+		for {
+			resp, err := p.client.ListApplicationVersions(ctx, api.ListApplicationVersionsParams{
+				ApplicationID: appID,
+				MaxItems:      30,
+				Cursor:        cursor,
+			})
+			if err != nil {
+				yield(api.ApplicationVersionDeploymentStatus{}, err)
+				return
+			}
+
+			for _, v := range resp.Versions {
+				if !yield(v, nil) {
+					return
+				}
+			}
+
+			if !resp.NextCursor.Set {
+				return
+			}
+			cursor = resp.NextCursor
+		}
+	}
+}
+
 // GetVersionDiff compares two versions and returns differences
 func (p *Provisioner) GetVersionDiff(ctx context.Context, clusterName, appName string, fromVersion, toVersion int) (*VersionDiff, error) {
 	// Resolve cluster name to ID
@@ -1050,8 +1538,8 @@ func (p *Provisioner) GetVersionDiff(ctx context.Context, clusterName, appName s
 }
 
 // compareVersionEnv compares environment variables between two versions
-func (p *Provisioner) compareVersionEnv(from, to []api.ReadEnvironmentVariable) ([]string, bool) {
-	var changes []string
+func (p *Provisioner) compareVersionEnv(from, to []api.ReadEnvironmentVariable) ([]FieldChange, bool) {
+	var changes []FieldChange
 	hasSecrets := false
 
 	// Build maps for comparison
@@ -1076,13 +1564,16 @@ func (p *Provisioner) compareVersionEnv(from, to []api.ReadEnvironmentVariable)
 		fromEnv, exists := fromByKey[toEnv.Key]
 		if !exists {
 			// New env var
-			if toEnv.Secret {
-				changes = append(changes, fmt.Sprintf("Env add: %s (secret)", toEnv.Key))
-			} else if !toEnv.Value.IsNull() {
-				changes = append(changes, fmt.Sprintf("Env add: %s=%s", toEnv.Key, toEnv.Value.Value))
-			} else {
-				changes = append(changes, fmt.Sprintf("Env add: %s", toEnv.Key))
+			change := FieldChange{
+				Resource:  ResourceApplication,
+				Path:      envPathPrefix + toEnv.Key,
+				Kind:      ChangeAdd,
+				Sensitive: toEnv.Secret,
+			}
+			if !toEnv.Secret && !toEnv.Value.IsNull() {
+				change.New = toEnv.Value.Value
 			}
+			changes = append(changes, change)
 			continue
 		}
 
@@ -1102,18 +1593,14 @@ func (p *Provisioner) compareVersionEnv(from, to []api.ReadEnvironmentVariable)
 			toValue = toEnv.Value.Value
 		}
 		if fromValue != toValue {
-			changes = append(changes, fmt.Sprintf("Env update: %s=%s -> %s", toEnv.Key, fromValue, toValue))
+			changes = append(changes, updateChange(ResourceApplication, envPathPrefix+toEnv.Key, fromValue, toValue))
 		}
 	}
 
 	// Check for removed env vars
 	for _, fromEnv := range from {
 		if _, exists := toByKey[fromEnv.Key]; !exists {
-			if fromEnv.Secret {
-				changes = append(changes, fmt.Sprintf("Env remove: %s (secret)", fromEnv.Key))
-			} else {
-				changes = append(changes, fmt.Sprintf("Env remove: %s", fromEnv.Key))
-			}
+			changes = append(changes, removedEnvChange(fromEnv))
 		}
 	}
 
@@ -82,6 +82,118 @@ func createTestVersion(mockServer *testutil.MockServer, appID api.ApplicationID,
 	})
 }
 
+// testClusterConfig returns a config of existing-app, which matches the versions created by createTestVersion
+// except for the CPU
+func testClusterConfig(cpu int64) *config.ClusterConfig {
+	return &config.ClusterConfig{
+		ClusterName: "my-cluster",
+		Applications: []config.ApplicationConfig{
+			{
+				Name: "existing-app",
+				Spec: config.ApplicationSpec{
+					CPU:         cpu,
+					Memory:      1024,
+					ScalingMode: "manual",
+					FixedScale:  int32Ptr(2),
+					ExposedPorts: []config.ExposedPortConfig{
+						{TargetPort: 80, LoadBalancerPort: int32Ptr(443), UseLetsEncrypt: true},
+					},
+				},
+			},
+		},
+	}
+}
+
+// setupTestCluster creates my-cluster with the named applications, each with version 1 matching testClusterConfig(500)
+func setupTestCluster(t *testing.T, configPath string, apps ...string) (*testutil.MockServer, *Provisioner, api.ClusterID, func()) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	for _, name := range apps {
+		appID := createTestApplication(mockServer, clusterID, name)
+		createTestVersion(mockServer, appID, 1, 500, 1024)
+	}
+	return mockServer, NewProvisioner(client, state.NewState(), configPath), clusterID, cleanup
+}
+
+// testAppID returns the ID of the named application
+func testAppID(mockServer *testutil.MockServer, clusterID api.ClusterID, name string) api.ApplicationID {
+	app, _ := mockServer.GetApplicationByName(clusterID, name)
+	return app.ApplicationID
+}
+
+// createTestASG creates an ASG that matches testASGConfig
+func createTestASG(mockServer *testutil.MockServer, clusterID api.ClusterID, name string) api.AutoScalingGroupID {
+	asgID := api.AutoScalingGroupID(uuid.New())
+	mockServer.AddAutoScalingGroup(clusterID, api.ReadAutoScalingGroupDetail{
+		AutoScalingGroupID:     asgID,
+		Name:                   name,
+		Zone:                   "is1a",
+		NameServers:            []api.IPv4{"8.8.8.8"},
+		WorkerServiceClassPath: "cloud/plan/worker",
+		MinNodes:               1,
+		MaxNodes:               2,
+		Interfaces: []api.AutoScalingGroupNodeInterface{
+			{InterfaceIndex: 0, Upstream: "shared", ConnectsToLB: true},
+		},
+	})
+	return asgID
+}
+
+// testASGConfig returns the config of an ASG created by createTestASG
+func testASGConfig(name string) config.AutoScalingGroupConfig {
+	return config.AutoScalingGroupConfig{
+		Name:                   name,
+		Zone:                   "is1a",
+		WorkerServiceClassPath: "cloud/plan/worker",
+		MinNodes:               1,
+		MaxNodes:               2,
+		NameServers:            []string{"8.8.8.8"},
+		Interfaces:             []config.ASGInterfaceConfig{{InterfaceIndex: 0, Upstream: "shared", ConnectsToLB: true}},
+	}
+}
+
+// createTestLB creates an LB that matches testLBConfig
+func createTestLB(mockServer *testutil.MockServer, asgID api.AutoScalingGroupID, name string) api.LoadBalancerID {
+	lbID := api.LoadBalancerID(uuid.New())
+	mockServer.AddLoadBalancer(asgID, api.ReadLoadBalancerDetail{
+		LoadBalancerID:   lbID,
+		Name:             name,
+		ServiceClassPath: "cloud/plan/lb",
+		NameServers:      []api.IPv4{"8.8.8.8"},
+		Interfaces: []api.LoadBalancerInterface{
+			{InterfaceIndex: 0, Upstream: "shared"},
+		},
+	})
+	return lbID
+}
+
+// testLBConfig returns the config of an LB created by createTestLB
+func testLBConfig(asgName, name string) config.LoadBalancerConfig {
+	return config.LoadBalancerConfig{
+		Name:                 name,
+		AutoScalingGroupName: asgName,
+		ServiceClassPath:     "cloud/plan/lb",
+		NameServers:          []string{"8.8.8.8"},
+		Interfaces:           []config.LBInterfaceConfig{{InterfaceIndex: 0, Upstream: "shared"}},
+	}
+}
+
+// setupInfraCluster creates my-cluster with existing-app, ASG web-asg and LB web-lb, as in infraTestConfig
+func setupInfraCluster(t *testing.T, configPath string) (*testutil.MockServer, *Provisioner, api.AutoScalingGroupID, func()) {
+	mockServer, provisioner, clusterID, cleanup := setupTestCluster(t, configPath, "existing-app")
+	asgID := createTestASG(mockServer, clusterID, "web-asg")
+	createTestLB(mockServer, asgID, "web-lb")
+	return mockServer, provisioner, asgID, cleanup
+}
+
+// infraTestConfig returns a config that matches the cluster created by setupInfraCluster
+func infraTestConfig() *config.ClusterConfig {
+	cfg := testClusterConfig(500)
+	cfg.AutoScalingGroups = []config.AutoScalingGroupConfig{testASGConfig("web-asg")}
+	cfg.LoadBalancers = []config.LoadBalancerConfig{testLBConfig("web-asg", "web-lb")}
+	return cfg
+}
+
 // =============================================================================
 // CreatePlan Tests - Cluster Resolution
 // =============================================================================
@@ -99,7 +211,7 @@ func TestCreatePlan_ClusterNotFound(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 
 	assert.Nil(t, plan)
 	require.Error(t, err)
@@ -118,7 +230,7 @@ func TestCreatePlan_ClusterFound(t *testing.T) {
 		Applications: []config.ApplicationConfig{},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 
 	require.NoError(t, err)
 	assert.Equal(t, "my-cluster", plan.ClusterName)
@@ -152,13 +264,13 @@ func TestCreatePlan_NewApplication(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 1)
 	assert.Equal(t, "new-app", plan.Actions[0].ApplicationName)
 	assert.Equal(t, ActionCreate, plan.Actions[0].Action)
-	assert.Contains(t, plan.Actions[0].Changes, "Create new application and version")
+	assert.Equal(t, "Create new application and version", plan.Actions[0].Reason)
 }
 
 // =============================================================================
@@ -189,13 +301,13 @@ func TestCreatePlan_ExistingApplication_NoVersion(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 1)
 	assert.Equal(t, "existing-app", plan.Actions[0].ApplicationName)
 	assert.Equal(t, ActionUpdate, plan.Actions[0].Action)
-	assert.Contains(t, plan.Actions[0].Changes, "Create initial version (no versions exist)")
+	assert.Equal(t, "Create initial version (no versions exist)", plan.Actions[0].Reason)
 }
 
 func TestCreatePlan_ExistingApplication_NoChanges(t *testing.T) {
@@ -225,7 +337,7 @@ func TestCreatePlan_ExistingApplication_NoChanges(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 1)
@@ -261,12 +373,12 @@ func TestCreatePlan_ExistingApplication_CPUChanged(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 1)
 	assert.Equal(t, ActionUpdate, plan.Actions[0].Action)
-	assert.Contains(t, plan.Actions[0].Changes, "CPU: 500 -> 1000")
+	assert.Contains(t, FormatChanges(plan.Actions[0].Changes), "CPU: 500 -> 1000")
 }
 
 func TestCreatePlan_ExistingApplication_MemoryChanged(t *testing.T) {
@@ -296,12 +408,12 @@ func TestCreatePlan_ExistingApplication_MemoryChanged(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 1)
 	assert.Equal(t, ActionUpdate, plan.Actions[0].Action)
-	assert.Contains(t, plan.Actions[0].Changes, "Memory: 1024 -> 2048")
+	assert.Contains(t, FormatChanges(plan.Actions[0].Changes), "Memory: 1024 -> 2048")
 }
 
 func TestCreatePlan_ExistingApplication_ScalingModeChanged(t *testing.T) {
@@ -332,12 +444,12 @@ func TestCreatePlan_ExistingApplication_ScalingModeChanged(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 1)
 	assert.Equal(t, ActionUpdate, plan.Actions[0].Action)
-	assert.Contains(t, plan.Actions[0].Changes, "ScalingMode: manual -> cpu")
+	assert.Contains(t, FormatChanges(plan.Actions[0].Changes), "ScalingMode: manual -> cpu")
 }
 
 func TestCreatePlan_ExistingApplication_FixedScaleChanged(t *testing.T) {
@@ -367,12 +479,12 @@ func TestCreatePlan_ExistingApplication_FixedScaleChanged(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 1)
 	assert.Equal(t, ActionUpdate, plan.Actions[0].Action)
-	assert.Contains(t, plan.Actions[0].Changes, "FixedScale: 2 -> 5")
+	assert.Contains(t, FormatChanges(plan.Actions[0].Changes), "FixedScale: 2 -> 5")
 }
 
 func TestCreatePlan_ExistingApplication_ExposedPortsCountChanged(t *testing.T) {
@@ -403,13 +515,13 @@ func TestCreatePlan_ExistingApplication_ExposedPortsCountChanged(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 1)
 	assert.Equal(t, ActionUpdate, plan.Actions[0].Action)
 	// New port addition is detected as individual field changes
-	assert.Contains(t, plan.Actions[0].Changes, "ExposedPorts.8080.TargetPort: (unset) -> 8080")
+	assert.Contains(t, FormatChanges(plan.Actions[0].Changes), "ExposedPorts.8080.TargetPort: (unset) -> 8080")
 }
 
 func TestCreatePlan_ExistingApplication_EnvCountChanged(t *testing.T) {
@@ -442,12 +554,12 @@ func TestCreatePlan_ExistingApplication_EnvCountChanged(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 1)
 	assert.Equal(t, ActionUpdate, plan.Actions[0].Action)
-	assert.Contains(t, plan.Actions[0].Changes, "Env add: APP_ENV=production")
+	assert.Contains(t, FormatChanges(plan.Actions[0].Changes), "Env add: APP_ENV=production")
 }
 
 func TestCreatePlan_ExistingApplication_MultipleChanges(t *testing.T) {
@@ -477,14 +589,14 @@ func TestCreatePlan_ExistingApplication_MultipleChanges(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 1)
 	assert.Equal(t, ActionUpdate, plan.Actions[0].Action)
 	assert.Len(t, plan.Actions[0].Changes, 2)
-	assert.Contains(t, plan.Actions[0].Changes, "CPU: 500 -> 1000")
-	assert.Contains(t, plan.Actions[0].Changes, "Memory: 1024 -> 2048")
+	assert.Contains(t, FormatChanges(plan.Actions[0].Changes), "CPU: 500 -> 1000")
+	assert.Contains(t, FormatChanges(plan.Actions[0].Changes), "Memory: 1024 -> 2048")
 }
 
 func TestCreatePlan_MultipleApplications(t *testing.T) {
@@ -525,7 +637,7 @@ func TestCreatePlan_MultipleApplications(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 2)
@@ -571,7 +683,7 @@ func TestApply_CreateApplication(t *testing.T) {
 	}
 
 	// Create plan first
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 1)
 	assert.Equal(t, ActionCreate, plan.Actions[0].Action)
@@ -618,7 +730,7 @@ func TestApply_UpdateApplication(t *testing.T) {
 	}
 
 	// Create plan
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 1)
 	assert.Equal(t, ActionUpdate, plan.Actions[0].Action)
@@ -670,7 +782,7 @@ func TestApply_NoopApplication(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 1)
 	assert.Equal(t, ActionNoop, plan.Actions[0].Action)
@@ -738,7 +850,7 @@ func TestApply_MultipleApplications_MixedActions(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 3)
 
@@ -807,7 +919,7 @@ func TestApply_ImageInheritedFromExistingVersion(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 	require.NoError(t, err)
 
 	err = provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{Activate: true})
@@ -846,7 +958,7 @@ func TestApply_NewApplication_UsesConfigImage(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 	require.NoError(t, err)
 
 	err = provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{Activate: true})
@@ -892,7 +1004,7 @@ func TestApply_CreateApplication_WithoutActivation(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 	require.NoError(t, err)
 
 	// Apply without activation
@@ -937,7 +1049,7 @@ func TestApply_UpdateApplication_WithoutActivation(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 	require.NoError(t, err)
 	assert.Equal(t, ActionUpdate, plan.Actions[0].Action)
 
@@ -1010,7 +1122,7 @@ func TestApply_InheritImage_False_UpdatesImage(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 1)
 	assert.Equal(t, ActionUpdate, plan.Actions[0].Action)
@@ -1070,7 +1182,7 @@ func TestApply_InheritImage_True_InheritsImage(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 	require.NoError(t, err)
 
 	err = provisioner.Apply(context.Background(), cfg, plan, ApplyOptions{Activate: true})
@@ -1129,12 +1241,12 @@ func TestCreatePlan_InheritImage_False_ReportsImageChange(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 1)
 	assert.Equal(t, ActionUpdate, plan.Actions[0].Action)
 	// Image change should be reported when InheritImage is false (default)
-	assert.Contains(t, plan.Actions[0].Changes, "Image: nginx:1.0.0 -> nginx:2.0.0")
+	assert.Contains(t, FormatChanges(plan.Actions[0].Changes), "Image: nginx:1.0.0 -> nginx:2.0.0")
 }
 
 func TestCreatePlan_InheritImage_False_NoChangeWhenSameImage(t *testing.T) {
@@ -1183,9 +1295,130 @@ func TestCreatePlan_InheritImage_False_NoChangeWhenSameImage(t *testing.T) {
 		},
 	}
 
-	plan, err := provisioner.CreatePlan(context.Background(), cfg)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
 	require.NoError(t, err)
 	require.Len(t, plan.Actions, 1)
 	assert.Equal(t, ActionNoop, plan.Actions[0].Action) // No changes
 	assert.Empty(t, plan.Actions[0].Changes)
 }
+
+// =============================================================================
+// Plan Tests
+// =============================================================================
+
+func TestPlan_HasDestructiveChanges(t *testing.T) {
+	tests := []struct {
+		name            string
+		plan            Plan
+		wantChanges     bool
+		wantDestructive bool
+	}{
+		{
+			name: "no changes",
+			plan: Plan{
+				ASGActions: []ASGAction{{Name: "web-asg", Action: ASGActionNoop}},
+				LBActions:  []LBAction{{Name: "old-lb", Action: LBActionSkip}},
+				Actions:    []PlannedAction{{ApplicationName: "app", Action: ActionNoop}},
+			},
+		},
+		{
+			name: "application update",
+			plan: Plan{
+				Actions: []PlannedAction{{ApplicationName: "app", Action: ActionUpdate}},
+			},
+			wantChanges: true,
+		},
+		{
+			name: "ASG create",
+			plan: Plan{
+				ASGActions: []ASGAction{{Name: "web-asg", Action: ASGActionCreate}},
+			},
+			wantChanges: true,
+		},
+		{
+			name: "ASG recreate",
+			plan: Plan{
+				ASGActions: []ASGAction{{Name: "web-asg", Action: ASGActionRecreate}},
+			},
+			wantChanges:     true,
+			wantDestructive: true,
+		},
+		{
+			name: "LB delete",
+			plan: Plan{
+				LBActions: []LBAction{{Name: "old-lb", Action: LBActionDelete}},
+			},
+			wantChanges:     true,
+			wantDestructive: true,
+		},
+		{
+			name: "application delete",
+			plan: Plan{
+				Actions: []PlannedAction{{ApplicationName: "orphan-app", Action: ActionDelete}},
+			},
+			wantChanges:     true,
+			wantDestructive: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantChanges, tt.plan.HasChanges())
+			assert.Equal(t, tt.wantDestructive, tt.plan.HasDestructiveChanges())
+		})
+	}
+}
+
+// =============================================================================
+// Version Pagination Tests
+// =============================================================================
+
+// setupManyVersions creates existing-app with 35 versions, more than a single page of versions.
+// The latest version 35 has CPU 1000, the others 500.
+func setupManyVersions(t *testing.T) (*Provisioner, api.ApplicationID, func()) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	appID := createTestApplication(mockServer, clusterID, "existing-app")
+	for v := api.ApplicationVersionNumber(1); v < 35; v++ {
+		createTestVersion(mockServer, appID, v, 500, 1024)
+	}
+	createTestVersion(mockServer, appID, 35, 1000, 1024)
+	return NewProvisioner(client, state.NewState(), ""), appID, cleanup
+}
+
+func TestGetLatestVersion_FollowsPagination(t *testing.T) {
+	provisioner, appID, cleanup := setupManyVersions(t)
+	defer cleanup()
+
+	latest, err := provisioner.getLatestVersion(context.Background(), appID)
+	require.NoError(t, err)
+	require.NotNil(t, latest)
+	assert.Equal(t, api.ApplicationVersionNumber(35), latest.Version)
+
+	// The plan compares the config with the real latest version
+	plan, err := provisioner.CreatePlan(context.Background(), testClusterConfig(1000), PlanOptions{})
+	require.NoError(t, err)
+	require.Len(t, plan.Actions, 1)
+	assert.Equal(t, ActionNoop, plan.Actions[0].Action)
+	assert.Equal(t, 35, plan.Actions[0].LatestVersion)
+}
+
+func TestListVersions_FollowsPagination(t *testing.T) {
+	provisioner, _, cleanup := setupManyVersions(t)
+	defer cleanup()
+
+	list, err := provisioner.ListVersions(context.Background(), "my-cluster", "existing-app")
+	require.NoError(t, err)
+	assert.Len(t, list.Versions, 35)
+	assert.Equal(t, 35, list.LatestVersion)
+}
+
+func TestDumpClusterConfig_FollowsPagination(t *testing.T) {
+	provisioner, _, cleanup := setupManyVersions(t)
+	defer cleanup()
+
+	cfg, err := provisioner.DumpClusterConfig(context.Background(), "my-cluster")
+	require.NoError(t, err)
+	require.Len(t, cfg.Applications, 1)
+	assert.Equal(t, int64(1000), cfg.Applications[0].Spec.CPU)
+}
@@ -0,0 +1,171 @@
+package provisioner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+)
+
+// TargetKind represents the kind of resource selected by a Target
+type TargetKind string
+
+const (
+	TargetApplication      TargetKind = "app"
+	TargetAutoScalingGroup TargetKind = "asg"
+	TargetLoadBalancer     TargetKind = "lb"
+)
+
+// Target selects a single resource to plan/apply (e.g., "app:webapp", "asg:web-asg", "lb:web-asg/web-lb")
+type Target struct {
+	Kind TargetKind
+	Name string
+	// ASGName is the parent ASG name (only for TargetLoadBalancer)
+	ASGName string
+}
+
+// ParseTarget parses a target selector
+func ParseTarget(s string) (Target, error) {
+	kind, name, ok := strings.Cut(s, ":")
+	if !ok || name == "" {
+		return Target{}, fmt.Errorf("invalid target %q: expected app:<name>, asg:<name> or lb:<asg>/<name>", s)
+	}
+
+	switch TargetKind(kind) {
+	case TargetApplication, TargetAutoScalingGroup:
+		return Target{Kind: TargetKind(kind), Name: name}, nil
+	case TargetLoadBalancer:
+		asgName, lbName, ok := strings.Cut(name, "/")
+		if !ok || asgName == "" || lbName == "" {
+			return Target{}, fmt.Errorf("invalid target %q: load balancer targets must be lb:<asg>/<name>", s)
+		}
+		return Target{Kind: TargetLoadBalancer, Name: lbName, ASGName: asgName}, nil
+	default:
+		return Target{}, fmt.Errorf("invalid target %q: unknown kind %q (expected app, asg or lb)", s, kind)
+	}
+}
+
+// String returns the selector form of the target
+func (t Target) String() string {
+	if t.Kind == TargetLoadBalancer {
+		return fmt.Sprintf("%s:%s/%s", t.Kind, t.ASGName, t.Name)
+	}
+	return fmt.Sprintf("%s:%s", t.Kind, t.Name)
+}
+
+// targetSet is the set of resources selected by targets
+type targetSet struct {
+	apps map[string]bool
+	asgs map[string]bool
+	lbs  map[string]map[string]bool // asgName -> lbName
+}
+
+// newTargetSet builds a targetSet, checking that every target exists in the config
+func newTargetSet(cfg *config.ClusterConfig, targets []Target) (*targetSet, error) {
+	set := &targetSet{
+		apps: make(map[string]bool),
+		asgs: make(map[string]bool),
+		lbs:  make(map[string]map[string]bool),
+	}
+
+	for _, target := range targets {
+		switch target.Kind {
+		case TargetApplication:
+			if !hasApplication(cfg, target.Name) {
+				return nil, fmt.Errorf("target %s not found in config", target)
+			}
+			set.apps[target.Name] = true
+		case TargetAutoScalingGroup:
+			if !hasASG(cfg, target.Name) {
+				return nil, fmt.Errorf("target %s not found in config", target)
+			}
+			set.asgs[target.Name] = true
+		case TargetLoadBalancer:
+			if !hasLB(cfg, target.ASGName, target.Name) {
+				return nil, fmt.Errorf("target %s not found in config", target)
+			}
+			set.addLB(target.ASGName, target.Name)
+		default:
+			return nil, fmt.Errorf("unknown target kind %q", target.Kind)
+		}
+	}
+
+	return set, nil
+}
+
+func (s *targetSet) addLB(asgName, lbName string) {
+	if s.lbs[asgName] == nil {
+		s.lbs[asgName] = make(map[string]bool)
+	}
+	s.lbs[asgName][lbName] = true
+}
+
+// filterInfraActions keeps only the ASG/LB actions of the targeted resources and their dependencies.
+// A targeted LB pulls in its parent ASG when the ASG is created or recreated, and an ASG being
+// created or recreated pulls in its LBs, because recreating an ASG also deletes its LBs.
+func (s *targetSet) filterInfraActions(asgActions []ASGAction, lbActions []LBAction) ([]ASGAction, []LBAction) {
+	asgActionByName := make(map[string]ASGAction)
+	for _, action := range asgActions {
+		asgActionByName[action.Name] = action
+	}
+
+	selectedASGs := make(map[string]bool)
+	for name := range s.asgs {
+		selectedASGs[name] = true
+	}
+	for asgName := range s.lbs {
+		if action, ok := asgActionByName[asgName]; ok && (action.Action == ASGActionCreate || action.Action == ASGActionRecreate) {
+			selectedASGs[asgName] = true
+		}
+	}
+
+	var filteredASGs []ASGAction
+	for _, action := range asgActions {
+		if selectedASGs[action.Name] {
+			filteredASGs = append(filteredASGs, action)
+		}
+	}
+
+	var filteredLBs []LBAction
+	for _, action := range lbActions {
+		selected := s.lbs[action.ASGName][action.Name]
+		if selectedASGs[action.ASGName] {
+			asgAction := asgActionByName[action.ASGName].Action
+			if asgAction == ASGActionCreate || asgAction == ASGActionRecreate {
+				selected = true
+			}
+		}
+		if selected {
+			filteredLBs = append(filteredLBs, action)
+		}
+	}
+
+	return filteredASGs, filteredLBs
+}
+
+func hasApplication(cfg *config.ClusterConfig, name string) bool {
+	for _, app := range cfg.Applications {
+		if app.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasASG(cfg *config.ClusterConfig, name string) bool {
+	for _, asg := range cfg.AutoScalingGroups {
+		if asg.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasLB(cfg *config.ClusterConfig, asgName, name string) bool {
+	for _, lb := range cfg.LoadBalancers {
+		if lb.AutoScalingGroupName == asgName && lb.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package provisioner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/state"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     Target
+	}{
+		{"app:webapp", Target{Kind: TargetApplication, Name: "webapp"}},
+		{"asg:web-asg", Target{Kind: TargetAutoScalingGroup, Name: "web-asg"}},
+		{"lb:web-asg/web-lb", Target{Kind: TargetLoadBalancer, Name: "web-lb", ASGName: "web-asg"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			target, err := ParseTarget(tt.selector)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, target)
+			assert.Equal(t, tt.selector, target.String())
+		})
+	}
+}
+
+func TestParseTarget_Invalid(t *testing.T) {
+	for _, selector := range []string{"webapp", "app:", "db:main", "lb:web-lb", "lb:/web-lb", "lb:web-asg/"} {
+		t.Run(selector, func(t *testing.T) {
+			_, err := ParseTarget(selector)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestFilterInfraActions(t *testing.T) {
+	asgActions := []ASGAction{
+		{Action: ASGActionRecreate, Name: "web-asg"},
+		{Action: ASGActionNoop, Name: "batch-asg"},
+	}
+	lbActions := []LBAction{
+		{Action: LBActionRecreate, Name: "web-lb", ASGName: "web-asg", Reason: "parent ASG is being recreated"},
+		{Action: LBActionRecreate, Name: "internal-lb", ASGName: "web-asg", Reason: "parent ASG is being recreated"},
+		{Action: LBActionCreate, Name: "batch-lb", ASGName: "batch-asg"},
+	}
+
+	t.Run("LB pulls in ASG being recreated and its other LBs", func(t *testing.T) {
+		set := &targetSet{lbs: map[string]map[string]bool{"web-asg": {"web-lb": true}}}
+		asgs, lbs := set.filterInfraActions(asgActions, lbActions)
+
+		require.Len(t, asgs, 1)
+		assert.Equal(t, "web-asg", asgs[0].Name)
+		require.Len(t, lbs, 2)
+		assert.Equal(t, "web-lb", lbs[0].Name)
+		assert.Equal(t, "internal-lb", lbs[1].Name)
+	})
+
+	t.Run("LB on unchanged ASG", func(t *testing.T) {
+		set := &targetSet{lbs: map[string]map[string]bool{"batch-asg": {"batch-lb": true}}}
+		asgs, lbs := set.filterInfraActions(asgActions, lbActions)
+
+		assert.Empty(t, asgs)
+		require.Len(t, lbs, 1)
+		assert.Equal(t, "batch-lb", lbs[0].Name)
+	})
+
+	t.Run("unchanged ASG does not pull in its LBs", func(t *testing.T) {
+		set := &targetSet{asgs: map[string]bool{"batch-asg": true}}
+		asgs, lbs := set.filterInfraActions(asgActions, lbActions)
+
+		require.Len(t, asgs, 1)
+		assert.Equal(t, "batch-asg", asgs[0].Name)
+		assert.Empty(t, lbs)
+	})
+
+	t.Run("application target only", func(t *testing.T) {
+		set := &targetSet{apps: map[string]bool{"webapp": true}}
+		asgs, lbs := set.filterInfraActions(asgActions, lbActions)
+
+		assert.Empty(t, asgs)
+		assert.Empty(t, lbs)
+	})
+}
+
+func TestCreatePlan_TargetApplication(t *testing.T) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	clusterID := createTestCluster(mockServer, "my-cluster")
+	appID := createTestApplication(mockServer, clusterID, "existing-app")
+	createTestVersion(mockServer, appID, 1, 500, 1024)
+
+	provisioner := NewProvisioner(client, state.NewState(), "")
+	plan, err := provisioner.CreatePlan(context.Background(), planFileTestConfig(), PlanOptions{
+		Targets: []Target{{Kind: TargetApplication, Name: "new-app"}},
+	})
+
+	require.NoError(t, err)
+	assert.True(t, plan.IsTargeted())
+	require.Len(t, plan.Actions, 1)
+	assert.Equal(t, "new-app", plan.Actions[0].ApplicationName)
+	assert.Equal(t, ActionCreate, plan.Actions[0].Action)
+}
+
+func TestCreatePlan_TargetNotInConfig(t *testing.T) {
+	mockServer, client, cleanup := setupMockServer(t, "test-token", "test-secret")
+	defer cleanup()
+
+	createTestCluster(mockServer, "my-cluster")
+
+	provisioner := NewProvisioner(client, state.NewState(), "")
+	cfg := &config.ClusterConfig{ClusterName: "my-cluster"}
+	_, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{
+		Targets: []Target{{Kind: TargetLoadBalancer, Name: "web-lb", ASGName: "web-asg"}},
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "target lb:web-asg/web-lb not found in config")
+}
@@ -0,0 +1,198 @@
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/api"
+)
+
+// verifyPollInterval is the interval between checks of the containers while verifying
+var verifyPollInterval = 5 * time.Second
+
+// containerStateRestarting is the state of a container being restarted
+const containerStateRestarting = "restarting"
+
+// VerifyResult is what was observed while verifying an activated version
+type VerifyResult struct {
+	ApplicationName string
+	Version         int
+	// DesiredContainers is the number of containers the version runs at least
+	DesiredContainers int64
+	// RunningContainers is the number of running containers at the last check
+	RunningContainers int64
+	Restarts          int
+	// Events describes the restarts observed (e.g., "container abc: running -> exited (Exited (1) 3 seconds ago)")
+	Events []string
+	// Failure is why the verification failed (empty if the version is healthy)
+	Failure string
+}
+
+// Healthy reports whether the version passed the verification
+func (r *VerifyResult) Healthy() bool {
+	return r.Failure == ""
+}
+
+func (r *VerifyResult) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "application %s version %d: ", r.ApplicationName, r.Version)
+	if r.Healthy() {
+		fmt.Fprintf(&sb, "healthy (%d/%d containers running, %d restarts)", r.RunningContainers, r.DesiredContainers, r.Restarts)
+	} else {
+		sb.WriteString(r.Failure)
+	}
+	for _, event := range r.Events {
+		fmt.Fprintf(&sb, "\n    %s", event)
+	}
+	return sb.String()
+}
+
+// verifyActivations watches the containers of the versions activated by the apply for the verify window.
+// The versions that keep restarting, do not reach the desired number of running containers, or could not
+// be verified are rolled back to their previous active version (all activated versions with AtomicActivate).
+func (p *Provisioner) verifyActivations(ctx context.Context, activations []pendingActivation, opts ApplyOptions) error {
+	if len(activations) == 0 {
+		return nil
+	}
+
+	log.Printf("Verifying %d applications for %s", len(activations), opts.VerifyWindow)
+	results := make([]*VerifyResult, len(activations))
+	errs := make([]error, len(activations))
+	var wg sync.WaitGroup
+	for i, activation := range activations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = p.verifyActivation(ctx, activation, opts)
+		}()
+	}
+	wg.Wait()
+
+	var (
+		report   []string
+		failures []error
+		rollback []*pendingActivation
+	)
+	for i, result := range results {
+		switch {
+		case errs[i] != nil:
+			// The version could not be verified, so it is not known to be healthy
+			failures = append(failures, errs[i])
+		case result.Healthy():
+			log.Printf("Application %q: version %d verified (%d/%d containers running, %d restarts)", result.ApplicationName, result.Version, result.RunningContainers, result.DesiredContainers, result.Restarts)
+			if !opts.AtomicActivate {
+				continue
+			}
+		default:
+			report = append(report, result.String())
+		}
+		rollback = append(rollback, &activations[i])
+	}
+	if len(report) == 0 && len(failures) == 0 {
+		return nil
+	}
+
+	if len(report) > 0 {
+		failures = append([]error{fmt.Errorf("verification failed:\n  %s", strings.Join(report, "\n  "))}, failures...)
+	}
+	// Roll back even if the apply was cancelled
+	return errors.Join(append(failures, p.rollbackActivations(context.WithoutCancel(ctx), rollback))...)
+}
+
+// verifyActivation watches the containers of an activated version for the verify window.
+// It stops early once the version has restarted more than VerifyMaxRestarts times.
+func (p *Provisioner) verifyActivation(ctx context.Context, activation pendingActivation, opts ApplyOptions) (*VerifyResult, error) {
+	versionResp, err := p.client.GetApplicationVersion(ctx, api.GetApplicationVersionParams{
+		ApplicationID: activation.appID,
+		Version:       activation.version,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify application %s: %w", activation.appName, wrapAPIError(err, fmt.Sprintf("failed to get version %d", activation.version)))
+	}
+
+	result := &VerifyResult{
+		ApplicationName:   activation.appName,
+		Version:           int(activation.version),
+		DesiredContainers: desiredInstances(&versionResp.ApplicationVersion),
+	}
+	deadline := time.Now().Add(opts.VerifyWindow)
+	// states holds the last observed state of each container of the version
+	states := make(map[string]string)
+	restart := func(format string, args ...any) {
+		result.Restarts++
+		event := fmt.Sprintf(format, args...)
+		result.Events = append(result.Events, event)
+		log.Printf("Application %q: %s", activation.appName, event)
+	}
+
+	for {
+		resp, err := p.client.GetApplicationContainers(ctx, api.GetApplicationContainersParams{ApplicationID: activation.appID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify application %s: %w", activation.appName, wrapAPIError(err, "failed to get containers"))
+		}
+
+		result.RunningContainers = 0
+		current := make(map[string]bool)
+		for _, node := range resp.Nodes {
+			stats, ok := node.ContainersStats.Get()
+			if !ok {
+				continue
+			}
+			for _, container := range stats.Containers {
+				if container.ApplicationVersion != int64(activation.version) {
+					continue
+				}
+				current[container.ID] = true
+				if container.State == containerStateRunning {
+					result.RunningContainers++
+				}
+				previous, seen := states[container.ID]
+				if container.State != previous && (container.State == containerStateRestarting || (seen && previous == containerStateRunning)) {
+					restart("container %s: %s -> %s (%s, CPU %.1f%%)", container.ID, describeContainerState(previous), container.State, container.Status, container.CpuUsagePercent)
+				}
+				states[container.ID] = container.State
+			}
+		}
+		for id, state := range states {
+			if !current[id] {
+				if state == containerStateRunning {
+					restart("container %s: running -> gone", id)
+				}
+				delete(states, id)
+			}
+		}
+
+		if result.Restarts > opts.VerifyMaxRestarts {
+			result.Failure = fmt.Sprintf("containers restarted %d times (at most %d allowed)", result.Restarts, opts.VerifyMaxRestarts)
+			return result, nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(min(verifyPollInterval, remaining)):
+		}
+	}
+
+	if result.RunningContainers < result.DesiredContainers {
+		result.Failure = fmt.Sprintf("only %d/%d containers were running at the end of the %s window", result.RunningContainers, result.DesiredContainers, opts.VerifyWindow)
+	}
+	return result, nil
+}
+
+// describeContainerState describes a container state for verify events
+func describeContainerState(state string) string {
+	if state == "" {
+		return "new"
+	}
+	return state
+}
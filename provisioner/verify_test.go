@@ -0,0 +1,192 @@
+package provisioner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/api"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
+	"github.com/tokuhirom/apprun-dedicated-provisioner/testutil"
+)
+
+// fastVerifyPolling shortens the verify poll interval for the test
+func fastVerifyPolling(t *testing.T) {
+	interval := verifyPollInterval
+	verifyPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { verifyPollInterval = interval })
+}
+
+func verifyOptions(window time.Duration, maxRestarts int) ApplyOptions {
+	return ApplyOptions{Activate: true, Verify: true, VerifyWindow: window, VerifyMaxRestarts: maxRestarts}
+}
+
+func activeVersion(t *testing.T, mockServer *testutil.MockServer, clusterID api.ClusterID, name string) int32 {
+	app, found := mockServer.GetApplicationByName(clusterID, name)
+	require.True(t, found)
+	return app.ActiveVersion.Value
+}
+
+func TestApply_Verify_Healthy(t *testing.T) {
+	logs := captureLog(t)
+	fastVerifyPolling(t)
+	mockServer, provisioner, clusterID, appID, cleanup := setupRunningApp(t)
+	defer cleanup()
+	mockServer.SetApplicationContainers(appID,
+		api.ApplicationContainerSummary{ID: "c1", State: "running", Status: "Up 3 seconds", ApplicationVersion: 2},
+		api.ApplicationContainerSummary{ID: "c2", State: "running", Status: "Up 3 seconds", ApplicationVersion: 2},
+	)
+
+	cfg := testClusterConfig(1000)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	require.NoError(t, provisioner.Apply(context.Background(), cfg, plan, verifyOptions(50*time.Millisecond, 0)))
+
+	assert.Equal(t, int32(2), activeVersion(t, mockServer, clusterID, "existing-app"))
+	assert.Contains(t, logs.String(), `Application "existing-app": version 2 verified (2/2 containers running, 0 restarts)`)
+}
+
+func TestApply_Verify_RestartingRollsBack(t *testing.T) {
+	captureLog(t)
+	fastVerifyPolling(t)
+	mockServer, provisioner, clusterID, appID, cleanup := setupRunningApp(t)
+	defer cleanup()
+	mockServer.SetApplicationContainers(appID,
+		api.ApplicationContainerSummary{ID: "c1", State: "running", Status: "Up 1 second", ApplicationVersion: 2},
+		api.ApplicationContainerSummary{ID: "c2", State: "running", Status: "Up 1 second", ApplicationVersion: 2},
+	)
+
+	// The first container crashes shortly after the activation
+	go func() {
+		for {
+			app, _ := mockServer.GetApplicationByName(clusterID, "existing-app")
+			if app.ActiveVersion.Value == 2 {
+				break
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		time.Sleep(50 * time.Millisecond)
+		mockServer.SetApplicationContainers(appID,
+			api.ApplicationContainerSummary{ID: "c1", State: "restarting", Status: "Restarting (1) 1 second ago", CpuUsagePercent: 0, ApplicationVersion: 2},
+			api.ApplicationContainerSummary{ID: "c2", State: "running", Status: "Up 2 seconds", ApplicationVersion: 2},
+		)
+	}()
+
+	cfg := testClusterConfig(1000)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	err = provisioner.Apply(context.Background(), cfg, plan, verifyOptions(5*time.Second, 0))
+	require.Error(t, err)
+
+	assert.Contains(t, err.Error(), "application existing-app version 2: containers restarted 1 times (at most 0 allowed)")
+	assert.Contains(t, err.Error(), "container c1: running -> restarting (Restarting (1) 1 second ago, CPU 0.0%)")
+	assert.Contains(t, err.Error(), "rolled back activations: existing-app (version 2 -> version 1)")
+	assert.Equal(t, int32(1), activeVersion(t, mockServer, clusterID, "existing-app"))
+}
+
+func TestApply_Verify_NeverRunningRollsBack(t *testing.T) {
+	captureLog(t)
+	fastVerifyPolling(t)
+	mockServer, provisioner, clusterID, appID, cleanup := setupRunningApp(t)
+	defer cleanup()
+	mockServer.SetApplicationContainers(appID,
+		api.ApplicationContainerSummary{ID: "c1", State: "created", Status: "Created", ApplicationVersion: 2},
+	)
+
+	cfg := testClusterConfig(1000)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	err = provisioner.Apply(context.Background(), cfg, plan, verifyOptions(50*time.Millisecond, 2))
+	require.Error(t, err)
+
+	assert.Contains(t, err.Error(), "application existing-app version 2: only 0/2 containers were running at the end of the 50ms window")
+	assert.Equal(t, int32(1), activeVersion(t, mockServer, clusterID, "existing-app"))
+}
+
+func TestApply_Verify_AtomicActivateRollsBackAll(t *testing.T) {
+	captureLog(t)
+	fastVerifyPolling(t)
+	mockServer, provisioner, clusterID, appID, cleanup := setupRunningApp(t)
+	defer cleanup()
+	otherID := createTestApplication(mockServer, clusterID, "other-app")
+	createTestVersion(mockServer, otherID, 1, 500, 1024)
+	mockServer.SetApplicationContainers(appID, runningContainers(2, 2)...)
+	mockServer.SetApplicationContainers(otherID,
+		api.ApplicationContainerSummary{ID: "c1", State: "restarting", Status: "Restarting (137) 1 second ago", ApplicationVersion: 2},
+	)
+
+	cfg := testClusterConfig(1000)
+	cfg.Applications = append(cfg.Applications, config.ApplicationConfig{Name: "other-app", Spec: cfg.Applications[0].Spec})
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+
+	opts := verifyOptions(50*time.Millisecond, 0)
+	opts.AtomicActivate = true
+	err = provisioner.Apply(context.Background(), cfg, plan, opts)
+	require.Error(t, err)
+
+	assert.Contains(t, err.Error(), "application other-app version 2: containers restarted 1 times (at most 0 allowed)")
+	assert.NotContains(t, err.Error(), "application existing-app version 2:")
+	// The healthy application is rolled back together with the failed one
+	assert.Equal(t, int32(1), activeVersion(t, mockServer, clusterID, "existing-app"))
+	assert.Equal(t, int32(1), activeVersion(t, mockServer, clusterID, "other-app"))
+}
+
+func TestApply_Verify_ErrorRollsBackWithFailures(t *testing.T) {
+	captureLog(t)
+	fastVerifyPolling(t)
+	mockServer, provisioner, clusterID, appID, cleanup := setupRunningApp(t)
+	defer cleanup()
+	mockServer.SetApplicationContainers(appID, runningContainers(2, 2)...)
+	unhealthyID := createTestApplication(mockServer, clusterID, "unhealthy-app")
+	createTestVersion(mockServer, unhealthyID, 1, 500, 1024)
+	mockServer.SetApplicationContainers(unhealthyID,
+		api.ApplicationContainerSummary{ID: "c1", State: "created", Status: "Created", ApplicationVersion: 2},
+	)
+	unverifiedID := createTestApplication(mockServer, clusterID, "unverified-app")
+	createTestVersion(mockServer, unverifiedID, 1, 500, 1024)
+	mockServer.FailApplicationContainers(unverifiedID, "containers unavailable")
+
+	cfg := testClusterConfig(1000)
+	for _, name := range []string{"unhealthy-app", "unverified-app"} {
+		cfg.Applications = append(cfg.Applications, config.ApplicationConfig{Name: name, Spec: cfg.Applications[0].Spec})
+	}
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	err = provisioner.Apply(context.Background(), cfg, plan, verifyOptions(50*time.Millisecond, 0))
+	require.Error(t, err)
+
+	// Both the failed and the unverified versions are reported and rolled back
+	assert.Contains(t, err.Error(), "application unhealthy-app version 2: only 0/2 containers were running at the end of the 50ms window")
+	assert.Contains(t, err.Error(), "failed to verify application unverified-app")
+	assert.Contains(t, err.Error(), "containers unavailable")
+	assert.Equal(t, int32(1), activeVersion(t, mockServer, clusterID, "unhealthy-app"))
+	assert.Equal(t, int32(1), activeVersion(t, mockServer, clusterID, "unverified-app"))
+	// The healthy version stays active
+	assert.Equal(t, int32(2), activeVersion(t, mockServer, clusterID, "existing-app"))
+}
+
+func TestApply_Verify_WaitTimeoutRollsBack(t *testing.T) {
+	captureLog(t)
+	fastRolloutPolling(t)
+	fastVerifyPolling(t)
+	mockServer, provisioner, clusterID, _, cleanup := setupRunningApp(t)
+	defer cleanup()
+
+	// Version 2 never gets an active node, so the rollout does not complete
+	cfg := testClusterConfig(1000)
+	plan, err := provisioner.CreatePlan(context.Background(), cfg, PlanOptions{})
+	require.NoError(t, err)
+	opts := verifyOptions(50*time.Millisecond, 0)
+	opts.Wait = true
+	opts.WaitTimeout = 50 * time.Millisecond
+	err = provisioner.Apply(context.Background(), cfg, plan, opts)
+	require.Error(t, err)
+
+	assert.Contains(t, err.Error(), "the apply completed, but the rollout did not: rollout of application existing-app did not complete within 50ms")
+	assert.Contains(t, err.Error(), "rolled back activations: existing-app (version 2 -> version 1)")
+	assert.Equal(t, int32(1), activeVersion(t, mockServer, clusterID, "existing-app"))
+}
@@ -0,0 +1,186 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	journalFileSuffix = ".apprun-journal.json"
+	journalVersion    = 1
+)
+
+// StepStatus represents the status of an apply step
+type StepStatus string
+
+const (
+	StepStarted   StepStatus = "started"
+	StepCompleted StepStatus = "completed"
+	StepFailed    StepStatus = "failed"
+)
+
+// JournalStep records the progress of a single apply step
+type JournalStep struct {
+	// ID identifies the step (e.g., "delete lb:web-asg/web-lb", "apply app:webapp")
+	ID     string     `json:"id"`
+	Status StepStatus `json:"status"`
+	// ResourceID is the ID returned by the API (e.g., the ID of a created ASG)
+	ResourceID string `json:"resourceId,omitempty"`
+	// Version is the application version created by the step
+	Version   int       `json:"version,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Journal records the steps of an apply so that an interrupted apply can be resumed.
+// It is written next to the state file and removed when the apply completes.
+type Journal struct {
+	Version   int       `json:"version"`
+	StartedAt time.Time `json:"startedAt"`
+	// Plan is the plan being applied, as saved by the provisioner
+	Plan json.RawMessage `json:"plan"`
+	// Activate records whether versions are activated by the apply
+	Activate bool `json:"activate"`
+	// AtomicActivate records whether activation is deferred until all versions are created
+	AtomicActivate bool          `json:"atomicActivate,omitempty"`
+	Steps          []JournalStep `json:"steps"`
+
+	mu         sync.Mutex
+	configPath string
+}
+
+// GetJournalPath returns the journal file path based on config file path
+// e.g., config.yaml -> config.apprun-journal.json
+func GetJournalPath(configPath string) string {
+	statePath := GetStatePath(configPath)
+	return statePath[:len(statePath)-len(stateFileSuffix)] + journalFileSuffix
+}
+
+// NewJournal creates a new journal for the given plan
+func NewJournal(configPath string, plan json.RawMessage, activate bool) *Journal {
+	return &Journal{
+		Version:    journalVersion,
+		StartedAt:  time.Now(),
+		Plan:       plan,
+		Activate:   activate,
+		configPath: configPath,
+	}
+}
+
+// LoadJournal loads the journal file from the same directory as config.
+// Returns nil if no journal exists.
+func LoadJournal(configPath string) (*Journal, error) {
+	data, err := os.ReadFile(GetJournalPath(configPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var journal Journal
+	if err := json.Unmarshal(data, &journal); err != nil {
+		return nil, fmt.Errorf("failed to parse journal: %w", err)
+	}
+	if journal.Version != journalVersion {
+		return nil, fmt.Errorf("unsupported journal version %d (expected %d)", journal.Version, journalVersion)
+	}
+	journal.configPath = configPath
+
+	return &journal, nil
+}
+
+// RemoveJournal removes the journal file (no error if it does not exist)
+func RemoveJournal(configPath string) error {
+	if err := os.Remove(GetJournalPath(configPath)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Save writes the journal to disk.
+// The file is replaced atomically so that a crash never leaves a truncated journal.
+func (j *Journal) Save() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.save()
+}
+
+func (j *Journal) save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := GetJournalPath(j.configPath)
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Step returns the recorded step with the given ID
+func (j *Journal) Step(id string) (JournalStep, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, step := range j.Steps {
+		if step.ID == id {
+			return step, true
+		}
+	}
+	return JournalStep{}, false
+}
+
+// IsCompleted reports whether the step with the given ID has completed
+func (j *Journal) IsCompleted(id string) bool {
+	step, ok := j.Step(id)
+	return ok && step.Status == StepCompleted
+}
+
+// Record updates the step with the given ID and saves the journal
+func (j *Journal) Record(step JournalStep) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	step.UpdatedAt = time.Now()
+	replaced := false
+	for i := range j.Steps {
+		if j.Steps[i].ID == step.ID {
+			j.Steps[i] = step
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		j.Steps = append(j.Steps, step)
+	}
+
+	return j.save()
+}
+
+// CompletedSteps returns the number of completed steps
+func (j *Journal) CompletedSteps() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	count := 0
+	for _, step := range j.Steps {
+		if step.Status == StepCompleted {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,266 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const lockFileSuffix = ".apprun-lock.json"
+
+// lockRetryInterval is the interval between attempts to acquire a held lock
+var lockRetryInterval = 2 * time.Second
+
+// LockInfo describes the holder of a lock
+type LockInfo struct {
+	// ID identifies the lock; it is needed to release the lock with force-unlock
+	ID string `json:"id"`
+	// Operation is the command holding the lock (e.g., "apply")
+	Operation   string `json:"operation"`
+	ClusterName string `json:"clusterName"`
+	// Who is the user and host holding the lock (e.g., "alice@ci-runner-1")
+	Who string `json:"who"`
+	// PlanFile is the saved plan being applied, if any
+	PlanFile string `json:"planFile,omitempty"`
+	// ConfigHash is the hash of the config being applied
+	ConfigHash string    `json:"configHash,omitempty"`
+	Created    time.Time `json:"created"`
+}
+
+// NewLockInfo creates the lock info of an operation run by the current user on this host
+func NewLockInfo(operation, clusterName string) *LockInfo {
+	who := "unknown"
+	if u, err := user.Current(); err == nil {
+		who = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		who += "@" + host
+	}
+	return &LockInfo{
+		ID:          uuid.NewString(),
+		Operation:   operation,
+		ClusterName: clusterName,
+		Who:         who,
+		Created:     time.Now(),
+	}
+}
+
+func (i *LockInfo) String() string {
+	s := fmt.Sprintf("%s by %s since %s (lock ID %s)", i.Operation, i.Who, i.Created.Local().Format("2006-01-02 15:04:05"), i.ID)
+	if i.PlanFile != "" {
+		s += fmt.Sprintf(", plan %s", i.PlanFile)
+	}
+	return s
+}
+
+// LockedError is returned when the lock is held by another operation
+type LockedError struct {
+	// Info is the holder of the lock (nil if the backend did not report it)
+	Info *LockInfo
+}
+
+func (e *LockedError) Error() string {
+	if e.Info == nil {
+		return "the cluster is locked by another operation"
+	}
+	return fmt.Sprintf("the cluster is locked by %s", e.Info)
+}
+
+// Locker is a backend that stores the lock of a cluster
+type Locker interface {
+	// Lock acquires the lock, or returns a *LockedError if it is already held
+	Lock(ctx context.Context, info *LockInfo) error
+	// Unlock releases the lock with the given ID
+	Unlock(ctx context.Context, id string) error
+}
+
+// AcquireLock acquires the lock, retrying until the timeout while it is held by another operation.
+// A zero timeout fails immediately if the lock is held.
+func AcquireLock(ctx context.Context, locker Locker, info *LockInfo, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	waiting := false
+	for {
+		err := locker.Lock(ctx, info)
+		var locked *LockedError
+		if !errors.As(err, &locked) || time.Now().Add(lockRetryInterval).After(deadline) {
+			return err
+		}
+		if !waiting {
+			log.Printf("Waiting up to %s for the lock: %v", timeout, err)
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// GetLockPath returns the lock file path based on config file path
+// e.g., config.yaml -> config.apprun-lock.json
+func GetLockPath(configPath string) string {
+	statePath := GetStatePath(configPath)
+	return statePath[:len(statePath)-len(stateFileSuffix)] + lockFileSuffix
+}
+
+// LocalLocker stores the lock in a file next to the state file.
+// It only prevents concurrent applies that share the file system.
+type LocalLocker struct {
+	path string
+}
+
+// NewLocalLocker creates a locker that uses the lock file of the config
+func NewLocalLocker(configPath string) *LocalLocker {
+	return &LocalLocker{path: GetLockPath(configPath)}
+}
+
+// Lock creates the lock file, or returns a *LockedError if it already exists
+func (l *LocalLocker) Lock(_ context.Context, info *LockInfo) error {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write the info to a temporary file and link it into place,
+	// so that the lock file is created only if it does not exist and is never seen half-written
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Link(tmp.Name(), l.path); err != nil {
+		if !errors.Is(err, os.ErrExist) {
+			return err
+		}
+		holder, err := l.read()
+		if err != nil {
+			return err
+		}
+		return &LockedError{Info: holder}
+	}
+	return nil
+}
+
+// Unlock removes the lock file if it holds the lock with the given ID
+func (l *LocalLocker) Unlock(_ context.Context, id string) error {
+	holder, err := l.read()
+	if err != nil {
+		return err
+	}
+	if holder == nil {
+		return fmt.Errorf("the cluster is not locked (%s not found)", l.path)
+	}
+	if holder.ID != id {
+		return fmt.Errorf("lock ID %s does not match the held lock: %w", id, &LockedError{Info: holder})
+	}
+	return os.Remove(l.path)
+}
+
+// read returns the holder of the lock, or nil if the lock file does not exist
+func (l *LocalLocker) read() (*LockInfo, error) {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var info LockInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file %s: %w", l.path, err)
+	}
+	return &info, nil
+}
+
+// HTTPLocker stores the lock in a remote HTTP server.
+// The server acquires the lock on a LOCK request and releases it on an UNLOCK request to the address,
+// both with a LockInfo body. It responds 200 on success, and 423 or 409 with the LockInfo of the holder
+// if the lock is held by another operation.
+type HTTPLocker struct {
+	address  string
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewHTTPLocker creates a locker that uses the lock at the given URL.
+// Basic authentication is used if the username is not empty.
+func NewHTTPLocker(address, username, password string) *HTTPLocker {
+	return &HTTPLocker{
+		address:  address,
+		username: username,
+		password: password,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Lock sends a LOCK request, and returns a *LockedError if the server reports the lock is held
+func (l *HTTPLocker) Lock(ctx context.Context, info *LockInfo) error {
+	return l.do(ctx, "LOCK", info)
+}
+
+// Unlock sends an UNLOCK request for the lock with the given ID
+func (l *HTTPLocker) Unlock(ctx context.Context, id string) error {
+	return l.do(ctx, "UNLOCK", &LockInfo{ID: id})
+}
+
+func (l *HTTPLocker) do(ctx context.Context, method string, info *LockInfo) error {
+	body, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, l.address, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if l.username != "" {
+		req.SetBasicAuth(l.username, l.password)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send %s request to %s: %w", method, l.address, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read %s response from %s: %w", method, l.address, err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusLocked, http.StatusConflict:
+		var holder LockInfo
+		if err := json.Unmarshal(respBody, &holder); err != nil || holder.ID == "" {
+			return &LockedError{}
+		}
+		return &LockedError{Info: &holder}
+	default:
+		if msg := bytes.TrimSpace(respBody); len(msg) > 0 {
+			return fmt.Errorf("%s request to %s failed: %s: %s", method, l.address, resp.Status, msg)
+		}
+		return fmt.Errorf("%s request to %s failed: %s", method, l.address, resp.Status)
+	}
+}
@@ -0,0 +1,262 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLocker reports the lock as held for the first attempts
+type fakeLocker struct {
+	heldFor int
+	calls   int
+}
+
+func (l *fakeLocker) Lock(_ context.Context, _ *LockInfo) error {
+	l.calls++
+	if l.calls <= l.heldFor {
+		return &LockedError{Info: &LockInfo{ID: "other", Operation: "apply"}}
+	}
+	return nil
+}
+
+func (l *fakeLocker) Unlock(_ context.Context, _ string) error {
+	return nil
+}
+
+// shortRetryInterval shortens the interval between lock attempts for the duration of the test
+func shortRetryInterval(t *testing.T) {
+	interval := lockRetryInterval
+	lockRetryInterval = 10 * time.Millisecond
+	t.Cleanup(func() { lockRetryInterval = interval })
+}
+
+func TestAcquireLock_RetriesWhileHeld(t *testing.T) {
+	shortRetryInterval(t)
+	locker := &fakeLocker{heldFor: 2}
+
+	err := AcquireLock(context.Background(), locker, NewLockInfo("apply", "my-cluster"), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 3, locker.calls)
+}
+
+func TestAcquireLock_ZeroTimeoutFailsImmediately(t *testing.T) {
+	shortRetryInterval(t)
+	locker := &fakeLocker{heldFor: 1}
+
+	err := AcquireLock(context.Background(), locker, NewLockInfo("apply", "my-cluster"), 0)
+	var locked *LockedError
+	require.ErrorAs(t, err, &locked)
+	assert.Equal(t, "other", locked.Info.ID)
+	assert.Equal(t, 1, locker.calls)
+}
+
+func TestAcquireLock_TimesOut(t *testing.T) {
+	shortRetryInterval(t)
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	holder := NewLockInfo("apply", "my-cluster")
+	require.NoError(t, NewLocalLocker(configPath).Lock(context.Background(), holder))
+
+	start := time.Now()
+	err := AcquireLock(context.Background(), NewLocalLocker(configPath), NewLockInfo("apply", "my-cluster"), 50*time.Millisecond)
+	var locked *LockedError
+	require.ErrorAs(t, err, &locked)
+	assert.Equal(t, holder.ID, locked.Info.ID)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestAcquireLock_Cancelled(t *testing.T) {
+	shortRetryInterval(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := AcquireLock(ctx, &fakeLocker{heldFor: 100}, NewLockInfo("apply", "my-cluster"), time.Minute)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestGetLockPath(t *testing.T) {
+	assert.Equal(t, filepath.Join("deploy", "config.apprun-lock.json"), GetLockPath(filepath.Join("deploy", "config.yaml")))
+}
+
+func TestLocalLocker_LockIsExclusive(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+
+	// Only one of the concurrent attempts acquires the lock
+	const attempts = 10
+	infos := make([]*LockInfo, attempts)
+	errs := make([]error, attempts)
+	var wg sync.WaitGroup
+	for i := range attempts {
+		infos[i] = NewLockInfo("apply", "my-cluster")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = NewLocalLocker(configPath).Lock(context.Background(), infos[i])
+		}()
+	}
+	wg.Wait()
+
+	var holder *LockInfo
+	for i, err := range errs {
+		if err == nil {
+			require.Nil(t, holder, "the lock was acquired twice")
+			holder = infos[i]
+		}
+	}
+	require.NotNil(t, holder, "the lock was not acquired")
+	for _, err := range errs {
+		if err != nil {
+			var locked *LockedError
+			require.ErrorAs(t, err, &locked)
+			assert.Equal(t, holder.ID, locked.Info.ID)
+		}
+	}
+
+	// The lock file holds the info of the holder, and no temporary files are left behind
+	data, err := os.ReadFile(GetLockPath(configPath))
+	require.NoError(t, err)
+	var info LockInfo
+	require.NoError(t, json.Unmarshal(data, &info))
+	assert.Equal(t, holder.ID, info.ID)
+	assert.Equal(t, "my-cluster", info.ClusterName)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "config.apprun-lock.json", entries[0].Name())
+}
+
+func TestLocalLocker_Unlock(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	locker := NewLocalLocker(configPath)
+	info := NewLockInfo("apply", "my-cluster")
+	require.NoError(t, locker.Lock(context.Background(), info))
+
+	// A stale lock ID does not release the lock of another operation
+	err := locker.Unlock(context.Background(), "stale-id")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "lock ID stale-id does not match the held lock")
+	var locked *LockedError
+	require.ErrorAs(t, err, &locked)
+	assert.Equal(t, info.ID, locked.Info.ID)
+	assert.FileExists(t, GetLockPath(configPath))
+
+	require.NoError(t, locker.Unlock(context.Background(), info.ID))
+	assert.NoFileExists(t, GetLockPath(configPath))
+
+	err = locker.Unlock(context.Background(), info.ID)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the cluster is not locked")
+
+	// The lock can be acquired again once released
+	require.NoError(t, locker.Lock(context.Background(), NewLockInfo("apply", "my-cluster")))
+}
+
+// lockRequest is a request received by the test lock server
+type lockRequest struct {
+	method   string
+	info     LockInfo
+	username string
+	password string
+}
+
+func startLockServer(t *testing.T, status int, body string) (*httptest.Server, *[]lockRequest) {
+	var mu sync.Mutex
+	var requests []lockRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := lockRequest{method: r.Method}
+		req.username, req.password, _ = r.BasicAuth()
+		if err := json.NewDecoder(r.Body).Decode(&req.info); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &requests
+}
+
+func TestHTTPLocker_LockAndUnlock(t *testing.T) {
+	ts, requests := startLockServer(t, http.StatusOK, "")
+	locker := NewHTTPLocker(ts.URL, "user", "pass")
+	info := NewLockInfo("apply", "my-cluster")
+	info.PlanFile = "plan.json"
+
+	require.NoError(t, locker.Lock(context.Background(), info))
+	require.NoError(t, locker.Unlock(context.Background(), info.ID))
+
+	require.Len(t, *requests, 2)
+	lock, unlock := (*requests)[0], (*requests)[1]
+	assert.Equal(t, "LOCK", lock.method)
+	assert.Equal(t, info.ID, lock.info.ID)
+	assert.Equal(t, "apply", lock.info.Operation)
+	assert.Equal(t, "my-cluster", lock.info.ClusterName)
+	assert.Equal(t, "plan.json", lock.info.PlanFile)
+	assert.Equal(t, "user", lock.username)
+	assert.Equal(t, "pass", lock.password)
+
+	assert.Equal(t, "UNLOCK", unlock.method)
+	assert.Equal(t, LockInfo{ID: info.ID}, unlock.info)
+}
+
+func TestHTTPLocker_Locked(t *testing.T) {
+	holder := NewLockInfo("apply", "my-cluster")
+	holderJSON, err := json.Marshal(holder)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantHolder string
+	}{
+		{name: "423 with the holder", status: http.StatusLocked, body: string(holderJSON), wantHolder: holder.ID},
+		{name: "409 with the holder", status: http.StatusConflict, body: string(holderJSON), wantHolder: holder.ID},
+		{name: "423 without a body", status: http.StatusLocked},
+		{name: "409 with an unparsable body", status: http.StatusConflict, body: "locked"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, _ := startLockServer(t, tt.status, tt.body)
+
+			err := NewHTTPLocker(ts.URL, "", "").Lock(context.Background(), NewLockInfo("apply", "my-cluster"))
+			var locked *LockedError
+			require.ErrorAs(t, err, &locked)
+			if tt.wantHolder == "" {
+				assert.Nil(t, locked.Info)
+				return
+			}
+			require.NotNil(t, locked.Info)
+			assert.Equal(t, tt.wantHolder, locked.Info.ID)
+		})
+	}
+}
+
+func TestHTTPLocker_ServerError(t *testing.T) {
+	ts, requests := startLockServer(t, http.StatusInternalServerError, "backend unavailable\n")
+
+	err := NewHTTPLocker(ts.URL, "", "").Unlock(context.Background(), "lock-id")
+	require.Error(t, err)
+	var locked *LockedError
+	assert.False(t, errors.As(err, &locked))
+	assert.Contains(t, err.Error(), "UNLOCK request to "+ts.URL+" failed: 500 Internal Server Error: backend unavailable")
+
+	// No credentials are sent without a username
+	require.Len(t, *requests, 1)
+	assert.Empty(t, (*requests)[0].username)
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+
+	"github.com/tokuhirom/apprun-dedicated-provisioner/config"
 )
 
 const (
@@ -33,7 +35,21 @@ func NewState() *State {
 
 // GetStatePath returns the state file path based on config file path
 // e.g., config.yaml -> config.apprun-state.json
+//
+// For a config directory or glob pattern, the state file is placed next to the config directory
+// and named after it, e.g., clusters/prod/ or clusters/prod/*.yaml -> clusters/prod.apprun-state.json
 func GetStatePath(configPath string) string {
+	if config.IsMultiFile(configPath) {
+		dir := config.Dir(configPath)
+		if base := filepath.Base(dir); base == "." || base == ".." {
+			// Name the state file after the actual directory
+			if abs, err := filepath.Abs(dir); err == nil {
+				dir = abs
+			}
+		}
+		return dir + stateFileSuffix
+	}
+
 	dir := filepath.Dir(configPath)
 	base := filepath.Base(configPath)
 	ext := filepath.Ext(base)
@@ -120,6 +136,16 @@ func (s *State) SetSecretEnvVersion(appName, envKey string, version *int) {
 	s.cleanupApp(appName)
 }
 
+// RemoveApplication removes the stored versions of an application (e.g., when it is deleted).
+// Returns true if the state was modified.
+func (s *State) RemoveApplication(appName string) bool {
+	if _, ok := s.Applications[appName]; !ok {
+		return false
+	}
+	delete(s.Applications, appName)
+	return true
+}
+
 // ensureApp ensures the application state exists
 func (s *State) ensureApp(appName string) {
 	if _, ok := s.Applications[appName]; !ok {
@@ -0,0 +1,40 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetStatePath(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "clusters", "prod"), 0o755))
+
+	tests := []struct {
+		name       string
+		configPath string
+		want       string
+	}{
+		{name: "file", configPath: filepath.Join(dir, "apprun.yaml"), want: filepath.Join(dir, "apprun.apprun-state.json")},
+		{name: "directory", configPath: filepath.Join(dir, "clusters", "prod"), want: filepath.Join(dir, "clusters", "prod.apprun-state.json")},
+		{name: "directory with a trailing slash", configPath: filepath.Join(dir, "clusters", "prod") + "/", want: filepath.Join(dir, "clusters", "prod.apprun-state.json")},
+		{name: "glob pattern", configPath: filepath.Join(dir, "clusters", "prod", "*.yaml"), want: filepath.Join(dir, "clusters", "prod.apprun-state.json")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, GetStatePath(tt.configPath))
+		})
+	}
+}
+
+func TestGetStatePath_CurrentDirectory(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	// The state file is named after the actual directory, next to it
+	assert.Equal(t, dir+".apprun-state.json", GetStatePath("."))
+	assert.Equal(t, dir+".apprun-lock.json", GetLockPath("."))
+}
@@ -2,9 +2,11 @@ package testutil
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"sync"
 	"time"
 
@@ -20,7 +22,7 @@ type ApplicationVersionKey struct {
 }
 
 // MockServer is a mock server for testing that implements the ogen Handler interface.
-// Supports Cluster, Application, and ApplicationVersion APIs used by the provisioner.
+// Supports Cluster, AutoScalingGroup, LoadBalancer, WorkerNode, WorkerServiceClass, Application, ApplicationVersion, and ApplicationContainer APIs used by the provisioner.
 type MockServer struct {
 	api.UnimplementedHandler
 
@@ -29,12 +31,27 @@ type MockServer struct {
 	applications        map[api.ApplicationID]api.ReadApplicationDetail
 	applicationVersions map[ApplicationVersionKey]api.ReadApplicationVersionDetail
 	nextVersionNumber   map[api.ApplicationID]api.ApplicationVersionNumber
+	failedActivations   map[ApplicationVersionKey]bool
+	autoScalingGroups   map[api.AutoScalingGroupID]mockAutoScalingGroup
+	loadBalancers       map[api.AutoScalingGroupID]map[api.LoadBalancerID]api.ReadLoadBalancerDetail
+	workerNodes         map[api.AutoScalingGroupID]map[api.WorkerNodeID]api.ReadWorkerNodeDetail
+	loadBalancerNodes   map[api.LoadBalancerID][]api.ReadLoadBalancerNodeSummary
+	failedLBNodes       map[string]string
+	workerClasses       []api.ReadWorkerServiceClass
+	containers          map[api.ApplicationID][]api.ApplicationContainerSummary
+	failedContainers    map[api.ApplicationID]string
 
 	// Authentication
 	expectedToken  string
 	expectedSecret string
 }
 
+// mockAutoScalingGroup is an ASG stored with the cluster it belongs to.
+type mockAutoScalingGroup struct {
+	clusterID api.ClusterID
+	detail    api.ReadAutoScalingGroupDetail
+}
+
 // NewMockServer creates a new mock server with the given authentication credentials.
 func NewMockServer(token, secret string) *MockServer {
 	return &MockServer{
@@ -42,6 +59,14 @@ func NewMockServer(token, secret string) *MockServer {
 		applications:        make(map[api.ApplicationID]api.ReadApplicationDetail),
 		applicationVersions: make(map[ApplicationVersionKey]api.ReadApplicationVersionDetail),
 		nextVersionNumber:   make(map[api.ApplicationID]api.ApplicationVersionNumber),
+		failedActivations:   make(map[ApplicationVersionKey]bool),
+		containers:          make(map[api.ApplicationID][]api.ApplicationContainerSummary),
+		failedContainers:    make(map[api.ApplicationID]string),
+		autoScalingGroups:   make(map[api.AutoScalingGroupID]mockAutoScalingGroup),
+		loadBalancers:       make(map[api.AutoScalingGroupID]map[api.LoadBalancerID]api.ReadLoadBalancerDetail),
+		workerNodes:         make(map[api.AutoScalingGroupID]map[api.WorkerNodeID]api.ReadWorkerNodeDetail),
+		loadBalancerNodes:   make(map[api.LoadBalancerID][]api.ReadLoadBalancerNodeSummary),
+		failedLBNodes:       make(map[string]string),
 		expectedToken:       token,
 		expectedSecret:      secret,
 	}
@@ -166,29 +191,228 @@ func (m *MockServer) ListAutoScalingGroups(ctx context.Context, params api.ListA
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	// Return empty list by default (ASGs are managed separately)
+	asgs := []api.ReadAutoScalingGroupDetail{}
+	for _, asg := range m.autoScalingGroups {
+		if asg.clusterID == params.ClusterID {
+			asgs = append(asgs, asg.detail)
+		}
+	}
+
 	return &api.ListAutoScalingGroupResponse{
-		AutoScalingGroups: []api.ReadAutoScalingGroupDetail{},
+		AutoScalingGroups: asgs,
 		NextCursor:        api.OptAutoScalingGroupID{},
 	}, nil
 }
 
+// GetAutoScalingGroup returns the details of a specific ASG.
+func (m *MockServer) GetAutoScalingGroup(ctx context.Context, params api.GetAutoScalingGroupParams) (*api.GetAutoScalingGroupResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	asg, exists := m.autoScalingGroups[params.AutoScalingGroupID]
+	if !exists || asg.clusterID != params.ClusterID {
+		return nil, fmt.Errorf("auto scaling group %s not found", uuid.UUID(params.AutoScalingGroupID).String())
+	}
+
+	return &api.GetAutoScalingGroupResponse{
+		AutoScalingGroup: asg.detail,
+	}, nil
+}
+
+// DeleteAutoScalingGroup deletes an ASG and its worker nodes. ASGs with LBs cannot be deleted.
+func (m *MockServer) DeleteAutoScalingGroup(ctx context.Context, params api.DeleteAutoScalingGroupParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	asg, exists := m.autoScalingGroups[params.AutoScalingGroupID]
+	if !exists || asg.clusterID != params.ClusterID {
+		return fmt.Errorf("auto scaling group %s not found", uuid.UUID(params.AutoScalingGroupID).String())
+	}
+	if len(m.loadBalancers[params.AutoScalingGroupID]) > 0 {
+		return fmt.Errorf("auto scaling group %s still has load balancers", asg.detail.Name)
+	}
+
+	delete(m.autoScalingGroups, params.AutoScalingGroupID)
+	delete(m.loadBalancers, params.AutoScalingGroupID)
+	delete(m.workerNodes, params.AutoScalingGroupID)
+	return nil
+}
+
 // =============================================================================
 // LoadBalancer APIs
 // =============================================================================
 
+// CreateLoadBalancer creates a new LB in an ASG with a single healthy node.
+func (m *MockServer) CreateLoadBalancer(ctx context.Context, req *api.CreateLoadBalancer, params api.CreateLoadBalancerParams) (*api.CreateLoadBalancerResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	asg, exists := m.autoScalingGroups[params.AutoScalingGroupID]
+	if !exists || asg.clusterID != params.ClusterID {
+		return nil, fmt.Errorf("auto scaling group %s not found", uuid.UUID(params.AutoScalingGroupID).String())
+	}
+	for _, lb := range m.loadBalancers[params.AutoScalingGroupID] {
+		if lb.Name == req.Name {
+			return nil, fmt.Errorf("load balancer with name %q already exists", req.Name)
+		}
+	}
+
+	lbID := api.LoadBalancerID(uuid.New())
+	if m.loadBalancers[params.AutoScalingGroupID] == nil {
+		m.loadBalancers[params.AutoScalingGroupID] = make(map[api.LoadBalancerID]api.ReadLoadBalancerDetail)
+	}
+	m.loadBalancers[params.AutoScalingGroupID][lbID] = api.ReadLoadBalancerDetail{
+		LoadBalancerID:   lbID,
+		Name:             req.Name,
+		ServiceClassPath: req.ServiceClassPath,
+		NameServers:      req.NameServers,
+		Interfaces:       req.Interfaces,
+		Created:          int(time.Now().Unix()),
+	}
+
+	node := api.ReadLoadBalancerNodeSummary{
+		LoadBalancerNodeID: api.LoadBalancerNodeID(uuid.New()),
+		Status:             api.LoadBalancerNodeStatusHealthy,
+		Created:            int(time.Now().Unix()),
+	}
+	if msg, ok := m.failedLBNodes[req.Name]; ok {
+		node.Status = api.LoadBalancerNodeStatusUnhealthy
+		node.CreateErrorMessage = api.NewOptString(msg)
+	}
+	m.loadBalancerNodes[lbID] = []api.ReadLoadBalancerNodeSummary{node}
+
+	return &api.CreateLoadBalancerResponse{
+		LoadBalancer: api.CreatedLoadBalancer{
+			LoadBalancerID: lbID,
+		},
+	}, nil
+}
+
 // ListLoadBalancers returns a list of all LBs for an ASG.
 func (m *MockServer) ListLoadBalancers(ctx context.Context, params api.ListLoadBalancersParams) (*api.ListLoadBalancersResponse, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	// Return empty list by default (LBs are managed separately)
+	lbs := []api.ReadLoadBalancerSummary{}
+	for _, lb := range m.loadBalancers[params.AutoScalingGroupID] {
+		lbs = append(lbs, api.ReadLoadBalancerSummary{
+			LoadBalancerID:   lb.LoadBalancerID,
+			Name:             lb.Name,
+			ServiceClassPath: lb.ServiceClassPath,
+			NameServers:      lb.NameServers,
+			Created:          lb.Created,
+			Deleting:         lb.Deleting,
+		})
+	}
+
 	return &api.ListLoadBalancersResponse{
-		LoadBalancers: []api.ReadLoadBalancerSummary{},
+		LoadBalancers: lbs,
 		NextCursor:    api.OptLoadBalancerID{},
 	}, nil
 }
 
+// GetLoadBalancer returns the details of a specific LB.
+func (m *MockServer) GetLoadBalancer(ctx context.Context, params api.GetLoadBalancerParams) (*api.GetLoadBalancerResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	lb, exists := m.loadBalancers[params.AutoScalingGroupID][params.LoadBalancerID]
+	if !exists {
+		return nil, fmt.Errorf("load balancer %s not found", uuid.UUID(params.LoadBalancerID).String())
+	}
+
+	return &api.GetLoadBalancerResponse{
+		LoadBalancer: lb,
+	}, nil
+}
+
+// DeleteLoadBalancer deletes an LB.
+func (m *MockServer) DeleteLoadBalancer(ctx context.Context, params api.DeleteLoadBalancerParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.loadBalancers[params.AutoScalingGroupID][params.LoadBalancerID]; !exists {
+		return fmt.Errorf("load balancer %s not found", uuid.UUID(params.LoadBalancerID).String())
+	}
+
+	delete(m.loadBalancers[params.AutoScalingGroupID], params.LoadBalancerID)
+	delete(m.loadBalancerNodes, params.LoadBalancerID)
+	return nil
+}
+
+// ListLoadBalancerNodes returns the nodes of an LB.
+func (m *MockServer) ListLoadBalancerNodes(ctx context.Context, params api.ListLoadBalancerNodesParams) (*api.ListLoadBalancerNodesResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, exists := m.loadBalancers[params.AutoScalingGroupID][params.LoadBalancerID]; !exists {
+		return nil, fmt.Errorf("load balancer %s not found", uuid.UUID(params.LoadBalancerID).String())
+	}
+
+	nodes := append([]api.ReadLoadBalancerNodeSummary{}, m.loadBalancerNodes[params.LoadBalancerID]...)
+	return &api.ListLoadBalancerNodesResponse{
+		LoadBalancerNodes: nodes,
+		NextCursor:        api.OptLoadBalancerNodeID{},
+	}, nil
+}
+
+// =============================================================================
+// WorkerNode APIs
+// =============================================================================
+
+// ListWorkerNodes returns a list of all worker nodes for an ASG.
+func (m *MockServer) ListWorkerNodes(ctx context.Context, params api.ListWorkerNodesParams) (*api.ListWorkerNodesResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	nodes := []api.ReadWorkerNodeSummary{}
+	for _, node := range m.workerNodes[params.AutoScalingGroupID] {
+		nodes = append(nodes, api.ReadWorkerNodeSummary{
+			WorkerNodeID:      node.WorkerNodeID,
+			ResourceID:        node.ResourceID,
+			Draining:          node.Draining,
+			Status:            node.Status,
+			NetworkInterfaces: node.NetworkInterfaces,
+			ArchiveVersion:    node.ArchiveVersion,
+			Created:           node.Created,
+		})
+	}
+
+	return &api.ListWorkerNodesResponse{
+		WorkerNodes: nodes,
+		NextCursor:  api.OptWorkerNodeID{},
+	}, nil
+}
+
+// GetWorkerNode returns the details of a specific worker node, including its running containers.
+func (m *MockServer) GetWorkerNode(ctx context.Context, params api.GetWorkerNodeParams) (*api.GetWorkerNodeResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	node, exists := m.workerNodes[params.AutoScalingGroupID][params.WorkerNodeID]
+	if !exists {
+		return nil, fmt.Errorf("worker node %s not found", uuid.UUID(params.WorkerNodeID).String())
+	}
+
+	return &api.GetWorkerNodeResponse{
+		WorkerNode: node,
+	}, nil
+}
+
+// =============================================================================
+// WorkerServiceClass APIs
+// =============================================================================
+
+// ListWorkerServiceClasses returns the worker service classes added with AddWorkerServiceClass.
+func (m *MockServer) ListWorkerServiceClasses(ctx context.Context) (*api.ListWorkerServiceClassResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return &api.ListWorkerServiceClassResponse{
+		WorkerServiceClasses: slices.Clone(m.workerClasses),
+	}, nil
+}
+
 // =============================================================================
 // Application APIs
 // =============================================================================
@@ -233,6 +457,25 @@ func (m *MockServer) CreateApplication(ctx context.Context, req *api.CreateAppli
 	}, nil
 }
 
+// DeleteApplication deletes an application and its versions.
+func (m *MockServer) DeleteApplication(ctx context.Context, params api.DeleteApplicationParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.applications[params.ApplicationID]; !exists {
+		return fmt.Errorf("application %s not found", uuid.UUID(params.ApplicationID).String())
+	}
+
+	delete(m.applications, params.ApplicationID)
+	delete(m.nextVersionNumber, params.ApplicationID)
+	for key := range m.applicationVersions {
+		if key.ApplicationID == params.ApplicationID {
+			delete(m.applicationVersions, key)
+		}
+	}
+	return nil
+}
+
 // ListApplications returns a list of applications, optionally filtered by cluster.
 func (m *MockServer) ListApplications(ctx context.Context, params api.ListApplicationsParams) (*api.ListApplicationResponse, error) {
 	m.mu.RLock()
@@ -252,6 +495,21 @@ func (m *MockServer) ListApplications(ctx context.Context, params api.ListApplic
 	}, nil
 }
 
+// GetApplication returns the details of a specific application.
+func (m *MockServer) GetApplication(ctx context.Context, params api.GetApplicationParams) (*api.GetApplicationResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	app, exists := m.applications[params.ApplicationID]
+	if !exists {
+		return nil, fmt.Errorf("application %s not found", uuid.UUID(params.ApplicationID).String())
+	}
+
+	return &api.GetApplicationResponse{
+		Application: app,
+	}, nil
+}
+
 // =============================================================================
 // Application Version APIs
 // =============================================================================
@@ -319,7 +577,8 @@ func (m *MockServer) CreateApplicationVersion(ctx context.Context, req *api.Crea
 	}, nil
 }
 
-// ListApplicationVersions returns a list of versions for an application.
+// ListApplicationVersions returns a page of versions for an application in ascending version order.
+// Pages hold at most MaxItems versions, and the cursor is the last version of the previous page.
 func (m *MockServer) ListApplicationVersions(ctx context.Context, params api.ListApplicationVersionsParams) (*api.ListApplicationVersionResponse, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -332,22 +591,62 @@ func (m *MockServer) ListApplicationVersions(ctx context.Context, params api.Lis
 
 	versions := make([]api.ApplicationVersionDeploymentStatus, 0)
 	for key, v := range m.applicationVersions {
-		if key.ApplicationID == params.ApplicationID {
-			versions = append(versions, api.ApplicationVersionDeploymentStatus{
-				Version:         v.Version,
-				Image:           v.Image,
-				ActiveNodeCount: v.ActiveNodeCount,
-				Created:         v.Created,
-			})
+		if key.ApplicationID != params.ApplicationID {
+			continue
 		}
+		if cursor, ok := params.Cursor.Get(); ok && v.Version <= cursor {
+			continue
+		}
+		versions = append(versions, api.ApplicationVersionDeploymentStatus{
+			Version:         v.Version,
+			Image:           v.Image,
+			ActiveNodeCount: v.ActiveNodeCount,
+			Created:         v.Created,
+		})
+	}
+	slices.SortFunc(versions, func(a, b api.ApplicationVersionDeploymentStatus) int {
+		return int(a.Version - b.Version)
+	})
+
+	var nextCursor api.OptApplicationVersionNumber
+	if params.MaxItems > 0 && int64(len(versions)) > params.MaxItems {
+		versions = versions[:params.MaxItems]
+		nextCursor.SetTo(versions[len(versions)-1].Version)
 	}
 
 	return &api.ListApplicationVersionResponse{
 		Versions:   versions,
-		NextCursor: api.OptApplicationVersionNumber{},
+		NextCursor: nextCursor,
 	}, nil
 }
 
+// GetApplicationContainers returns the containers of an application, all placed on a single node.
+func (m *MockServer) GetApplicationContainers(ctx context.Context, params api.GetApplicationContainersParams) (*api.GetApplicationContainersResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, exists := m.applications[params.ApplicationID]; !exists {
+		return nil, fmt.Errorf("application %s not found", uuid.UUID(params.ApplicationID).String())
+	}
+	if message, failed := m.failedContainers[params.ApplicationID]; failed {
+		return nil, errors.New(message)
+	}
+
+	nodes := make([]api.NodeContainerPlacementInfo, 0)
+	if containers := m.containers[params.ApplicationID]; len(containers) > 0 {
+		nodes = append(nodes, api.NodeContainerPlacementInfo{
+			NodeID: "node-1",
+			ContainersStats: api.NewNilApplicationContainersStats(api.ApplicationContainersStats{
+				CollectedAtSec: time.Now().Unix(),
+				Containers:     slices.Clone(containers),
+			}),
+			Desired: api.NilApplicationPeekDesiredContainersResponse{Null: true},
+		})
+	}
+
+	return &api.GetApplicationContainersResponse{Nodes: nodes}, nil
+}
+
 // GetApplicationVersion returns the details of a specific application version.
 func (m *MockServer) GetApplicationVersion(ctx context.Context, params api.GetApplicationVersionParams) (*api.GetApplicationVersionResponse, error) {
 	m.mu.RLock()
@@ -378,6 +677,10 @@ func (m *MockServer) UpdateApplication(ctx context.Context, req *api.UpdateAppli
 		return fmt.Errorf("application %s not found", uuid.UUID(params.ApplicationID).String())
 	}
 
+	if !req.ActiveVersion.Null && m.failedActivations[ApplicationVersionKey{ApplicationID: params.ApplicationID, Version: api.ApplicationVersionNumber(req.ActiveVersion.Value)}] {
+		return fmt.Errorf("activation of version %d failed", req.ActiveVersion.Value)
+	}
+
 	// Update active version
 	app.ActiveVersion = req.ActiveVersion
 
@@ -458,6 +761,75 @@ func (m *MockServer) ClusterCount() int {
 	return len(m.clusters)
 }
 
+// AddAutoScalingGroup adds an ASG directly to the mock server (for test setup).
+func (m *MockServer) AddAutoScalingGroup(clusterID api.ClusterID, asg api.ReadAutoScalingGroupDetail) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.autoScalingGroups[asg.AutoScalingGroupID] = mockAutoScalingGroup{clusterID: clusterID, detail: asg}
+}
+
+// HasAutoScalingGroup reports whether the ASG exists (for test assertions).
+func (m *MockServer) HasAutoScalingGroup(asgID api.AutoScalingGroupID) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, exists := m.autoScalingGroups[asgID]
+	return exists
+}
+
+// AddLoadBalancer adds an LB to an ASG directly (for test setup).
+func (m *MockServer) AddLoadBalancer(asgID api.AutoScalingGroupID, lb api.ReadLoadBalancerDetail) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.loadBalancers[asgID] == nil {
+		m.loadBalancers[asgID] = make(map[api.LoadBalancerID]api.ReadLoadBalancerDetail)
+	}
+	m.loadBalancers[asgID][lb.LoadBalancerID] = lb
+}
+
+// HasLoadBalancer reports whether the LB exists in the ASG (for test assertions).
+func (m *MockServer) HasLoadBalancer(asgID api.AutoScalingGroupID, lbID api.LoadBalancerID) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, exists := m.loadBalancers[asgID][lbID]
+	return exists
+}
+
+// LoadBalancerNames returns the sorted names of the LBs in an ASG (for test assertions).
+func (m *MockServer) LoadBalancerNames(asgID api.AutoScalingGroupID) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var names []string
+	for _, lb := range m.loadBalancers[asgID] {
+		names = append(names, lb.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// FailLoadBalancerNodes makes the nodes of LBs created with the given name fail (for testing error handling).
+func (m *MockServer) FailLoadBalancerNodes(name, message string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failedLBNodes[name] = message
+}
+
+// AddWorkerNode adds a worker node to an ASG directly (for test setup).
+func (m *MockServer) AddWorkerNode(asgID api.AutoScalingGroupID, node api.ReadWorkerNodeDetail) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.workerNodes[asgID] == nil {
+		m.workerNodes[asgID] = make(map[api.WorkerNodeID]api.ReadWorkerNodeDetail)
+	}
+	m.workerNodes[asgID][node.WorkerNodeID] = node
+}
+
+// AddWorkerServiceClass adds a worker service class (for test setup).
+func (m *MockServer) AddWorkerServiceClass(path, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.workerClasses = append(m.workerClasses, api.ReadWorkerServiceClass{Path: path, Name: name})
+}
+
 // AddApplication adds an application directly to the mock server (for test setup).
 func (m *MockServer) AddApplication(app api.ReadApplicationDetail) {
 	m.mu.Lock()
@@ -487,6 +859,8 @@ func (m *MockServer) ClearApplications() {
 	m.applications = make(map[api.ApplicationID]api.ReadApplicationDetail)
 	m.applicationVersions = make(map[ApplicationVersionKey]api.ReadApplicationVersionDetail)
 	m.nextVersionNumber = make(map[api.ApplicationID]api.ApplicationVersionNumber)
+	m.containers = make(map[api.ApplicationID][]api.ApplicationContainerSummary)
+	m.failedContainers = make(map[api.ApplicationID]string)
 }
 
 // ApplicationCount returns the number of applications.
@@ -535,6 +909,45 @@ func (m *MockServer) VersionCount(appID api.ApplicationID) int {
 	return count
 }
 
+// SetActiveNodeCount sets the number of nodes running an application version (for simulating a rollout).
+func (m *MockServer) SetActiveNodeCount(appID api.ApplicationID, version api.ApplicationVersionNumber, count int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := ApplicationVersionKey{ApplicationID: appID, Version: version}
+	if v, exists := m.applicationVersions[key]; exists {
+		v.ActiveNodeCount = count
+		m.applicationVersions[key] = v
+	}
+}
+
+// SetApplicationContainers replaces the containers of an application (for simulating a rollout).
+func (m *MockServer) SetApplicationContainers(appID api.ApplicationID, containers ...api.ApplicationContainerSummary) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.containers[appID] = containers
+}
+
+// FailApplicationContainers makes getting the containers of the application fail (for testing error handling).
+func (m *MockServer) FailApplicationContainers(appID api.ApplicationID, message string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failedContainers[appID] = message
+}
+
+// FailActivation makes activation of the given application version fail (for testing error handling).
+func (m *MockServer) FailActivation(appID api.ApplicationID, version api.ApplicationVersionNumber) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failedActivations[ApplicationVersionKey{ApplicationID: appID, Version: version}] = true
+}
+
+// ClearActivationFailures removes the failures set by FailActivation.
+func (m *MockServer) ClearActivationFailures() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failedActivations = make(map[ApplicationVersionKey]bool)
+}
+
 // ClearAll removes all data from the mock server.
 func (m *MockServer) ClearAll() {
 	m.mu.Lock()
@@ -543,6 +956,14 @@ func (m *MockServer) ClearAll() {
 	m.applications = make(map[api.ApplicationID]api.ReadApplicationDetail)
 	m.applicationVersions = make(map[ApplicationVersionKey]api.ReadApplicationVersionDetail)
 	m.nextVersionNumber = make(map[api.ApplicationID]api.ApplicationVersionNumber)
+	m.failedActivations = make(map[ApplicationVersionKey]bool)
+	m.containers = make(map[api.ApplicationID][]api.ApplicationContainerSummary)
+	m.autoScalingGroups = make(map[api.AutoScalingGroupID]mockAutoScalingGroup)
+	m.loadBalancers = make(map[api.AutoScalingGroupID]map[api.LoadBalancerID]api.ReadLoadBalancerDetail)
+	m.workerNodes = make(map[api.AutoScalingGroupID]map[api.WorkerNodeID]api.ReadWorkerNodeDetail)
+	m.loadBalancerNodes = make(map[api.LoadBalancerID][]api.ReadLoadBalancerNodeSummary)
+	m.failedLBNodes = make(map[string]string)
+	m.workerClasses = nil
 }
 
 // StartTestServer starts an HTTP test server with the mock handler.
@@ -218,6 +218,33 @@ func TestMockServer_ListApplications(t *testing.T) {
 	assert.Equal(t, "app-1", resp.Applications[0].Name)
 }
 
+func TestMockServer_GetApplication(t *testing.T) {
+	server := NewMockServer("test-token", "test-secret")
+	ctx := context.Background()
+
+	clusterResp, _ := server.CreateCluster(ctx, &api.CreateCluster{
+		Name:               "test-cluster",
+		ServicePrincipalID: "sp-123",
+	})
+	appResp, _ := server.CreateApplication(ctx, &api.CreateApplication{
+		Name:      "test-app",
+		ClusterID: clusterResp.Cluster.ClusterID,
+	})
+
+	resp, err := server.GetApplication(ctx, api.GetApplicationParams{
+		ApplicationID: appResp.Application.ApplicationID,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "test-app", resp.Application.Name)
+	assert.True(t, resp.Application.ActiveVersion.Null)
+
+	// Non-existent application
+	_, err = server.GetApplication(ctx, api.GetApplicationParams{
+		ApplicationID: api.ApplicationID(uuid.New()),
+	})
+	assert.Error(t, err)
+}
+
 func TestMockServer_CreateApplicationVersion(t *testing.T) {
 	server := NewMockServer("test-token", "test-secret")
 	ctx := context.Background()
@@ -330,6 +357,94 @@ func TestMockServer_ListApplicationVersions(t *testing.T) {
 	assert.Len(t, resp.Versions, 2)
 }
 
+func TestMockServer_ListApplicationVersions_Pagination(t *testing.T) {
+	server := NewMockServer("test-token", "test-secret")
+	ctx := context.Background()
+
+	clusterResp, _ := server.CreateCluster(ctx, &api.CreateCluster{
+		Name:               "test-cluster",
+		ServicePrincipalID: "sp-123",
+	})
+	appResp, _ := server.CreateApplication(ctx, &api.CreateApplication{
+		Name:      "test-app",
+		ClusterID: clusterResp.Cluster.ClusterID,
+	})
+	appID := appResp.Application.ApplicationID
+
+	for range 5 {
+		_, _ = server.CreateApplicationVersion(ctx, &api.CreateApplicationVersion{
+			CPU:         500,
+			Memory:      1024,
+			ScalingMode: api.ScalingModeManual,
+			FixedScale:  api.OptInt32{Value: 1, Set: true},
+			Image:       "nginx:latest",
+		}, api.CreateApplicationVersionParams{ApplicationID: appID})
+	}
+
+	// First page
+	resp, err := server.ListApplicationVersions(ctx, api.ListApplicationVersionsParams{
+		ApplicationID: appID,
+		MaxItems:      2,
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Versions, 2)
+	assert.Equal(t, api.ApplicationVersionNumber(1), resp.Versions[0].Version)
+	assert.Equal(t, api.ApplicationVersionNumber(2), resp.Versions[1].Version)
+	assert.Equal(t, api.NewOptApplicationVersionNumber(2), resp.NextCursor)
+
+	// Second page starts after the cursor
+	resp, err = server.ListApplicationVersions(ctx, api.ListApplicationVersionsParams{
+		ApplicationID: appID,
+		MaxItems:      2,
+		Cursor:        resp.NextCursor,
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Versions, 2)
+	assert.Equal(t, api.ApplicationVersionNumber(3), resp.Versions[0].Version)
+
+	// Last page has no cursor
+	resp, err = server.ListApplicationVersions(ctx, api.ListApplicationVersionsParams{
+		ApplicationID: appID,
+		MaxItems:      2,
+		Cursor:        resp.NextCursor,
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Versions, 1)
+	assert.Equal(t, api.ApplicationVersionNumber(5), resp.Versions[0].Version)
+	assert.False(t, resp.NextCursor.Set)
+}
+
+func TestMockServer_GetApplicationContainers(t *testing.T) {
+	server := NewMockServer("test-token", "test-secret")
+	ctx := context.Background()
+
+	clusterResp, _ := server.CreateCluster(ctx, &api.CreateCluster{
+		Name:               "test-cluster",
+		ServicePrincipalID: "sp-123",
+	})
+	appResp, _ := server.CreateApplication(ctx, &api.CreateApplication{
+		Name:      "test-app",
+		ClusterID: clusterResp.Cluster.ClusterID,
+	})
+	appID := appResp.Application.ApplicationID
+
+	// No containers until the rollout is simulated
+	resp, err := server.GetApplicationContainers(ctx, api.GetApplicationContainersParams{ApplicationID: appID})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Nodes)
+
+	server.SetApplicationContainers(appID,
+		api.ApplicationContainerSummary{ID: "c1", State: "running", ApplicationVersion: 1},
+		api.ApplicationContainerSummary{ID: "c2", State: "running", ApplicationVersion: 1},
+	)
+	resp, err = server.GetApplicationContainers(ctx, api.GetApplicationContainersParams{ApplicationID: appID})
+	require.NoError(t, err)
+	require.Len(t, resp.Nodes, 1)
+	stats, ok := resp.Nodes[0].ContainersStats.Get()
+	require.True(t, ok)
+	assert.Len(t, stats.Containers, 2)
+}
+
 func TestMockServer_GetApplicationVersion(t *testing.T) {
 	server := NewMockServer("test-token", "test-secret")
 	ctx := context.Background()
@@ -457,6 +572,33 @@ func TestMockServer_UpdateApplication_ClearActiveVersion(t *testing.T) {
 	assert.True(t, app.DesiredCount.Null)
 }
 
+func TestMockServer_DeleteAutoScalingGroup_WithLoadBalancers(t *testing.T) {
+	server := NewMockServer("test-token", "test-secret")
+	ctx := context.Background()
+
+	clusterID := api.ClusterID(uuid.New())
+	asgID := api.AutoScalingGroupID(uuid.New())
+	lbID := api.LoadBalancerID(uuid.New())
+	server.AddAutoScalingGroup(clusterID, api.ReadAutoScalingGroupDetail{AutoScalingGroupID: asgID, Name: "asg"})
+	server.AddLoadBalancer(asgID, api.ReadLoadBalancerDetail{LoadBalancerID: lbID, Name: "lb"})
+
+	params := api.DeleteAutoScalingGroupParams{ClusterID: clusterID, AutoScalingGroupID: asgID}
+	assert.Error(t, server.DeleteAutoScalingGroup(ctx, params), "ASG with LBs should not be deleted")
+
+	require.NoError(t, server.DeleteLoadBalancer(ctx, api.DeleteLoadBalancerParams{
+		ClusterID:          clusterID,
+		AutoScalingGroupID: asgID,
+		LoadBalancerID:     lbID,
+	}))
+	assert.False(t, server.HasLoadBalancer(asgID, lbID))
+
+	require.NoError(t, server.DeleteAutoScalingGroup(ctx, params))
+	assert.False(t, server.HasAutoScalingGroup(asgID))
+
+	_, err := server.GetAutoScalingGroup(ctx, api.GetAutoScalingGroupParams{ClusterID: clusterID, AutoScalingGroupID: asgID})
+	assert.Error(t, err)
+}
+
 func TestMockServer_ClearAll(t *testing.T) {
 	server := NewMockServer("test-token", "test-secret")
 	ctx := context.Background()